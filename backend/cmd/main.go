@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -11,6 +14,7 @@ import (
 	"github.com/reaviseapp/rv-backend/internal/database"
 	"github.com/reaviseapp/rv-backend/internal/handlers"
 	"github.com/reaviseapp/rv-backend/internal/middleware"
+	"github.com/reaviseapp/rv-backend/internal/migrations"
 	"github.com/reaviseapp/rv-backend/internal/services"
 )
 
@@ -48,29 +52,127 @@ func main() {
 	}
 	defer db.Close()
 
+	// Duplicates would stop the one-open-listing-per-post index building
+	if err := migrations.DedupeOpenListings(db); err != nil {
+		log.Fatal("Failed to dedupe NFT listings:", err)
+	}
+
+	if err := db.EnsureIndexes(); err != nil {
+		log.Fatal("Failed to create database indexes:", err)
+	}
+
+	if err := migrations.MigrateDirectMessages(db); err != nil {
+		log.Fatal("Failed to migrate messages to conversations:", err)
+	}
+
+	if err := migrations.BackfillPostCategories(db); err != nil {
+		log.Fatal("Failed to backfill post categories:", err)
+	}
+
+	if err := migrations.BackfillUsernameKeys(db); err != nil {
+		log.Fatal("Failed to backfill username keys:", err)
+	}
+
+	if err := migrations.BackfillAuthorFlags(db); err != nil {
+		log.Fatal("Failed to backfill author flags:", err)
+	}
+
+	if err := migrations.MigrateCopyrightDisablements(db); err != nil {
+		log.Fatal("Failed to migrate copyright disablements:", err)
+	}
+
+	var adminEmails []string
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			adminEmails = append(adminEmails, email)
+		}
+	}
+	if err := migrations.GrantAdminRoles(db, adminEmails); err != nil {
+		log.Fatal("Failed to grant admin roles:", err)
+	}
+
 	// Initialize services
-	authService := services.NewAuthService(jwtSecret)
+	authService := services.NewAuthService(jwtSecret, db)
 
 	// Initialize services
 	paymentService := services.NewPaymentService()
-	recommendationService := services.NewRecommendationService(db)
+	privacyService := services.NewPrivacyService(db)
+	recommendationService := services.NewRecommendationService(db, privacyService)
+
+	chainClient, err := services.NewChainClient()
+	if err != nil {
+		log.Fatal("Failed to initialize chain client:", err)
+	}
+
+	eventBroker, err := services.NewEventBroker(db)
+	if err != nil {
+		log.Fatal("Failed to initialize realtime broker:", err)
+	}
+	realtimeHub := services.NewRealtimeHub(eventBroker)
+	go realtimeHub.Run(context.Background())
+	notificationService := services.NewNotificationService(db, realtimeHub)
+
+	profileSyncService := services.NewProfileSyncService(db)
+	go profileSyncService.Run(context.Background())
+	moderationService := services.NewModerationService(db, realtimeHub, profileSyncService)
+	go moderationService.Run(context.Background())
+	copyrightService := services.NewCopyrightService(db, moderationService, notificationService)
+	go copyrightService.Run(context.Background())
+	postService := services.NewPostService(db)
+	go postService.Run(context.Background())
+	mintService := services.NewMintService(db, chainClient)
+	go mintService.Run(context.Background())
+
+	uploadDir := os.Getenv("UPLOAD_DIR")
+	if uploadDir == "" {
+		uploadDir = "./uploads"
+	}
+
+	// Identity and business documents and message attachments; never
+	// served statically
+	privateUploadDir := os.Getenv("PRIVATE_UPLOAD_DIR")
+	if privateUploadDir == "" {
+		privateUploadDir = "./private_uploads"
+	}
+
+	blobStore, err := services.NewBlobStore(uploadDir)
+	if err != nil {
+		log.Fatal("Failed to initialize media storage:", err)
+	}
+	mediaProcessor := services.NewMediaProcessor(db, blobStore, realtimeHub, profileSyncService)
+	go mediaProcessor.Run(context.Background())
+
+	nftMetadataBaseURL := os.Getenv("NFT_METADATA_BASE_URL")
+	if nftMetadataBaseURL == "" {
+		nftMetadataBaseURL = "http://localhost:" + port + "/api/posts/"
+	}
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db, authService)
-	postHandler := handlers.NewPostHandler(db)
-	userHandler := handlers.NewUserHandler(db)
-	messageHandler := handlers.NewMessageHandler(db)
-	commentHandler := handlers.NewCommentHandler(db)
-	transactionHandler := handlers.NewTransactionHandler(db)
-	nftHandler := handlers.NewNFTHandler(db)
+	postHandler := handlers.NewPostHandler(db, privacyService, notificationService)
+	userHandler := handlers.NewUserHandler(db, privacyService, profileSyncService, notificationService)
+	attachmentStore := services.NewFSBlobStore(filepath.Join(privateUploadDir, "messages"), "")
+	messageHandler := handlers.NewMessageHandler(db, realtimeHub, privacyService, attachmentStore, notificationService)
+	commentHandler := handlers.NewCommentHandler(db, privacyService, realtimeHub, notificationService)
+	transactionHandler := handlers.NewTransactionHandler(db, notificationService)
+	nftHandler := handlers.NewNFTHandler(db, chainClient, mintService, privacyService, notificationService, nftMetadataBaseURL)
+
+	allowedOrigins := []string{"http://localhost:5173", "http://localhost:3000"}
+	reportHandler := handlers.NewReportHandler(db, moderationService)
+	mediaHandler := handlers.NewMediaHandler(db, blobStore)
+	adminHandler := handlers.NewAdminHandler(db, moderationService)
+	businessHandler := handlers.NewBusinessHandler(db, realtimeHub, privateUploadDir)
+	dmcaHandler := handlers.NewDMCAHandler(db, moderationService, copyrightService)
+	realtimeHandler := handlers.NewRealtimeHandler(db, authService, realtimeHub, privacyService, allowedOrigins)
+	notificationHandler := handlers.NewNotificationHandler(db)
 
 	// Setup Gin router
 	router := gin.Default()
 
 	// Middleware
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:5173", "http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowOrigins:     allowedOrigins,
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -89,26 +191,54 @@ func main() {
 			auth.GET("/me", middleware.AuthMiddleware(authService), authHandler.GetCurrentUser)
 		}
 
+		// Media routes (protected)
+		media := api.Group("/media", middleware.AuthMiddleware(authService))
+		{
+			media.POST("", mediaHandler.Upload)
+			media.POST("/presign", mediaHandler.Presign)
+			media.POST("/:id/complete", mediaHandler.Complete)
+			media.GET("/:id", mediaHandler.GetMedia)
+			media.DELETE("/:id", mediaHandler.DeleteMedia)
+		}
+
 		// Posts routes
 		posts := api.Group("/posts")
 		{
-			posts.GET("", postHandler.GetPosts)
-			posts.GET("/:id", postHandler.GetPost)
+			posts.GET("", middleware.OptionalAuth(authService), postHandler.GetPosts)
+			posts.GET("/deleted", middleware.AuthMiddleware(authService), postHandler.GetDeletedPosts)
+			posts.GET("/:id", middleware.OptionalAuth(authService), postHandler.GetPost)
+			posts.GET("/:id/revisions", middleware.OptionalAuth(authService), postHandler.GetPostRevisions)
 			
 			// Protected routes
 			posts.POST("", middleware.AuthMiddleware(authService), postHandler.CreatePost)
 			posts.POST("/:id/like", middleware.AuthMiddleware(authService), postHandler.LikePost)
+			posts.PUT("/:id", middleware.AuthMiddleware(authService), postHandler.UpdatePost)
+			posts.DELETE("/:id", middleware.AuthMiddleware(authService), postHandler.DeletePost)
+			posts.POST("/:id/restore", middleware.AuthMiddleware(authService), postHandler.RestorePost)
+			posts.PUT("/:id/comment-settings", middleware.AuthMiddleware(authService), postHandler.UpdateCommentSettings)
 		}
 
 		// User routes
 		users := api.Group("/users")
 		{
-			users.GET("/:id", userHandler.GetUser)
+			users.GET("/by-username/:username", middleware.OptionalAuth(authService), userHandler.GetUserByUsername)
+			users.GET("/:id", middleware.OptionalAuth(authService), userHandler.GetUser)
+			users.GET("/:id/followers", middleware.OptionalAuth(authService), userHandler.GetFollowers)
+			users.GET("/:id/following", middleware.OptionalAuth(authService), userHandler.GetFollowing)
 			
 			// Protected routes
+			users.PATCH("/:id", middleware.AuthMiddleware(authService), userHandler.UpdateUser)
 			users.PUT("/:id", middleware.AuthMiddleware(authService), userHandler.UpdateUser)
+			users.PUT("/:id/wallet", middleware.AuthMiddleware(authService), userHandler.LinkWallet)
 			users.POST("/:id/follow", middleware.AuthMiddleware(authService), userHandler.FollowUser)
 			users.DELETE("/:id/follow", middleware.AuthMiddleware(authService), userHandler.UnfollowUser)
+			users.GET("/blocked", middleware.AuthMiddleware(authService), userHandler.GetBlockedUsers)
+			users.GET("/follow-requests", middleware.AuthMiddleware(authService), userHandler.GetFollowRequests)
+			users.POST("/follow-requests/:id/approve", middleware.AuthMiddleware(authService), userHandler.ApproveFollowRequest)
+			users.POST("/follow-requests/:id/reject", middleware.AuthMiddleware(authService), userHandler.RejectFollowRequest)
+			users.POST("/:id/block", middleware.AuthMiddleware(authService), userHandler.BlockUser)
+			users.DELETE("/:id/block", middleware.AuthMiddleware(authService), userHandler.UnblockUser)
+			users.PUT("/:id/privacy", middleware.AuthMiddleware(authService), userHandler.UpdatePrivacy)
 			users.DELETE("/:id", middleware.AuthMiddleware(authService), userHandler.DeleteUser)
 		}
 
@@ -116,18 +246,65 @@ func main() {
 		messages := api.Group("/messages", middleware.AuthMiddleware(authService))
 		{
 			messages.POST("", messageHandler.SendMessage)
+			messages.POST("/attachments", messageHandler.UploadAttachment)
+			messages.GET("/attachments/:id", messageHandler.GetAttachment)
 			messages.GET("/conversations", messageHandler.GetConversations)
+			messages.GET("/unread", messageHandler.GetUnreadCount)
 			messages.GET("/:userId", messageHandler.GetMessages)
+			messages.POST("/:userId/read", messageHandler.MarkAsRead)
+		}
+
+		// Conversation routes (all protected)
+		conversations := api.Group("/conversations", middleware.AuthMiddleware(authService))
+		{
+			conversations.POST("", messageHandler.CreateConversation)
+			conversations.GET("", messageHandler.GetConversations)
+			conversations.GET("/requests", messageHandler.GetMessageRequests)
+			conversations.GET("/:id", messageHandler.GetConversation)
+			conversations.PUT("/:id", messageHandler.UpdateConversation)
+			conversations.GET("/:id/messages", messageHandler.GetConversationMessages)
+			conversations.POST("/:id/messages", messageHandler.SendConversationMessage)
+			conversations.POST("/:id/read", messageHandler.MarkConversationRead)
+			conversations.POST("/:id/accept", messageHandler.AcceptMessageRequest)
+			conversations.POST("/:id/decline", messageHandler.DeclineMessageRequest)
+			conversations.POST("/:id/offers", messageHandler.SendOffer)
+			conversations.POST("/:id/offers/:messageId/accept", messageHandler.AcceptOffer)
+			conversations.POST("/:id/offers/:messageId/decline", messageHandler.DeclineOffer)
+			conversations.POST("/:id/offers/:messageId/withdraw", messageHandler.WithdrawOffer)
+			conversations.POST("/:id/participants", messageHandler.AddParticipant)
+			conversations.DELETE("/:id/participants/:userId", messageHandler.RemoveParticipant)
+		}
+
+		// Realtime events over WebSocket (authenticates with the token query parameter)
+		api.GET("/ws", realtimeHandler.Connect)
+
+		// Notification routes (all protected)
+		notifications := api.Group("/notifications", middleware.AuthMiddleware(authService))
+		{
+			notifications.GET("", notificationHandler.GetNotifications)
+			notifications.GET("/unread", notificationHandler.GetUnreadCount)
+			notifications.POST("/read-all", notificationHandler.MarkAllRead)
+			notifications.POST("/:id/read", notificationHandler.MarkRead)
+			notifications.GET("/preferences", notificationHandler.GetPreferences)
+			notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
 		}
 
 		// Comment routes
 		comments := api.Group("/comments")
 		{
-			comments.GET("/post/:postId", commentHandler.GetComments)
+			comments.GET("/post/:postId", middleware.OptionalAuth(authService), commentHandler.GetComments)
+			comments.GET("/:id/replies", middleware.OptionalAuth(authService), commentHandler.GetReplies)
 			
 			// Protected routes
 			comments.POST("/post/:postId", middleware.AuthMiddleware(authService), commentHandler.CreateComment)
+			comments.PUT("/:id", middleware.AuthMiddleware(authService), commentHandler.UpdateComment)
 			comments.DELETE("/:id", middleware.AuthMiddleware(authService), commentHandler.DeleteComment)
+			comments.POST("/:id/like", middleware.AuthMiddleware(authService), commentHandler.LikeComment)
+			comments.DELETE("/:id/like", middleware.AuthMiddleware(authService), commentHandler.UnlikeComment)
+			comments.POST("/:id/hide", middleware.AuthMiddleware(authService), commentHandler.HideComment)
+			comments.POST("/:id/unhide", middleware.AuthMiddleware(authService), commentHandler.UnhideComment)
+			comments.GET("/filters", middleware.AuthMiddleware(authService), commentHandler.GetCommentFilters)
+			comments.PUT("/filters", middleware.AuthMiddleware(authService), commentHandler.UpdateCommentFilters)
 		}
 
 		// Transaction routes (all protected)
@@ -142,15 +319,75 @@ func main() {
 		// NFT routes
 		nfts := api.Group("/nft")
 		{
-			nfts.GET("", nftHandler.GetNFTListings)
-			nfts.GET("/:id", nftHandler.GetNFTListing)
+			nfts.GET("", middleware.OptionalAuth(authService), nftHandler.GetNFTListings)
+			nfts.GET("/earnings", middleware.AuthMiddleware(authService), nftHandler.GetEarnings)
+			nfts.GET("/:id", middleware.OptionalAuth(authService), nftHandler.GetNFTListing)
+			nfts.GET("/:id/edits", middleware.OptionalAuth(authService), nftHandler.GetNFTListingEdits)
+			nfts.GET("/:id/provenance", middleware.OptionalAuth(authService), nftHandler.GetProvenance)
 			
 			// Protected routes
 			nfts.POST("", middleware.AuthMiddleware(authService), nftHandler.CreateNFTListing)
+			nfts.PUT("/:id", middleware.AuthMiddleware(authService), nftHandler.UpdateNFTListing)
+			nfts.POST("/:id/cancel", middleware.AuthMiddleware(authService), nftHandler.CancelNFTListing)
 			nfts.POST("/:id/bid", middleware.AuthMiddleware(authService), nftHandler.PlaceBid)
 			nfts.POST("/:id/complete", middleware.AuthMiddleware(authService), nftHandler.CompleteAuction)
 		}
 
+		// Report routes (protected)
+		api.POST("/reports", middleware.AuthMiddleware(authService), reportHandler.CreateReport)
+
+		// Copyright routes
+		dmca := api.Group("/dmca")
+		{
+			dmca.POST("/notices", middleware.OptionalAuth(authService), dmcaHandler.FileNotice)
+			dmca.GET("/notices", middleware.AuthMiddleware(authService), dmcaHandler.GetMyNotices)
+			dmca.GET("/notices/:id", middleware.AuthMiddleware(authService), dmcaHandler.GetNotice)
+			dmca.POST("/notices/:id/counter", middleware.AuthMiddleware(authService), dmcaHandler.FileCounterNotice)
+		}
+
+		// Moderation routes (moderators and admins only)
+		moderation := api.Group("/moderation", middleware.AuthMiddleware(authService), middleware.RequireRole("moderator", "admin"))
+		{
+			moderation.GET("/reports", reportHandler.GetReports)
+			moderation.GET("/reports/:id", reportHandler.GetReport)
+			moderation.PUT("/reports/:id/status", reportHandler.UpdateReportStatus)
+			moderation.POST("/reports/:id/actions", reportHandler.TakeAction)
+			moderation.GET("/dmca", dmcaHandler.GetNotices)
+			moderation.POST("/dmca/:id/lawsuit", dmcaHandler.RecordLawsuit)
+			moderation.POST("/dmca/:id/reject", dmcaHandler.RejectNotice)
+		}
+
+		// Business account routes (protected)
+		business := api.Group("/business", middleware.AuthMiddleware(authService))
+		{
+			business.POST("/documents", businessHandler.UploadDocument)
+			business.GET("/documents/:id", businessHandler.GetDocument)
+			business.POST("/applications", businessHandler.SubmitApplication)
+			business.GET("/applications", businessHandler.GetMyApplications)
+			business.POST("/applications/:id/withdraw", businessHandler.WithdrawApplication)
+
+			// Approved business accounts only
+			business.PUT("/storefront", middleware.RequireBusinessAccount(db), businessHandler.UpdateStorefront)
+			business.GET("/analytics", middleware.RequireBusinessAccount(db), businessHandler.GetSalesAnalytics)
+		}
+
+		// Admin routes (admins only)
+		admin := api.Group("/admin", middleware.AuthMiddleware(authService), middleware.RequireRole("admin"))
+		{
+			admin.GET("/users", adminHandler.SearchUsers)
+			admin.GET("/users/:id", adminHandler.GetUser)
+			admin.POST("/users/:id/suspend", adminHandler.SuspendUser)
+			admin.DELETE("/users/:id/suspend", adminHandler.UnsuspendUser)
+			admin.PUT("/users/:id/roles", adminHandler.SetRoles)
+			admin.PUT("/users/:id/verification", adminHandler.SetVerification)
+			admin.DELETE("/content/:type/:id", adminHandler.RemoveContent)
+			admin.GET("/audit-log", adminHandler.GetAuditLog)
+			admin.GET("/business-applications", businessHandler.GetApplications)
+			admin.GET("/business-applications/:id", businessHandler.GetApplication)
+			admin.POST("/business-applications/:id/approve", businessHandler.ApproveApplication)
+			admin.POST("/business-applications/:id/reject", businessHandler.RejectApplication)
+		}
+
 		// Recommendation routes (protected)
 		recommendations := api.Group("/recommendations", middleware.AuthMiddleware(authService))
 		{
@@ -200,6 +437,9 @@ func main() {
 		}
 	}
 
+	// Uploaded files
+	router.Static("/uploads", uploadDir)
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -46,6 +48,357 @@ func (db *Database) Close() error {
 	return db.Client.Disconnect(ctx)
 }
 
+// EnsureIndexes creates the indexes that enforce uniqueness rules the
+// handlers depend on. It is safe to call on every startup.
+func (db *Database) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	indexes := map[*mongo.Collection][]mongo.IndexModel{
+		db.Users(): {
+			{
+				Keys: bson.D{{Key: "wallet_address", Value: 1}},
+				Options: options.Index().
+					SetName("wallet_address_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"wallet_address": bson.M{"$exists": true}}),
+			},
+			{
+				// Users from before usernames were unique may not have a key
+				// yet; see migrations.BackfillUsernameKeys
+				Keys: bson.D{{Key: "username_key", Value: 1}},
+				Options: options.Index().
+					SetName("username_key_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"username_key": bson.M{"$exists": true}}),
+			},
+		},
+		db.NFTListings(): {
+			{
+				// Only one open listing per post at a time, counting one
+				// whose auction is being settled
+				Keys: bson.D{{Key: "post_id", Value: 1}},
+				Options: options.Index().
+					SetName("post_id_open_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"status": bson.M{"$in": []string{"active", "settling"}}}),
+			},
+			{
+				Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("owner_id"),
+			},
+			{
+				Keys:    bson.D{{Key: "owner_id", Value: 1}},
+				Options: options.Index().SetName("author_suspended").SetPartialFilterExpression(bson.M{"author_suspended": true}),
+			},
+		},
+		db.RealtimeEvents(): {
+			{
+				// Events only need to live long enough to reach every node
+				Keys:    bson.D{{Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("created_at_ttl").SetExpireAfterSeconds(60),
+			},
+		},
+		db.Conversations(): {
+			{
+				Keys:    bson.D{{Key: "participants.user_id", Value: 1}, {Key: "last_message_at", Value: -1}},
+				Options: options.Index().SetName("participant_recent"),
+			},
+			{
+				// One direct conversation per pair of users
+				Keys: bson.D{{Key: "direct_key", Value: 1}},
+				Options: options.Index().
+					SetName("direct_key_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"direct_key": bson.M{"$exists": true}}),
+			},
+		},
+		db.Messages(): {
+			{
+				Keys:    bson.D{{Key: "conversation_id", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("conversation_created_at"),
+			},
+			{
+				// Access checks on attachment downloads
+				Keys:    bson.D{{Key: "attachments._id", Value: 1}},
+				Options: options.Index().SetName("attachment_id").SetSparse(true),
+			},
+			{
+				// Offers referring to a post, checked before purging it
+				Keys:    bson.D{{Key: "offer.post_id", Value: 1}},
+				Options: options.Index().SetName("offer_post_id").SetSparse(true),
+			},
+		},
+		db.Follows(): {
+			{
+				Keys:    bson.D{{Key: "followee_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("followee_recent"),
+			},
+			{
+				Keys:    bson.D{{Key: "follower_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("follower_recent"),
+			},
+		},
+		db.FollowRequests(): {
+			{
+				Keys:    bson.D{{Key: "requester_id", Value: 1}, {Key: "target_id", Value: 1}},
+				Options: options.Index().SetName("requester_target_unique").SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "target_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("target_recent"),
+			},
+		},
+		db.Reports(): {
+			{
+				// Moderator queue, oldest first
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("status_created_at"),
+			},
+			{
+				Keys:    bson.D{{Key: "target_type", Value: 1}, {Key: "target_id", Value: 1}},
+				Options: options.Index().SetName("target"),
+			},
+		},
+		db.ModerationActions(): {
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("user_recent"),
+			},
+		},
+		db.BusinessApplications(): {
+			{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("status_created_at"),
+			},
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("user_recent"),
+			},
+		},
+		db.Media(): {
+			{
+				Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("owner_recent"),
+			},
+			{
+				Keys:    bson.D{{Key: "processing_status", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("processing_queue"),
+			},
+		},
+		db.Posts(): {
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("user_id"),
+			},
+			{
+				Keys:    bson.D{{Key: "media.media_id", Value: 1}},
+				Options: options.Index().SetName("media_id"),
+			},
+			{
+				Keys:    bson.D{{Key: "categories", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("categories_recent"),
+			},
+			{
+				Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+				Options: options.Index().SetName("deleted").SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$exists": true}}),
+			},
+			{
+				Keys:    bson.D{{Key: "processing_status", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("processing").SetPartialFilterExpression(bson.M{"processing_status": bson.M{"$exists": true}}),
+			},
+			{
+				// The suspension sweep finds suspended authors' content
+				Keys:    bson.D{{Key: "user_id", Value: 1}},
+				Options: options.Index().SetName("author_suspended").SetPartialFilterExpression(bson.M{"author_suspended": true}),
+			},
+		},
+		db.PostRevisions(): {
+			{
+				Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "revision", Value: 1}},
+				Options: options.Index().SetName("post_revision").SetUnique(true),
+			},
+		},
+		db.Comments(): {
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("user_id"),
+			},
+			{
+				Keys:    bson.D{{Key: "post_id", Value: 1}, {Key: "parent_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("post_thread"),
+			},
+			{
+				Keys:    bson.D{{Key: "parent_id", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().SetName("replies").SetPartialFilterExpression(bson.M{"parent_id": bson.M{"$exists": true}}),
+			},
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}},
+				Options: options.Index().SetName("author_suspended").SetPartialFilterExpression(bson.M{"author_suspended": true}),
+			},
+		},
+		db.CommentLikes(): {
+			{
+				Keys:    bson.D{{Key: "comment_id", Value: 1}, {Key: "user_id", Value: 1}},
+				Options: options.Index().SetName("comment_user_unique").SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "post_id", Value: 1}},
+				Options: options.Index().SetName("post_id"),
+			},
+		},
+		db.Notifications(): {
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}},
+				Options: options.Index().SetName("user_recent"),
+			},
+			{
+				// One open group per kind and subject, joined until it is read
+				Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "group_key", Value: 1}},
+				Options: options.Index().
+					SetName("unread_group_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"is_read": false, "group_key": bson.M{"$exists": true}}),
+			},
+			{
+				Keys:    bson.D{{Key: "updated_at", Value: 1}},
+				Options: options.Index().SetName("expiry").SetExpireAfterSeconds(90 * 24 * 60 * 60),
+			},
+		},
+		db.ProfileSyncs(): {
+			{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: 1}},
+				Options: options.Index().SetName("status_updated"),
+			},
+		},
+		db.AuditLog(): {
+			{
+				Keys:    bson.D{{Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("recent"),
+			},
+			{
+				Keys:    bson.D{{Key: "admin_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("admin_recent"),
+			},
+			{
+				Keys:    bson.D{{Key: "target_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("target_recent"),
+			},
+		},
+		db.DMCANotices(): {
+			{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "restore_after", Value: 1}},
+				Options: options.Index().SetName("status_restore_after"),
+			},
+			{
+				Keys:    bson.D{{Key: "status", Value: 1}, {Key: "counter_deadline", Value: 1}},
+				Options: options.Index().SetName("status_counter_deadline"),
+			},
+			{
+				// Rate limiting of notices
+				Keys:    bson.D{{Key: "complainant_ip", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("complainant_ip_recent"),
+			},
+			{
+				Keys:    bson.D{{Key: "complainant_email", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("complainant_email_recent"),
+			},
+			{
+				Keys:    bson.D{{Key: "uploader_id", Value: 1}, {Key: "created_at", Value: -1}},
+				Options: options.Index().SetName("uploader_recent"),
+			},
+		},
+		db.CopyrightStrikes(): {
+			{
+				Keys:    bson.D{{Key: "user_id", Value: 1}},
+				Options: options.Index().SetName("user_id"),
+			},
+			{
+				Keys:    bson.D{{Key: "notice_id", Value: 1}},
+				Options: options.Index().SetName("notice_id_unique").SetUnique(true),
+			},
+		},
+		db.Blocks(): {
+			{
+				Keys:    bson.D{{Key: "blocker_id", Value: 1}, {Key: "blocked_id", Value: 1}},
+				Options: options.Index().SetName("blocker_blocked_unique").SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "blocked_id", Value: 1}},
+				Options: options.Index().SetName("blocked_id"),
+			},
+		},
+		db.Transactions(): {
+			{
+				Keys:    bson.D{{Key: "post_id", Value: 1}},
+				Options: options.Index().SetName("post_id"),
+			},
+		},
+		db.NFTs(): {
+			{
+				Keys:    bson.D{{Key: "post_id", Value: 1}},
+				Options: options.Index().SetName("post_id_unique").SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "owner_id", Value: 1}},
+				Options: options.Index().SetName("owner_id"),
+			},
+			{
+				// The mint sweep looks for mints left in progress
+				Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+				Options: options.Index().
+					SetName("status_created_at").
+					SetPartialFilterExpression(bson.M{"status": bson.M{"$exists": true}}),
+			},
+		},
+		db.NFTTransfers(): {
+			{
+				// One transfer per sale, so a retried settlement can't record two
+				Keys: bson.D{{Key: "listing_id", Value: 1}},
+				Options: options.Index().
+					SetName("listing_id_unique").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"listing_id": bson.M{"$exists": true}}),
+			},
+		},
+		db.Earnings(): {
+			{
+				Keys:    bson.D{{Key: "listing_id", Value: 1}, {Key: "type", Value: 1}},
+				Options: options.Index().SetName("listing_type_unique").SetUnique(true),
+			},
+		},
+	}
+
+	// Indexes replaced by ones above
+	obsolete := map[*mongo.Collection][]string{
+		db.NFTListings(): {"post_id_active_unique", "post_id"},
+	}
+
+	for collection, names := range obsolete {
+		for _, name := range names {
+			_, err := collection.Indexes().DropOne(ctx, name)
+			if err != nil && !isMissingIndex(err) {
+				return err
+			}
+		}
+	}
+
+	for collection, models := range indexes {
+		if _, err := collection.Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isMissingIndex reports whether dropping an index failed because it or its
+// collection doesn't exist (NamespaceNotFound or IndexNotFound).
+func isMissingIndex(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27)
+}
+
 // Collection helpers
 func (db *Database) Users() *mongo.Collection {
 	return db.Database.Collection("users")
@@ -59,10 +412,22 @@ func (db *Database) Comments() *mongo.Collection {
 	return db.Database.Collection("comments")
 }
 
+func (db *Database) CommentLikes() *mongo.Collection {
+	return db.Database.Collection("comment_likes")
+}
+
 func (db *Database) Messages() *mongo.Collection {
 	return db.Database.Collection("messages")
 }
 
+func (db *Database) Attachments() *mongo.Collection {
+	return db.Database.Collection("attachments")
+}
+
+func (db *Database) Conversations() *mongo.Collection {
+	return db.Database.Collection("conversations")
+}
+
 func (db *Database) Transactions() *mongo.Collection {
 	return db.Database.Collection("transactions")
 }
@@ -71,6 +436,30 @@ func (db *Database) NFTListings() *mongo.Collection {
 	return db.Database.Collection("nft_listings")
 }
 
+func (db *Database) NFTs() *mongo.Collection {
+	return db.Database.Collection("nfts")
+}
+
+func (db *Database) Earnings() *mongo.Collection {
+	return db.Database.Collection("earnings")
+}
+
+func (db *Database) NFTTransfers() *mongo.Collection {
+	return db.Database.Collection("nft_transfers")
+}
+
+func (db *Database) NFTListingEdits() *mongo.Collection {
+	return db.Database.Collection("nft_listing_edits")
+}
+
+func (db *Database) PostRevisions() *mongo.Collection {
+	return db.Database.Collection("post_revisions")
+}
+
+func (db *Database) RealtimeEvents() *mongo.Collection {
+	return db.Database.Collection("realtime_events")
+}
+
 func (db *Database) Likes() *mongo.Collection {
 	return db.Database.Collection("likes")
 }
@@ -78,3 +467,55 @@ func (db *Database) Likes() *mongo.Collection {
 func (db *Database) Follows() *mongo.Collection {
 	return db.Database.Collection("follows")
 }
+
+func (db *Database) FollowRequests() *mongo.Collection {
+	return db.Database.Collection("follow_requests")
+}
+
+func (db *Database) Blocks() *mongo.Collection {
+	return db.Database.Collection("blocks")
+}
+
+func (db *Database) Reports() *mongo.Collection {
+	return db.Database.Collection("reports")
+}
+
+func (db *Database) ModerationActions() *mongo.Collection {
+	return db.Database.Collection("moderation_actions")
+}
+
+func (db *Database) DMCANotices() *mongo.Collection {
+	return db.Database.Collection("dmca_notices")
+}
+
+func (db *Database) CopyrightStrikes() *mongo.Collection {
+	return db.Database.Collection("copyright_strikes")
+}
+
+func (db *Database) AuditLog() *mongo.Collection {
+	return db.Database.Collection("audit_log")
+}
+
+func (db *Database) BusinessApplications() *mongo.Collection {
+	return db.Database.Collection("business_applications")
+}
+
+func (db *Database) BusinessDocuments() *mongo.Collection {
+	return db.Database.Collection("business_documents")
+}
+
+func (db *Database) ProfileSyncs() *mongo.Collection {
+	return db.Database.Collection("profile_syncs")
+}
+
+func (db *Database) Notifications() *mongo.Collection {
+	return db.Database.Collection("notifications")
+}
+
+func (db *Database) Media() *mongo.Collection {
+	return db.Database.Collection("media")
+}
+
+func (db *Database) Migrations() *mongo.Collection {
+	return db.Database.Collection("migrations")
+}
@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AdminHandler serves the /admin API. Every change it makes is written to
+// the audit log.
+type AdminHandler struct {
+	db         *database.Database
+	moderation *services.ModerationService
+}
+
+func NewAdminHandler(db *database.Database, moderation *services.ModerationService) *AdminHandler {
+	return &AdminHandler{db: db, moderation: moderation}
+}
+
+// AdminUser is a user with the account state only admins can see.
+type AdminUser struct {
+	models.User
+	WarningsCount int                `json:"warningsCount"`
+	Suspension    *models.Suspension `json:"suspension,omitempty"`
+}
+
+func newAdminUser(user models.User) AdminUser {
+	return AdminUser{User: user, WarningsCount: user.WarningsCount, Suspension: user.Suspension}
+}
+
+// SearchUsers finds users by username or email prefix, newest first.
+func (h *AdminHandler) SearchUsers(c *gin.Context) {
+	page, perPage := parsePagination(c)
+
+	filter := bson.M{}
+	if q := c.Query("q"); q != "" {
+		pattern := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(q), Options: "i"}
+		filter["$or"] = []bson.M{{"username": pattern}, {"email": pattern}}
+	}
+	if role := c.Query("role"); role != "" {
+		filter["roles"] = role
+	}
+	if c.Query("suspended") == "true" {
+		filter["suspension"] = bson.M{"$exists": true}
+	}
+	if c.Query("isBusinessAccount") == "true" {
+		filter["is_business_account"] = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := h.db.Users().CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * perPage)).
+		SetLimit(int64(perPage))
+
+	cursor, err := h.db.Users().Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search users"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode users"})
+		return
+	}
+
+	results := make([]AdminUser, 0, len(users))
+	for _, user := range users {
+		results = append(results, newAdminUser(user))
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       results,
+		Pagination: newPagination(page, perPage, total),
+	})
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := h.db.Users().FindOne(ctx, bson.M{"_id": c.Param("id")}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUser(user))
+}
+
+// SuspendUser suspends a user for durationDays, or permanently if omitted.
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	adminID := c.GetString("userID")
+	userID := c.Param("id")
+
+	var req struct {
+		Reason       string `json:"reason" binding:"required"`
+		DurationDays int    `json:"durationDays" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if userID == adminID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot suspend yourself"})
+		return
+	}
+
+	var until *time.Time
+	if req.DurationDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.DurationDays)
+		until = &expiresAt
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry, ok := beginAudit(ctx, c, h.db, "suspend_user", "user", userID, map[string]interface{}{
+		"reason": req.Reason,
+		"until":  until,
+	})
+	if !ok {
+		return
+	}
+
+	err := h.moderation.Suspend(ctx, userID, req.Reason, until, adminID)
+	finishAudit(h.db, entry, err, nil)
+	if err == services.ErrTargetNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suspend user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User suspended successfully"})
+}
+
+func (h *AdminHandler) UnsuspendUser(c *gin.Context) {
+	userID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry, ok := beginAudit(ctx, c, h.db, "unsuspend_user", "user", userID, nil)
+	if !ok {
+		return
+	}
+
+	err := h.moderation.Unsuspend(ctx, userID)
+	finishAudit(h.db, entry, err, nil)
+	if err == services.ErrTargetNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsuspend user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unsuspended successfully"})
+}
+
+// SetRoles replaces a user's roles. The user's token picks up the new roles
+// at their next login.
+func (h *AdminHandler) SetRoles(c *gin.Context) {
+	adminID := c.GetString("userID")
+	userID := c.Param("id")
+
+	var req struct {
+		Roles []string `json:"roles" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	roles := []string{}
+	seen := map[string]bool{}
+	for _, role := range req.Roles {
+		if !models.UserRoles[role] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + role})
+			return
+		}
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	// Keep at least one admin able to undo the change
+	if userID == adminID && !seen["admin"] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot remove your own admin role"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry, ok := beginAudit(ctx, c, h.db, "set_roles", "user", userID, map[string]interface{}{
+		"to": roles,
+	})
+	if !ok {
+		return
+	}
+
+	var previous models.User
+	err := h.db.Users().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"roles": roles, "updated_at": time.Now()}},
+	).Decode(&previous)
+	finishAudit(h.db, entry, err, map[string]interface{}{"from": previous.Roles})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// SetVerification grants or revokes a user's verified badge.
+func (h *AdminHandler) SetVerification(c *gin.Context) {
+	userID := c.Param("id")
+
+	var req struct {
+		IsVerified *bool `json:"isVerified" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry, ok := beginAudit(ctx, c, h.db, "set_verification", "user", userID, map[string]interface{}{
+		"isVerified": *req.IsVerified,
+	})
+	if !ok {
+		return
+	}
+
+	result, err := h.db.Users().UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"is_verified": *req.IsVerified, "updated_at": time.Now()}},
+	)
+	if err == nil && result.MatchedCount == 0 {
+		err = services.ErrTargetNotFound
+	}
+	finishAudit(h.db, entry, err, nil)
+	if err == services.ErrTargetNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update verification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"isVerified": *req.IsVerified})
+}
+
+// RemoveContent removes a post, comment, message or listing for everyone.
+func (h *AdminHandler) RemoveContent(c *gin.Context) {
+	adminID := c.GetString("userID")
+	targetType := c.Param("type")
+	targetID := c.Param("id")
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	action := models.ModerationAction{
+		ModeratorID: adminID,
+		Action:      "remove",
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Note:        req.Note,
+	}
+
+	entry, ok := beginAudit(ctx, c, h.db, "remove_content", targetType, targetID, map[string]interface{}{
+		"note": req.Note,
+	})
+	if !ok {
+		return
+	}
+
+	err := h.moderation.Apply(ctx, &action)
+	finishAudit(h.db, entry, err, map[string]interface{}{
+		"ownerId":  action.UserID,
+		"actionId": action.ID,
+	})
+
+	switch err {
+	case nil:
+	case services.ErrTargetNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Content not found"})
+		return
+	case services.ErrInvalidAction:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid content type: " + targetType})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove content"})
+		return
+	}
+
+	c.JSON(http.StatusOK, action)
+}
+
+// GetAuditLog lists admin actions, newest first.
+func (h *AdminHandler) GetAuditLog(c *gin.Context) {
+	page, perPage := parsePagination(c)
+
+	filter := bson.M{}
+	if adminID := c.Query("adminId"); adminID != "" {
+		filter["admin_id"] = adminID
+	}
+	if targetID := c.Query("targetId"); targetID != "" {
+		filter["target_id"] = targetID
+	}
+	if action := c.Query("action"); action != "" {
+		filter["action"] = action
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := h.db.AuditLog().CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * perPage)).
+		SetLimit(int64(perPage))
+
+	cursor, err := h.db.AuditLog().Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.AuditLogEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       entries,
+		Pagination: newPagination(page, perPage, total),
+	})
+}
+
+// beginAudit records an admin action before it is taken, so none goes
+// unrecorded. An action whose entry can't be written must not go ahead;
+// beginAudit writes the error response itself.
+func beginAudit(ctx context.Context, c *gin.Context, db *database.Database, action, targetType, targetID string, details map[string]interface{}) (*models.AuditLogEntry, bool) {
+	entry := models.AuditLogEntry{
+		ID:         primitive.NewObjectID().Hex(),
+		AdminID:    c.GetString("userID"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		Status:     "pending",
+		IPAddress:  c.ClientIP(),
+		CreatedAt:  time.Now(),
+	}
+
+	if _, err := db.AuditLog().InsertOne(ctx, entry); err != nil {
+		log.Printf("Failed to write audit log entry %s on %s %s: %v", action, targetType, targetID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record action in audit log"})
+		return nil, false
+	}
+	return &entry, true
+}
+
+// finishAudit records how an action begun with beginAudit turned out, with
+// any details only known once it ran. It has its own timeout, as the action
+// may have failed on the request's. An entry left pending means the outcome
+// is unknown.
+func finishAudit(db *database.Database, entry *models.AuditLogEntry, actionErr error, details map[string]interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"status": "succeeded"}
+	if actionErr != nil {
+		set["status"] = "failed"
+	}
+	for key, value := range details {
+		set["details."+key] = value
+	}
+
+	if _, err := db.AuditLog().UpdateOne(ctx, bson.M{"_id": entry.ID}, bson.M{"$set": set}); err != nil {
+		log.Printf("Failed to finish audit log entry %s: %v", entry.ID, err)
+	}
+}
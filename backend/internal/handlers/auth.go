@@ -11,6 +11,7 @@ import (
 	"github.com/reaviseapp/rv-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type AuthHandler struct {
@@ -43,6 +44,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	if !validateUsername(c, req.Username) {
+		return
+	}
+
 	// Check if user already exists
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -65,6 +70,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	user := models.User{
 		ID:                primitive.NewObjectID().Hex(),
 		Username:          req.Username,
+		UsernameKey:       models.UsernameKey(req.Username),
 		Email:             req.Email,
 		PasswordHash:      hashedPassword,
 		FollowersCount:    0,
@@ -76,13 +82,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	_, err = h.db.Users().InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username is already taken"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
 	// Generate token
-	token, err := h.authService.GenerateToken(user.ID)
+	token, err := h.authService.GenerateToken(user.ID, user.Roles)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -117,8 +127,23 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Suspended users can't sign in until their suspension ends
+	suspension, err := h.authService.ActiveSuspension(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+		return
+	}
+	if suspension != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "Account suspended",
+			"reason": suspension.Reason,
+			"until":  suspension.Until,
+		})
+		return
+	}
+
 	// Generate token
-	token, err := h.authService.GenerateToken(user.ID)
+	token, err := h.authService.GenerateToken(user.ID, user.Roles)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -0,0 +1,650 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxBusinessDocumentSize caps supporting documents at 10MB.
+const maxBusinessDocumentSize = 10 << 20
+
+type BusinessHandler struct {
+	db          *database.Database
+	hub         *services.RealtimeHub
+	documentDir string
+}
+
+// NewBusinessHandler stores supporting documents under privateUploadDir,
+// which must not be served publicly.
+func NewBusinessHandler(db *database.Database, hub *services.RealtimeHub, privateUploadDir string) *BusinessHandler {
+	return &BusinessHandler{db: db, hub: hub, documentDir: filepath.Join(privateUploadDir, "business")}
+}
+
+// UploadDocument stores a PDF or image supporting a business application.
+func (h *BusinessHandler) UploadDocument(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	limitUploadBody(c, maxBusinessDocumentSize)
+
+	fileHeader, err := c.FormFile("file")
+	if isBodyTooLarge(err) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Document exceeds the 10MB limit"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	if fileHeader.Size > maxBusinessDocumentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Document exceeds the 10MB limit"})
+		return
+	}
+
+	docType := c.PostForm("type")
+	if !models.BusinessDocumentTypes[docType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document type: " + docType})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	// Trust the file's content, not the client's declared type
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType := http.DetectContentType(head[:n])
+
+	if contentType != "application/pdf" && !strings.HasPrefix(contentType, "image/") {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only PDF and image documents are supported"})
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	id := primitive.NewObjectID().Hex()
+	path := filepath.Join(h.documentDir, id+filepath.Ext(fileHeader.Filename))
+
+	if err := os.MkdirAll(h.documentDir, 0o700); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store document"})
+		return
+	}
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store document"})
+		return
+	}
+	defer dst.Close()
+
+	size, err := io.Copy(dst, io.LimitReader(file, maxBusinessDocumentSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store document"})
+		return
+	}
+
+	document := models.BusinessDocument{
+		ID:          id,
+		UploaderID:  userID,
+		Type:        docType,
+		Path:        path,
+		ContentType: contentType,
+		Size:        size,
+		Filename:    filepath.Base(fileHeader.Filename),
+		CreatedAt:   time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := h.db.BusinessDocuments().InsertOne(ctx, document); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record document"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, document)
+}
+
+// GetDocument downloads a supporting document. Only its uploader and admins
+// can read it.
+func (h *BusinessHandler) GetDocument(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var document models.BusinessDocument
+	err := h.db.BusinessDocuments().FindOne(ctx, bson.M{"_id": c.Param("id")}).Decode(&document)
+	if err != nil || (document.UploaderID != userID && !isAdmin(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+
+	c.Header("Content-Type", document.ContentType)
+	c.FileAttachment(document.Path, document.Filename)
+}
+
+type BusinessApplicationRequest struct {
+	BusinessName       string   `json:"businessName" binding:"required,max=200"`
+	RegistrationNumber string   `json:"registrationNumber" binding:"required,max=100"`
+	Country            string   `json:"country" binding:"required,len=2"`
+	Address            string   `json:"address" binding:"required,max=500"`
+	Website            string   `json:"website" binding:"omitempty,url"`
+	ContactEmail       string   `json:"contactEmail" binding:"required,email"`
+	Phone              string   `json:"phone" binding:"max=50"`
+	Description        string   `json:"description" binding:"required,max=2000"`
+	DocumentIDs        []string `json:"documentIds" binding:"required,min=1,max=10"`
+}
+
+// SubmitApplication files a business application for review. A user can
+// only have one pending application at a time.
+func (h *BusinessHandler) SubmitApplication(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req BusinessApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := h.db.Users().FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.IsBusinessAccount {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already a business account"})
+		return
+	}
+
+	count, err := h.db.BusinessApplications().CountDocuments(ctx, bson.M{"user_id": userID, "status": "pending"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit application"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending application"})
+		return
+	}
+
+	cursor, err := h.db.BusinessDocuments().Find(ctx, bson.M{
+		"_id":         bson.M{"$in": req.DocumentIDs},
+		"uploader_id": userID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch documents"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var documents []models.BusinessDocument
+	if err = cursor.All(ctx, &documents); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode documents"})
+		return
+	}
+
+	documentIDs := []string{}
+	hasIdentity := false
+	for _, document := range documents {
+		documentIDs = append(documentIDs, document.ID)
+		if document.Type == "identity" {
+			hasIdentity = true
+		}
+	}
+	if len(documentIDs) != len(req.DocumentIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown document in documentIds"})
+		return
+	}
+	if !hasIdentity {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "An identity document is required"})
+		return
+	}
+
+	now := time.Now()
+	application := models.BusinessApplication{
+		ID:                 primitive.NewObjectID().Hex(),
+		UserID:             userID,
+		BusinessName:       strings.TrimSpace(req.BusinessName),
+		RegistrationNumber: strings.TrimSpace(req.RegistrationNumber),
+		Country:            strings.ToUpper(req.Country),
+		Address:            req.Address,
+		Website:            req.Website,
+		ContactEmail:       req.ContactEmail,
+		Phone:              req.Phone,
+		Description:        req.Description,
+		DocumentIDs:        documentIDs,
+		Status:             "pending",
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	if _, err := h.db.BusinessApplications().InsertOne(ctx, application); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit application"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, application)
+}
+
+// GetMyApplications lists the caller's applications, newest first.
+func (h *BusinessHandler) GetMyApplications(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := h.db.BusinessApplications().Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applications"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	applications := []models.BusinessApplication{}
+	if err = cursor.All(ctx, &applications); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode applications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, applications)
+}
+
+// WithdrawApplication cancels the caller's pending application.
+func (h *BusinessHandler) WithdrawApplication(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.db.BusinessApplications().UpdateOne(
+		ctx,
+		bson.M{"_id": c.Param("id"), "user_id": userID, "status": "pending"},
+		bson.M{"$set": bson.M{"status": "withdrawn", "updated_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to withdraw application"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found or no longer pending"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Application withdrawn"})
+}
+
+// BusinessApplicationDetail is an application with what an admin needs to
+// review it.
+type BusinessApplicationDetail struct {
+	models.BusinessApplication
+	Applicant *models.UserSummary       `json:"applicant,omitempty"`
+	Documents []models.BusinessDocument `json:"documents"`
+}
+
+// GetApplications is the admin review queue, oldest first. It defaults to
+// pending applications.
+func (h *BusinessHandler) GetApplications(c *gin.Context) {
+	page, perPage := parsePagination(c)
+	filter := bson.M{"status": c.DefaultQuery("status", "pending")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := h.db.BusinessApplications().CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applications"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetSkip(int64((page - 1) * perPage)).
+		SetLimit(int64(perPage))
+
+	cursor, err := h.db.BusinessApplications().Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch applications"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	applications := []models.BusinessApplication{}
+	if err = cursor.All(ctx, &applications); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode applications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       applications,
+		Pagination: newPagination(page, perPage, total),
+	})
+}
+
+func (h *BusinessHandler) GetApplication(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var application models.BusinessApplication
+	err := h.db.BusinessApplications().FindOne(ctx, bson.M{"_id": c.Param("id")}).Decode(&application)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found"})
+		return
+	}
+
+	detail := BusinessApplicationDetail{BusinessApplication: application, Documents: []models.BusinessDocument{}}
+
+	profiles, err := findUserSummaries(ctx, h.db, []string{application.UserID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch application"})
+		return
+	}
+	if profile, ok := profiles[application.UserID]; ok {
+		detail.Applicant = &profile
+	}
+
+	cursor, err := h.db.BusinessDocuments().Find(ctx, bson.M{"_id": bson.M{"$in": application.DocumentIDs}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch documents"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &detail.Documents); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode documents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// ApproveApplication makes the applicant a verified business account and
+// starts their storefront from the application details.
+func (h *BusinessHandler) ApproveApplication(c *gin.Context) {
+	adminID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	application, entry, ok := h.reviewApplication(ctx, c, "approve_business", nil, bson.M{
+		"status":      "approved",
+		"reviewed_by": adminID,
+		"reviewed_at": now,
+		"updated_at":  now,
+	})
+	if !ok {
+		return
+	}
+
+	storefront := models.Storefront{
+		Name:         application.BusinessName,
+		ContactEmail: application.ContactEmail,
+	}
+	if application.Website != "" {
+		storefront.Links = []string{application.Website}
+	}
+
+	// Keep a storefront the user set up under a previous approval
+	_, err := h.db.Users().UpdateOne(ctx, bson.M{"_id": application.UserID}, []bson.M{
+		{"$set": bson.M{
+			"is_business_account": true,
+			"is_verified":         true,
+			"storefront":          bson.M{"$ifNull": []interface{}{"$storefront", storefront}},
+			"updated_at":          now,
+		}},
+	})
+	finishAudit(h.db, entry, err, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update account"})
+		return
+	}
+
+	h.hub.Publish("business_application", map[string]interface{}{
+		"applicationId": application.ID,
+		"status":        "approved",
+	}, application.UserID)
+
+	c.JSON(http.StatusOK, application)
+}
+
+// RejectApplication turns down an application with a reason the applicant
+// can see.
+func (h *BusinessHandler) RejectApplication(c *gin.Context) {
+	adminID := c.GetString("userID")
+
+	var req struct {
+		Reason string `json:"reason" binding:"required,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	details := map[string]interface{}{"reason": req.Reason}
+	application, entry, ok := h.reviewApplication(ctx, c, "reject_business", details, bson.M{
+		"status":           "rejected",
+		"rejection_reason": req.Reason,
+		"reviewed_by":      adminID,
+		"reviewed_at":      now,
+		"updated_at":       now,
+	})
+	if !ok {
+		return
+	}
+	finishAudit(h.db, entry, nil, nil)
+
+	h.hub.Publish("business_application", map[string]interface{}{
+		"applicationId": application.ID,
+		"status":        "rejected",
+		"reason":        req.Reason,
+	}, application.UserID)
+
+	c.JSON(http.StatusOK, application)
+}
+
+// reviewApplication sets the review outcome on a pending application,
+// recording it in the audit log first as auditAction with the given details.
+// The caller finishes the audit entry. It writes the error response itself.
+func (h *BusinessHandler) reviewApplication(ctx context.Context, c *gin.Context, auditAction string, details map[string]interface{}, set bson.M) (*models.BusinessApplication, *models.AuditLogEntry, bool) {
+	filter := bson.M{"_id": c.Param("id"), "status": "pending"}
+
+	var application models.BusinessApplication
+	err := h.db.BusinessApplications().FindOne(ctx, filter).Decode(&application)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found or already reviewed"})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review application"})
+		return nil, nil, false
+	}
+
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["applicationId"] = application.ID
+	entry, ok := beginAudit(ctx, c, h.db, auditAction, "user", application.UserID, details)
+	if !ok {
+		return nil, nil, false
+	}
+
+	err = h.db.BusinessApplications().FindOneAndUpdate(
+		ctx,
+		filter,
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&application)
+	if err != nil {
+		finishAudit(h.db, entry, err, nil)
+	}
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Application not found or already reviewed"})
+		return nil, nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review application"})
+		return nil, nil, false
+	}
+
+	return &application, entry, true
+}
+
+// UpdateStorefront replaces the caller's storefront. Business accounts only.
+func (h *BusinessHandler) UpdateStorefront(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req struct {
+		Name           string   `json:"name" binding:"required,max=100"`
+		Tagline        string   `json:"tagline" binding:"max=200"`
+		BannerImage    string   `json:"bannerImage" binding:"omitempty,url"`
+		ContactEmail   string   `json:"contactEmail" binding:"omitempty,email"`
+		ShippingPolicy string   `json:"shippingPolicy" binding:"max=5000"`
+		ReturnPolicy   string   `json:"returnPolicy" binding:"max=5000"`
+		Links          []string `json:"links" binding:"max=5,dive,url"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	storefront := models.Storefront{
+		Name:           strings.TrimSpace(req.Name),
+		Tagline:        req.Tagline,
+		BannerImage:    req.BannerImage,
+		ContactEmail:   req.ContactEmail,
+		ShippingPolicy: req.ShippingPolicy,
+		ReturnPolicy:   req.ReturnPolicy,
+		Links:          req.Links,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := h.db.Users().UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"storefront": storefront, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update storefront"})
+		return
+	}
+
+	c.JSON(http.StatusOK, storefront)
+}
+
+// SalesDay is one day of a business account's sales.
+type SalesDay struct {
+	Date    string  `json:"date"` // YYYY-MM-DD, UTC
+	Sales   int     `json:"sales"`
+	Revenue float64 `json:"revenue"`
+}
+
+// SalesAnalytics summarises a business account's completed sales.
+type SalesAnalytics struct {
+	Days              int        `json:"days"`
+	Sales             int        `json:"sales"`
+	Revenue           float64    `json:"revenue"`
+	AverageOrderValue float64    `json:"averageOrderValue"`
+	UniqueBuyers      int        `json:"uniqueBuyers"`
+	PendingSales      int64      `json:"pendingSales"`
+	Daily             []SalesDay `json:"daily"`
+}
+
+// GetSalesAnalytics reports the caller's completed sales over the last
+// days days (default 30, max 365). Business accounts only.
+func (h *BusinessHandler) GetSalesAnalytics(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+
+	cursor, err := h.db.Transactions().Find(ctx, bson.M{
+		"seller_id":  userID,
+		"status":     "completed",
+		"created_at": bson.M{"$gte": since},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sales"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var transactions []models.Transaction
+	if err = cursor.All(ctx, &transactions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode sales"})
+		return
+	}
+
+	analytics := SalesAnalytics{Days: days, Daily: make([]SalesDay, days)}
+	for i := range analytics.Daily {
+		analytics.Daily[i].Date = since.AddDate(0, 0, i).Format("2006-01-02")
+	}
+
+	buyers := map[string]bool{}
+	for _, transaction := range transactions {
+		analytics.Sales++
+		analytics.Revenue += transaction.Amount
+		buyers[transaction.BuyerID] = true
+
+		day := int(transaction.CreatedAt.UTC().Sub(since).Hours() / 24)
+		if day >= 0 && day < days {
+			analytics.Daily[day].Sales++
+			analytics.Daily[day].Revenue += transaction.Amount
+		}
+	}
+
+	analytics.UniqueBuyers = len(buyers)
+	if analytics.Sales > 0 {
+		analytics.AverageOrderValue = analytics.Revenue / float64(analytics.Sales)
+	}
+
+	analytics.PendingSales, err = h.db.Transactions().CountDocuments(ctx, bson.M{
+		"seller_id": userID,
+		"status":    "pending",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sales"})
+		return
+	}
+
+	c.JSON(http.StatusOK, analytics)
+}
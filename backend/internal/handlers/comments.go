@@ -2,27 +2,53 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/reaviseapp/rv-backend/internal/database"
 	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// maxMentions is how many users one comment can mention.
+const maxMentions = 10
+
+// mentionPattern matches @username where the @ doesn't follow a character
+// that could be part of a name or email address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.@])@([A-Za-z0-9_.]{3,30})`)
+
 type CommentHandler struct {
-	db *database.Database
+	db            *database.Database
+	privacy       *services.PrivacyService
+	hub           *services.RealtimeHub
+	notifications *services.NotificationService
 }
 
-func NewCommentHandler(db *database.Database) *CommentHandler {
-	return &CommentHandler{db: db}
+func NewCommentHandler(db *database.Database, privacy *services.PrivacyService, hub *services.RealtimeHub, notifications *services.NotificationService) *CommentHandler {
+	return &CommentHandler{db: db, privacy: privacy, hub: hub, notifications: notifications}
 }
 
 type CreateCommentRequest struct {
-	Text string `json:"text" binding:"required"`
+	Text     string `json:"text" binding:"required,max=2200"`
+	ParentID string `json:"parentId"`
+}
+
+type UpdateCommentRequest struct {
+	Text string `json:"text" binding:"required,max=2200"`
+}
+
+// CommentEntry is a comment as listed to a viewer.
+type CommentEntry struct {
+	models.Comment
+	IsLiked bool `json:"isLiked"`
 }
 
 func (h *CommentHandler) CreateComment(c *gin.Context) {
@@ -46,15 +72,63 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
+	// Blocked users can't comment on each other's posts
+	post, ok := findVisiblePost(ctx, c, h.db, h.privacy, postID, userID)
+	if !ok {
+		return
+	}
+
+	if post.CommentsDisabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Comments are turned off for this post"})
+		return
+	}
+
+	parentID := ""
+	if req.ParentID != "" {
+		parent, _, ok := h.findVisibleComment(ctx, c, req.ParentID, userID)
+		if !ok {
+			return
+		}
+		if parent.PostID != postID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent comment is on another post"})
+			return
+		}
+
+		// Replies to a reply join its thread
+		parentID = parent.ID
+		if parent.ParentID != "" {
+			parentID = parent.ParentID
+		}
+	}
+
+	mentions, err := h.resolveMentions(ctx, req.Text, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	held, err := h.matchesOwnerFilters(ctx, post, userID, req.Text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+	ownerStatus := ""
+	if held {
+		ownerStatus = "held"
+	}
+
 	// Create comment
 	comment := models.Comment{
-		ID:         primitive.NewObjectID().Hex(),
-		PostID:     postID,
-		UserID:     userID,
-		Username:   user.Username,
-		UserAvatar: user.ProfilePhoto,
-		Text:       req.Text,
-		CreatedAt:  time.Now(),
+		ID:          primitive.NewObjectID().Hex(),
+		PostID:      postID,
+		ParentID:    parentID,
+		UserID:      userID,
+		Username:    user.Username,
+		UserAvatar:  user.ProfilePhoto,
+		Text:        req.Text,
+		Mentions:    mentions,
+		OwnerStatus: ownerStatus,
+		CreatedAt:   time.Now(),
 	}
 
 	_, err = h.db.Comments().InsertOne(ctx, comment)
@@ -63,25 +137,114 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
-	// Increment comments count
+	// Increment comments count, which includes replies
 	_, _ = h.db.Posts().UpdateOne(
 		ctx,
 		bson.M{"_id": postID},
 		bson.M{"$inc": bson.M{"comments_count": 1}},
 	)
 
+	if parentID != "" {
+		_, _ = h.db.Comments().UpdateOne(
+			ctx,
+			bson.M{"_id": parentID},
+			bson.M{"$inc": bson.M{"replies_count": 1}},
+		)
+	}
+
+	// Held comments notify nobody unless the owner approves them
+	if !held {
+		h.notifyComment(ctx, post, &comment)
+	}
+
 	c.JSON(http.StatusCreated, comment)
 }
 
+// GetComments lists a post's top-level comments, newest first. Replies are
+// fetched per thread with GetReplies.
 func (h *CommentHandler) GetComments(c *gin.Context) {
+	viewerID := c.GetString("userID")
 	postID := c.Param("postId")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	post, ok := findVisiblePost(ctx, c, h.db, h.privacy, postID, viewerID)
+	if !ok {
+		return
+	}
+
+	ownerStatus := c.Query("ownerStatus")
+	if ownerStatus != "" {
+		if viewerID != post.UserID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the post's owner can review its comments"})
+			return
+		}
+		if !models.CommentOwnerStatuses[ownerStatus] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid owner status: " + ownerStatus})
+			return
+		}
+
+		// Review lists every held or hidden comment, replies included
+		h.listComments(ctx, c, viewerID, post, bson.M{"post_id": postID, "owner_status": ownerStatus}, -1)
+		return
+	}
+
+	filter := bson.M{"post_id": postID, "parent_id": bson.M{"$exists": false}}
+	h.listComments(ctx, c, viewerID, post, filter, -1)
+}
 
-	cursor, err := h.db.Comments().Find(ctx, bson.M{"post_id": postID}, opts)
+// GetReplies lists the replies to a comment, oldest first.
+func (h *CommentHandler) GetReplies(c *gin.Context) {
+	viewerID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	parent, post, ok := h.findVisibleComment(ctx, c, c.Param("id"), viewerID)
+	if !ok {
+		return
+	}
+
+	h.listComments(ctx, c, viewerID, post, bson.M{"parent_id": parent.ID}, 1)
+}
+
+// listComments writes a page of the comments on post matching filter, sorted
+// by creation time in the given direction.
+func (h *CommentHandler) listComments(ctx context.Context, c *gin.Context, viewerID string, post *models.Post, filter bson.M, direction int) {
+	page, perPage := parsePagination(c)
+
+	// Hide comments from users blocked either way and suspended users
+	conditions, err := h.privacy.AuthorConditions(ctx, viewerID, "user_id")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	conditions = append(conditions, services.VisibleContentFilter("user_id", viewerID))
+
+	// Held and hidden comments are shown to their author and the post's owner
+	if viewerID != post.UserID {
+		ownerVisible := []bson.M{{"owner_status": bson.M{"$exists": false}}}
+		if viewerID != "" {
+			ownerVisible = append(ownerVisible, bson.M{"user_id": viewerID})
+		}
+		conditions = append(conditions, bson.M{"$or": ownerVisible})
+	}
+	filter["$and"] = conditions
+
+	total, err := h.db.Comments().CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: direction}, {Key: "_id", Value: direction}}).
+		SetSkip(int64((page - 1) * perPage)).
+		SetLimit(int64(perPage))
+
+	cursor, err := h.db.Comments().Find(ctx, filter, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
 		return
@@ -94,7 +257,130 @@ func (h *CommentHandler) GetComments(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, comments)
+	liked, err := h.likedCommentIDs(ctx, viewerID, comments)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+
+	entries := make([]CommentEntry, 0, len(comments))
+	for _, comment := range comments {
+		entries = append(entries, CommentEntry{Comment: comment, IsLiked: liked[comment.ID]})
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       entries,
+		Pagination: newPagination(page, perPage, total),
+	})
+}
+
+// likedCommentIDs reports which of the comments the viewer has liked.
+func (h *CommentHandler) likedCommentIDs(ctx context.Context, viewerID string, comments []models.Comment) (map[string]bool, error) {
+	liked := map[string]bool{}
+	if viewerID == "" || len(comments) == 0 {
+		return liked, nil
+	}
+
+	ids := make([]string, 0, len(comments))
+	for _, comment := range comments {
+		ids = append(ids, comment.ID)
+	}
+
+	cursor, err := h.db.CommentLikes().Find(ctx, bson.M{"user_id": viewerID, "comment_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var likes []models.CommentLike
+	if err = cursor.All(ctx, &likes); err != nil {
+		return nil, err
+	}
+
+	for _, like := range likes {
+		liked[like.CommentID] = true
+	}
+	return liked, nil
+}
+
+// UpdateComment edits a comment's text. Edited comments carry editedAt.
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	userID := c.GetString("userID")
+	commentID := c.Param("id")
+
+	var req UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	comment, post, ok := h.findVisibleComment(ctx, c, commentID, userID)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot edit other user's comment"})
+		return
+	}
+
+	mentions, err := h.resolveMentions(ctx, req.Text, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+
+	// Edits can't sneak filtered words past the owner
+	held := comment.OwnerStatus != ""
+	if !held {
+		held, err = h.matchesOwnerFilters(ctx, post, userID, req.Text)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+			return
+		}
+	}
+
+	set := bson.M{"text": req.Text, "edited_at": time.Now()}
+	unset := bson.M{}
+	if len(mentions) > 0 {
+		set["mentions"] = mentions
+	} else {
+		unset["mentions"] = ""
+	}
+	if held && comment.OwnerStatus == "" {
+		set["owner_status"] = "held"
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var updated models.Comment
+	err = h.db.Comments().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": commentID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	// Users already mentioned were notified when they were first mentioned
+	notified := map[string]bool{}
+	for _, mention := range comment.Mentions {
+		notified[mention.UserID] = true
+	}
+	if !held {
+		h.notifyMentions(ctx, post, &updated, notified)
+	}
+
+	c.JSON(http.StatusOK, updated)
 }
 
 func (h *CommentHandler) DeleteComment(c *gin.Context) {
@@ -104,7 +390,8 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Check if comment belongs to user
+	// Authors can delete their comments, and post owners any comment on
+	// their posts
 	var comment models.Comment
 	err := h.db.Comments().FindOne(ctx, bson.M{"_id": commentID}).Decode(&comment)
 	if err != nil {
@@ -113,8 +400,12 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 	}
 
 	if comment.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete other user's comment"})
-		return
+		var post models.Post
+		err := h.db.Posts().FindOne(ctx, bson.M{"_id": comment.PostID}).Decode(&post)
+		if err != nil || post.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot delete other user's comment"})
+			return
+		}
 	}
 
 	// Delete comment
@@ -129,12 +420,434 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 		return
 	}
 
+	deletedIDs := []string{commentID}
+	if comment.ParentID == "" {
+		// A thread goes with its first comment
+		replyIDs, err := h.db.Comments().Distinct(ctx, "_id", bson.M{"parent_id": commentID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete replies"})
+			return
+		}
+		if len(replyIDs) > 0 {
+			if _, err := h.db.Comments().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": replyIDs}}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete replies"})
+				return
+			}
+			for _, id := range replyIDs {
+				if id, ok := id.(string); ok {
+					deletedIDs = append(deletedIDs, id)
+				}
+			}
+		}
+	} else {
+		_, _ = h.db.Comments().UpdateOne(
+			ctx,
+			bson.M{"_id": comment.ParentID},
+			bson.M{"$inc": bson.M{"replies_count": -1}},
+		)
+	}
+
+	_, _ = h.db.CommentLikes().DeleteMany(ctx, bson.M{"comment_id": bson.M{"$in": deletedIDs}})
+
 	// Decrement comments count
 	_, _ = h.db.Posts().UpdateOne(
 		ctx,
 		bson.M{"_id": comment.PostID},
-		bson.M{"$inc": bson.M{"comments_count": -1}},
+		bson.M{"$inc": bson.M{"comments_count": -len(deletedIDs)}},
 	)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
 }
+
+func (h *CommentHandler) LikeComment(c *gin.Context) {
+	userID := c.GetString("userID")
+	commentID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	comment, _, ok := h.findVisibleComment(ctx, c, commentID, userID)
+	if !ok {
+		return
+	}
+
+	like := models.CommentLike{
+		ID:        primitive.NewObjectID().Hex(),
+		CommentID: commentID,
+		PostID:    comment.PostID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := h.db.CommentLikes().InsertOne(ctx, like)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Comment already liked"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to like comment"})
+		return
+	}
+
+	_, err = h.db.Comments().UpdateOne(
+		ctx,
+		bson.M{"_id": commentID},
+		bson.M{"$inc": bson.M{"likes_count": 1}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update likes count"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment liked successfully"})
+}
+
+func (h *CommentHandler) UnlikeComment(c *gin.Context) {
+	userID := c.GetString("userID")
+	commentID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.db.CommentLikes().DeleteOne(ctx, bson.M{"comment_id": commentID, "user_id": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlike comment"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not liked"})
+		return
+	}
+
+	_, err = h.db.Comments().UpdateOne(
+		ctx,
+		bson.M{"_id": commentID},
+		bson.M{"$inc": bson.M{"likes_count": -1}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update likes count"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment unliked successfully"})
+}
+
+// HideComment hides a comment on the caller's post from everyone but its
+// author.
+func (h *CommentHandler) HideComment(c *gin.Context) {
+	h.setOwnerStatus(c, "hidden")
+}
+
+// UnhideComment shows a hidden comment again, or approves a held one.
+func (h *CommentHandler) UnhideComment(c *gin.Context) {
+	h.setOwnerStatus(c, "")
+}
+
+func (h *CommentHandler) setOwnerStatus(c *gin.Context, status string) {
+	userID := c.GetString("userID")
+	commentID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	comment, post, ok := h.findVisibleComment(ctx, c, commentID, userID)
+	if !ok {
+		return
+	}
+
+	if post.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the post's owner can moderate its comments"})
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"owner_status": status}}
+	if status == "" {
+		update = bson.M{"$unset": bson.M{"owner_status": ""}}
+	}
+
+	var updated models.Comment
+	err := h.db.Comments().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": commentID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+
+	// Held comments notified nobody when they were made
+	if comment.OwnerStatus == "held" && status == "" {
+		h.notifyComment(ctx, post, &updated)
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// GetCommentFilters returns the keywords that hold comments on the caller's
+// posts for review.
+func (h *CommentHandler) GetCommentFilters(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := h.db.Users().FindOne(
+		ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"comment_filters": 1}),
+	).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	keywords := user.CommentFilters
+	if keywords == nil {
+		keywords = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"keywords": keywords})
+}
+
+// UpdateCommentFilters replaces the caller's comment filter keywords. They
+// apply to comments made from then on.
+func (h *CommentHandler) UpdateCommentFilters(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req struct {
+		Keywords []string `json:"keywords" binding:"dive,max=50"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Keywords) > models.MaxCommentFilters {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d keywords can be filtered", models.MaxCommentFilters)})
+		return
+	}
+
+	keywords := []string{}
+	seen := map[string]bool{}
+	for _, keyword := range req.Keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" || seen[keyword] {
+			continue
+		}
+		seen[keyword] = true
+		keywords = append(keywords, keyword)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"comment_filters": keywords, "updated_at": time.Now()}}
+	if len(keywords) == 0 {
+		update = bson.M{
+			"$set":   bson.M{"updated_at": time.Now()},
+			"$unset": bson.M{"comment_filters": ""},
+		}
+	}
+
+	result, err := h.db.Users().UpdateOne(ctx, bson.M{"_id": userID}, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment filters"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keywords": keywords})
+}
+
+// matchesOwnerFilters reports whether a comment by authorID should be held
+// because its text contains one of the post owner's filter keywords. The
+// owner's own comments are never held.
+func (h *CommentHandler) matchesOwnerFilters(ctx context.Context, post *models.Post, authorID, text string) (bool, error) {
+	if authorID == post.UserID {
+		return false, nil
+	}
+
+	var owner models.User
+	err := h.db.Users().FindOne(
+		ctx,
+		bson.M{"_id": post.UserID},
+		options.FindOne().SetProjection(bson.M{"comment_filters": 1}),
+	).Decode(&owner)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	text = strings.ToLower(text)
+	for _, keyword := range owner.CommentFilters {
+		if strings.Contains(text, keyword) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findVisibleComment loads a comment the viewer is allowed to see, and the
+// post it is on. It writes the error response itself.
+func (h *CommentHandler) findVisibleComment(ctx context.Context, c *gin.Context, commentID, viewerID string) (*models.Comment, *models.Post, bool) {
+	var comment models.Comment
+	err := h.db.Comments().FindOne(ctx, bson.M{"_id": commentID}).Decode(&comment)
+	if err != nil || !services.IsContentVisible(comment.ModerationStatus, comment.UserID, viewerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, nil, false
+	}
+
+	blocked, err := h.privacy.IsBlocked(ctx, viewerID, comment.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comment"})
+		return nil, nil, false
+	}
+	if blocked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, nil, false
+	}
+
+	post, ok := findVisiblePost(ctx, c, h.db, h.privacy, comment.PostID, viewerID)
+	if !ok {
+		return nil, nil, false
+	}
+
+	// Held and hidden comments are between their author and the post's owner
+	if comment.OwnerStatus != "" && viewerID != comment.UserID && viewerID != post.UserID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return nil, nil, false
+	}
+
+	return &comment, post, true
+}
+
+// resolveMentions links the @usernames in text to users, in the order they
+// first appear. Unknown names and users blocked either way by the author are
+// left as plain text.
+func (h *CommentHandler) resolveMentions(ctx context.Context, text, authorID string) ([]models.CommentMention, error) {
+	var keys []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		// Usernames can't end with a period, so one is punctuation
+		key := models.UsernameKey(strings.TrimRight(match[1], "."))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+		if len(keys) == maxMentions {
+			break
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	cursor, err := h.db.Users().Find(
+		ctx,
+		bson.M{"username_key": bson.M{"$in": keys}},
+		options.Find().SetProjection(bson.M{"_id": 1, "username": 1, "username_key": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	blockedIDs, err := h.privacy.BlockedUserIDs(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+	blocked := map[string]bool{}
+	for _, id := range blockedIDs {
+		blocked[id] = true
+	}
+
+	byKey := map[string]models.User{}
+	for _, user := range users {
+		if !blocked[user.ID] {
+			byKey[user.UsernameKey] = user
+		}
+	}
+
+	var mentions []models.CommentMention
+	for _, key := range keys {
+		if user, ok := byKey[key]; ok {
+			mentions = append(mentions, models.CommentMention{UserID: user.ID, Username: user.Username})
+		}
+	}
+	return mentions, nil
+}
+
+// notifyMentions tells the users mentioned in a comment, other than its
+// author and those in skip, about it. Users who can't see the post aren't
+// told.
+func (h *CommentHandler) notifyMentions(ctx context.Context, post *models.Post, comment *models.Comment, skip map[string]bool) {
+	for _, mention := range comment.Mentions {
+		if mention.UserID == comment.UserID || skip[mention.UserID] {
+			continue
+		}
+
+		visible, err := h.privacy.CanView(ctx, mention.UserID, post.UserID)
+		if err != nil || !visible {
+			continue
+		}
+
+		h.notifications.Notify(ctx, comment.UserID, models.Notification{
+			UserID:     mention.UserID,
+			Type:       "mention",
+			TargetType: "comment",
+			TargetID:   comment.ID,
+			PostID:     comment.PostID,
+		})
+	}
+}
+
+// notifyComment tells the post's owner about a new comment, or the parent
+// comment's author about a new reply, and notifies the users it mentions.
+func (h *CommentHandler) notifyComment(ctx context.Context, post *models.Post, comment *models.Comment) {
+	parentAuthorID := ""
+	if comment.ParentID != "" {
+		var parent models.Comment
+		err := h.db.Comments().FindOne(
+			ctx,
+			bson.M{"_id": comment.ParentID},
+			options.FindOne().SetProjection(bson.M{"user_id": 1}),
+		).Decode(&parent)
+		if err == nil {
+			parentAuthorID = parent.UserID
+			h.notifications.Notify(ctx, comment.UserID, models.Notification{
+				UserID:     parent.UserID,
+				Type:       "reply",
+				TargetType: "comment",
+				TargetID:   parent.ID,
+				PostID:     post.ID,
+				GroupKey:   "reply:" + parent.ID,
+			})
+		}
+	}
+
+	// Owners replied to on their own post already heard about it
+	if parentAuthorID != post.UserID {
+		h.notifications.Notify(ctx, comment.UserID, models.Notification{
+			UserID:     post.UserID,
+			Type:       "comment",
+			TargetType: "post",
+			TargetID:   post.ID,
+			GroupKey:   "comment:" + post.ID,
+		})
+	}
+
+	h.notifyMentions(ctx, post, comment, nil)
+}
@@ -0,0 +1,732 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ParticipantRequest struct {
+	UserID string `json:"userId" binding:"required"`
+	Role   string `json:"role"`
+}
+
+type CreateConversationRequest struct {
+	Title        string               `json:"title" binding:"required"`
+	Participants []ParticipantRequest `json:"participants" binding:"required,min=1,dive"`
+}
+
+// CreateConversation starts a group conversation owned by the caller.
+func (h *MessageHandler) CreateConversation(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req CreateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	participants := []models.ConversationParticipant{
+		{UserID: userID, Role: "owner", JoinedAt: now},
+	}
+	seen := map[string]bool{userID: true}
+	var participantIDs []string
+
+	for _, p := range req.Participants {
+		if seen[p.UserID] {
+			continue
+		}
+		seen[p.UserID] = true
+
+		role := p.Role
+		if role == "" {
+			role = "member"
+		}
+		if !models.ConversationRoles[role] || role == "owner" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant role: " + role})
+			return
+		}
+
+		participants = append(participants, models.ConversationParticipant{
+			UserID:   p.UserID,
+			Role:     role,
+			JoinedAt: now,
+		})
+		participantIDs = append(participantIDs, p.UserID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Every participant must exist
+	count, err := h.db.Users().CountDocuments(ctx, bson.M{"_id": bson.M{"$in": participantIDs}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify participants"})
+		return
+	}
+	if int(count) != len(participantIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One or more participants do not exist"})
+		return
+	}
+
+	for i := range participants[1:] {
+		participant := &participants[i+1]
+		request, ok := h.checkCanAdd(ctx, c, userID, participant.UserID)
+		if !ok {
+			return
+		}
+		participant.Requested = request
+	}
+
+	conversation := models.Conversation{
+		ID:            primitive.NewObjectID().Hex(),
+		Type:          "group",
+		Title:         req.Title,
+		Participants:  participants,
+		LastMessageAt: now,
+		CreatedBy:     userID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	_, err = h.db.Conversations().InsertOne(ctx, conversation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create conversation"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, conversation)
+}
+
+func (h *MessageHandler) GetConversation(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+func (h *MessageHandler) UpdateConversation(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req struct {
+		Title string `json:"title" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	if !h.requireGroupOwner(c, conversation, userID) {
+		return
+	}
+
+	_, err := h.db.Conversations().UpdateOne(ctx, bson.M{"_id": conversation.ID}, bson.M{
+		"$set": bson.M{
+			"title":      req.Title,
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation updated successfully"})
+}
+
+func (h *MessageHandler) AddParticipant(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req ParticipantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role := req.Role
+	if role == "" {
+		role = "member"
+	}
+	if !models.ConversationRoles[role] || role == "owner" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant role: " + role})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	if !h.requireGroupOwner(c, conversation, userID) {
+		return
+	}
+
+	if conversation.Participant(req.UserID) != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a participant"})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Users().FindOne(ctx, bson.M{"_id": req.UserID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	request, ok := h.checkCanAdd(ctx, c, userID, req.UserID)
+	if !ok {
+		return
+	}
+
+	participant := models.ConversationParticipant{
+		UserID:    req.UserID,
+		Role:      role,
+		Requested: request,
+		JoinedAt:  time.Now(),
+	}
+
+	_, err := h.db.Conversations().UpdateOne(
+		ctx,
+		bson.M{"_id": conversation.ID, "participants.user_id": bson.M{"$ne": req.UserID}},
+		bson.M{
+			"$push": bson.M{"participants": participant},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add participant"})
+		return
+	}
+
+	c.JSON(http.StatusOK, participant)
+}
+
+// RemoveParticipant lets the owner remove anyone, and any participant leave.
+func (h *MessageHandler) RemoveParticipant(c *gin.Context) {
+	userID := c.GetString("userID")
+	targetID := c.Param("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	if conversation.Type != "group" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot change participants of a direct conversation"})
+		return
+	}
+
+	target := conversation.Participant(targetID)
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not a participant"})
+		return
+	}
+
+	if targetID != userID && conversation.Participant(userID).Role != "owner" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can remove participants"})
+		return
+	}
+
+	if target.Role == "owner" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The owner cannot leave the conversation"})
+		return
+	}
+
+	_, err := h.db.Conversations().UpdateOne(ctx, bson.M{"_id": conversation.ID}, bson.M{
+		"$pull": bson.M{"participants": bson.M{"user_id": targetID}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove participant"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Participant removed successfully"})
+}
+
+func (h *MessageHandler) GetConversationMessages(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	messages, err := h.findMessages(ctx, conversation.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *MessageHandler) SendConversationMessage(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req MessageContent
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	if !h.checkNotBlocked(ctx, c, conversation, userID) {
+		return
+	}
+
+	message, ok := h.buildMessage(ctx, c, userID, req)
+	if !ok {
+		return
+	}
+
+	if err := h.postMessage(ctx, conversation, message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
+
+type SendOfferRequest struct {
+	PostID string  `json:"postId" binding:"required"`
+	Price  float64 `json:"price" binding:"required,gt=0"`
+	Note   string  `json:"note"`
+	// BuyerID names who the offer is for. It is required in group
+	// conversations and defaults to the other participant in direct ones.
+	BuyerID string `json:"buyerId"`
+}
+
+// SendOffer posts an offer card for one of the sender's posts, addressed to
+// one other participant.
+func (h *MessageHandler) SendOffer(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req SendOfferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	if !h.checkNotBlocked(ctx, c, conversation, userID) {
+		return
+	}
+
+	buyerID := req.BuyerID
+	if buyerID == "" && conversation.Type == "direct" {
+		for _, id := range conversation.ParticipantIDs() {
+			if id != userID {
+				buyerID = id
+			}
+		}
+	}
+	if buyerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Buyer is required in group conversations"})
+		return
+	}
+	if buyerID == userID || conversation.Participant(buyerID) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Buyer must be another participant of the conversation"})
+		return
+	}
+
+	// Only the seller of a post can make an offer for it
+	var post models.Post
+	err := h.db.Posts().FindOne(ctx, bson.M{"_id": req.PostID, "user_id": userID, "deleted_at": bson.M{"$exists": false}}).Decode(&post)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found or you don't own it"})
+		return
+	}
+
+	message := &models.Message{
+		SenderID:   userID,
+		Type:       "offer",
+		Text:       req.Note,
+		SharedPost: sharedPostFrom(&post),
+		Offer: &models.Offer{
+			PostID:   post.ID,
+			SellerID: userID,
+			BuyerID:  buyerID,
+			Price:    req.Price,
+			Note:     req.Note,
+			Status:   "pending",
+		},
+	}
+
+	if err := h.postMessage(ctx, conversation, message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send offer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
+
+type RespondToOfferRequest struct {
+	// PaymentMethod is required when accepting.
+	PaymentMethod string `json:"paymentMethod" binding:"omitempty,oneof=stripe paypal"`
+}
+
+// AcceptOffer creates a transaction for the offer on behalf of its buyer.
+func (h *MessageHandler) AcceptOffer(c *gin.Context) {
+	h.respondToOffer(c, "accepted")
+}
+
+// DeclineOffer lets the offer's buyer turn it down.
+func (h *MessageHandler) DeclineOffer(c *gin.Context) {
+	h.respondToOffer(c, "declined")
+}
+
+// WithdrawOffer lets the seller retract a pending offer.
+func (h *MessageHandler) WithdrawOffer(c *gin.Context) {
+	h.respondToOffer(c, "withdrawn")
+}
+
+func (h *MessageHandler) respondToOffer(c *gin.Context, status string) {
+	userID := c.GetString("userID")
+	messageID := c.Param("messageId")
+
+	var req RespondToOfferRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status == "accepted" && req.PaymentMethod == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment method is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	var message models.Message
+	err := h.db.Messages().FindOne(ctx, bson.M{
+		"_id":             messageID,
+		"conversation_id": conversation.ID,
+		"type":            "offer",
+	}).Decode(&message)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+		return
+	}
+
+	if status == "withdrawn" && message.Offer.SellerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the seller can withdraw an offer"})
+		return
+	}
+	if status != "withdrawn" && message.Offer.BuyerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the buyer the offer was made to can respond to it"})
+		return
+	}
+
+	if message.Offer.Status != "pending" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Offer is no longer pending"})
+		return
+	}
+
+	// The post may have been deleted or taken down since the offer was made
+	if status == "accepted" {
+		if _, ok := findSellablePost(ctx, c, h.db, message.Offer.PostID); !ok {
+			return
+		}
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"offer.status":       status,
+		"offer.responded_by": userID,
+		"offer.responded_at": now,
+	}
+
+	var transaction *models.Transaction
+	if status == "accepted" {
+		transaction = &models.Transaction{
+			ID:             primitive.NewObjectID().Hex(),
+			BuyerID:        userID,
+			SellerID:       message.Offer.SellerID,
+			PostID:         message.Offer.PostID,
+			Amount:         message.Offer.Price,
+			Status:         "pending",
+			PaymentMethod:  req.PaymentMethod,
+			OfferMessageID: message.ID,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if message.SharedPost != nil {
+			transaction.PostRevision = message.SharedPost.Revision
+		}
+		set["offer.transaction_id"] = transaction.ID
+	}
+
+	// Claim the offer first so it cannot be accepted twice
+	result, err := h.db.Messages().UpdateOne(
+		ctx,
+		bson.M{"_id": message.ID, "offer.status": "pending"},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update offer"})
+		return
+	}
+
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Offer is no longer pending"})
+		return
+	}
+
+	if transaction != nil {
+		_, err = h.db.Transactions().InsertOne(ctx, transaction)
+		if err != nil {
+			h.reopenOffer(message.ID, transaction.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
+			return
+		}
+
+		notifyOrder(ctx, h.notifications, userID, transaction.SellerID, transaction)
+	}
+
+	message.Offer.Status = status
+	message.Offer.RespondedBy = userID
+	message.Offer.RespondedAt = &now
+	if transaction != nil {
+		message.Offer.TransactionID = transaction.ID
+	}
+
+	h.hub.Publish("offer", message, conversation.ParticipantIDs()...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"offer":       message.Offer,
+		"transaction": transaction,
+	})
+}
+
+// reopenOffer returns an accepted offer to pending after its transaction
+// failed to be created, so the buyer can accept it again. It has its own
+// timeout, as the insert may have failed on the request's.
+func (h *MessageHandler) reopenOffer(messageID, transactionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := h.db.Messages().UpdateOne(
+		ctx,
+		bson.M{"_id": messageID, "offer.transaction_id": transactionID},
+		bson.M{
+			"$set":   bson.M{"offer.status": "pending"},
+			"$unset": bson.M{"offer.responded_by": "", "offer.responded_at": "", "offer.transaction_id": ""},
+		},
+	)
+	if err != nil {
+		log.Printf("Failed to reopen offer %s after its transaction failed: %v", messageID, err)
+	}
+}
+
+// AcceptMessageRequest moves a message request into the caller's inbox.
+func (h *MessageHandler) AcceptMessageRequest(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadMessageRequest(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	update := bson.M{
+		"$unset": bson.M{"requested_by": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	}
+	filter := bson.M{"_id": conversation.ID}
+	if conversation.Type == "group" {
+		filter["participants.user_id"] = userID
+		update["$unset"] = bson.M{"participants.$.requested": ""}
+	}
+
+	_, err := h.db.Conversations().UpdateOne(ctx, filter, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept message request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message request accepted"})
+}
+
+// DeclineMessageRequest deletes a message request and its messages. The
+// sender can start a new request later unless they are blocked. Declining a
+// group leaves it instead.
+func (h *MessageHandler) DeclineMessageRequest(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadMessageRequest(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	if conversation.Type == "group" {
+		_, err := h.db.Conversations().UpdateOne(ctx, bson.M{"_id": conversation.ID}, bson.M{
+			"$pull": bson.M{"participants": bson.M{"user_id": userID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline message request"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Message request declined"})
+		return
+	}
+
+	_, err := h.db.Conversations().DeleteOne(ctx, bson.M{"_id": conversation.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline message request"})
+		return
+	}
+
+	_, _ = h.db.Messages().DeleteMany(ctx, bson.M{"conversation_id": conversation.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message request declined"})
+}
+
+func (h *MessageHandler) MarkConversationRead(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return
+	}
+
+	readAt, err := h.markRead(ctx, conversation, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark conversation as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"readAt": readAt})
+}
+
+// loadConversation fetches the conversation in the :id param and writes the
+// error response itself when it is missing or the user is not a participant.
+func (h *MessageHandler) loadConversation(ctx context.Context, c *gin.Context, userID string) (*models.Conversation, bool) {
+	var conversation models.Conversation
+	err := h.db.Conversations().FindOne(ctx, bson.M{"_id": c.Param("id")}).Decode(&conversation)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return nil, false
+	}
+
+	if conversation.Participant(userID) == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a participant in this conversation"})
+		return nil, false
+	}
+
+	return &conversation, true
+}
+
+// loadMessageRequest loads a pending message request addressed to userID.
+func (h *MessageHandler) loadMessageRequest(ctx context.Context, c *gin.Context, userID string) (*models.Conversation, bool) {
+	conversation, ok := h.loadConversation(ctx, c, userID)
+	if !ok {
+		return nil, false
+	}
+
+	if !conversation.IsRequestFor(userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message request not found"})
+		return nil, false
+	}
+
+	return conversation, true
+}
+
+// checkCanAdd applies the participant's DM settings to being added to a
+// group by the owner, as if the owner messaged them. It reports whether the
+// group goes to the participant's message requests, and writes the error
+// response itself.
+func (h *MessageHandler) checkCanAdd(ctx context.Context, c *gin.Context, ownerID, participantID string) (bool, bool) {
+	request, err := h.privacy.CheckNewConversation(ctx, ownerID, participantID)
+	switch err {
+	case nil:
+		return request, true
+	case services.ErrUserNotFound:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One or more participants do not exist"})
+	case services.ErrUserBlocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot add a user you have blocked or who blocked you"})
+	case services.ErrMessagesRestricted:
+		c.JSON(http.StatusForbidden, gin.H{"error": "A participant doesn't accept messages from you"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify participants"})
+	}
+
+	return false, false
+}
+
+func (h *MessageHandler) requireGroupOwner(c *gin.Context, conversation *models.Conversation, userID string) bool {
+	if conversation.Type != "group" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Direct conversations cannot be changed"})
+		return false
+	}
+
+	if conversation.Participant(userID).Role != "owner" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change this conversation"})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// openNoticeStatuses are the notices a moderator can still reject.
+var openNoticeStatuses = []string{"disabled", "counter_noticed", "upheld"}
+
+// activeNoticeStatuses are the notices that keep their content disabled.
+var activeNoticeStatuses = append([]string{"restoring"}, openNoticeStatuses...)
+
+const (
+	// noticeRateLimit is how many notices one address or email can file per
+	// noticeRateWindow, since anyone can file one.
+	noticeRateLimit  = 5
+	noticeRateWindow = 24 * time.Hour
+)
+
+type DMCAHandler struct {
+	db         *database.Database
+	moderation *services.ModerationService
+	copyright  *services.CopyrightService
+}
+
+func NewDMCAHandler(db *database.Database, moderation *services.ModerationService, copyright *services.CopyrightService) *DMCAHandler {
+	return &DMCAHandler{db: db, moderation: moderation, copyright: copyright}
+}
+
+// DMCANoticeRequest carries the elements a valid takedown notice must
+// include under 17 U.S.C. § 512(c)(3).
+type DMCANoticeRequest struct {
+	Name               string `json:"name" binding:"required"`
+	Email              string `json:"email" binding:"required,email"`
+	Address            string `json:"address" binding:"required"`
+	Phone              string `json:"phone"`
+	CopyrightedWork    string `json:"copyrightedWork" binding:"required,max=5000"`
+	OriginalWorkURL    string `json:"originalWorkUrl" binding:"omitempty,url"`
+	TargetType         string `json:"targetType" binding:"required,oneof=post listing"`
+	TargetID           string `json:"targetId" binding:"required"`
+	InfringingURL      string `json:"infringingUrl" binding:"required,url"`
+	GoodFaithStatement bool   `json:"goodFaithStatement"`
+	AccuracyStatement  bool   `json:"accuracyStatement"`
+	Signature          string `json:"signature" binding:"required"`
+}
+
+// FileNotice accepts a takedown notice and disables the content straight
+// away. The uploader's strike waits until the notice is upheld or the
+// counter-notice window passes. Complainants don't need an account.
+func (h *DMCAHandler) FileNotice(c *gin.Context) {
+	var req DMCANoticeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.GoodFaithStatement || !req.AccuracyStatement {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The good faith and accuracy statements are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	clientIP := c.ClientIP()
+
+	recent, err := h.db.DMCANotices().CountDocuments(ctx, bson.M{
+		"$or": []bson.M{
+			{"complainant_ip": clientIP},
+			{"complainant_email": req.Email},
+		},
+		"created_at": bson.M{"$gt": now.Add(-noticeRateWindow)},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file notice"})
+		return
+	}
+	if recent >= noticeRateLimit {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many notices filed recently; contact our copyright agent instead"})
+		return
+	}
+
+	uploaderID, err := h.moderation.TargetOwner(ctx, req.TargetType, req.TargetID)
+	if err == services.ErrTargetNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Infringing content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch infringing content"})
+		return
+	}
+
+	count, err := h.db.DMCANotices().CountDocuments(ctx, bson.M{
+		"target_type": req.TargetType,
+		"target_id":   req.TargetID,
+		"status":      bson.M{"$in": activeNoticeStatuses},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file notice"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This content is already disabled by a copyright notice"})
+		return
+	}
+
+	counterDeadline := services.AddBusinessDays(now, services.CounterNoticeWindowDays)
+	notice := models.DMCANotice{
+		ID:                 primitive.NewObjectID().Hex(),
+		ComplainantUserID:  c.GetString("userID"),
+		ComplainantName:    strings.TrimSpace(req.Name),
+		ComplainantEmail:   req.Email,
+		ComplainantAddress: req.Address,
+		ComplainantPhone:   req.Phone,
+		CopyrightedWork:    req.CopyrightedWork,
+		OriginalWorkURL:    req.OriginalWorkURL,
+		TargetType:         req.TargetType,
+		TargetID:           req.TargetID,
+		InfringingURL:      req.InfringingURL,
+		UploaderID:         uploaderID,
+		GoodFaithStatement: req.GoodFaithStatement,
+		AccuracyStatement:  req.AccuracyStatement,
+		Signature:          req.Signature,
+		Status:             "disabled",
+		CounterDeadline:    &counterDeadline,
+		ComplainantIP:      clientIP,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	if _, err := h.db.DMCANotices().InsertOne(ctx, notice); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to file notice"})
+		return
+	}
+
+	if err := h.copyright.Takedown(ctx, &notice); err != nil {
+		log.Printf("Failed to take down content for DMCA notice %s: %v", notice.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable content"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, notice)
+}
+
+// GetMyNotices lists the notices filed against the caller's content.
+func (h *DMCAHandler) GetMyNotices(c *gin.Context) {
+	userID := c.GetString("userID")
+	page, perPage := parsePagination(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	h.listNotices(ctx, c, bson.M{"uploader_id": userID}, page, perPage)
+}
+
+// GetNotice returns a notice to the uploader or the complainant.
+func (h *DMCAHandler) GetNotice(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var notice models.DMCANotice
+	err := h.db.DMCANotices().FindOne(ctx, bson.M{"_id": c.Param("id")}).Decode(&notice)
+	if err != nil || (notice.UploaderID != userID && notice.ComplainantUserID != userID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notice not found"})
+		return
+	}
+
+	response := gin.H{"notice": notice}
+
+	// Uploaders see where they stand under the repeat infringer policy
+	if notice.UploaderID == userID {
+		strikes, err := h.copyright.ActiveStrikes(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notice"})
+			return
+		}
+		response["strikes"] = strikes
+		response["strikeLimit"] = services.StrikeLimit
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DMCACounterNoticeRequest carries the elements a valid counter-notice must
+// include under 17 U.S.C. § 512(g)(3).
+type DMCACounterNoticeRequest struct {
+	Name                string `json:"name" binding:"required"`
+	Email               string `json:"email" binding:"required,email"`
+	Address             string `json:"address" binding:"required"`
+	Phone               string `json:"phone"`
+	Explanation         string `json:"explanation" binding:"required,max=5000"`
+	PerjuryStatement    bool   `json:"perjuryStatement"`
+	JurisdictionConsent bool   `json:"jurisdictionConsent"`
+	Signature           string `json:"signature" binding:"required"`
+}
+
+// FileCounterNotice lets the uploader dispute a notice. The content is
+// restored after CounterNoticeWaitDays business days unless the complainant
+// reports that they have filed suit.
+func (h *DMCAHandler) FileCounterNotice(c *gin.Context) {
+	userID := c.GetString("userID")
+	noticeID := c.Param("id")
+
+	var req DMCACounterNoticeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.PerjuryStatement || !req.JurisdictionConsent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The perjury statement and consent to jurisdiction are required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	counter := models.DMCACounterNotice{
+		Name:                strings.TrimSpace(req.Name),
+		Email:               req.Email,
+		Address:             req.Address,
+		Phone:               req.Phone,
+		Explanation:         req.Explanation,
+		PerjuryStatement:    req.PerjuryStatement,
+		JurisdictionConsent: req.JurisdictionConsent,
+		Signature:           req.Signature,
+		SubmittedAt:         now,
+	}
+	restoreAfter := services.AddBusinessDays(now, services.CounterNoticeWaitDays)
+
+	var notice models.DMCANotice
+	err := h.db.DMCANotices().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": noticeID, "uploader_id": userID, "status": "disabled"},
+		bson.M{"$set": bson.M{
+			"counter_notice": counter,
+			"restore_after":  restoreAfter,
+			"status":         "counter_noticed",
+			"updated_at":     now,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&notice)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notice not found or no longer open to a counter-notice"})
+		return
+	}
+
+	// Forward the counter-notice to a complainant with an account; others
+	// are contacted by the copyright agent at the address they gave
+	if notice.ComplainantUserID != "" {
+		h.copyright.Notify(ctx, notice.ComplainantUserID, "counter_notice", &notice, map[string]interface{}{
+			"restoreAfter":       restoreAfter,
+			"counterName":        counter.Name,
+			"counterEmail":       counter.Email,
+			"counterAddress":     counter.Address,
+			"counterPhone":       counter.Phone,
+			"counterExplanation": counter.Explanation,
+			"counterSubmittedAt": counter.SubmittedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, notice)
+}
+
+// GetNotices is the moderator view of copyright notices, newest first.
+func (h *DMCAHandler) GetNotices(c *gin.Context) {
+	page, perPage := parsePagination(c)
+
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	if uploaderID := c.Query("uploaderId"); uploaderID != "" {
+		filter["uploader_id"] = uploaderID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	h.listNotices(ctx, c, filter, page, perPage)
+}
+
+func (h *DMCAHandler) listNotices(ctx context.Context, c *gin.Context, filter bson.M, page, perPage int) {
+	total, err := h.db.DMCANotices().CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notices"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * perPage)).
+		SetLimit(int64(perPage))
+
+	cursor, err := h.db.DMCANotices().Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notices"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	notices := []models.DMCANotice{}
+	if err = cursor.All(ctx, &notices); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode notices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       notices,
+		Pagination: newPagination(page, perPage, total),
+	})
+}
+
+// RecordLawsuit marks that the complainant has sued the uploader, which
+// keeps the content disabled past the counter-notice waiting period and
+// records the uploader's strike.
+func (h *DMCAHandler) RecordLawsuit(c *gin.Context) {
+	h.resolveNotice(c, []string{"disabled", "counter_noticed"}, "upheld", "uphold_dmca_notice")
+}
+
+// RejectNotice throws out an invalid or abusive notice, restoring the
+// content and retracting the uploader's strike, if it has one.
+func (h *DMCAHandler) RejectNotice(c *gin.Context) {
+	// The notice is restoring until its content is back; Run retries it if
+	// that fails here
+	h.resolveNotice(c, openNoticeStatuses, "restoring", "reject_dmca_notice")
+}
+
+func (h *DMCAHandler) resolveNotice(c *gin.Context, fromStatuses []string, status, auditAction string) {
+	moderatorID := c.GetString("userID")
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"status":          status,
+			"resolved_by":     moderatorID,
+			"resolution_note": req.Note,
+			"updated_at":      time.Now(),
+		},
+		"$unset": bson.M{"restore_after": ""},
+	}
+
+	entry, ok := beginAudit(ctx, c, h.db, auditAction, "dmca_notice", c.Param("id"), map[string]interface{}{
+		"note": req.Note,
+	})
+	if !ok {
+		return
+	}
+
+	var notice models.DMCANotice
+	err := h.db.DMCANotices().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": c.Param("id"), "status": bson.M{"$in": fromStatuses}},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&notice)
+	if err != nil {
+		finishAudit(h.db, entry, err, nil)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notice not found or already resolved"})
+		return
+	}
+
+	finishAudit(h.db, entry, nil, map[string]interface{}{
+		"uploaderId": notice.UploaderID,
+		"targetType": notice.TargetType,
+		"targetId":   notice.TargetID,
+	})
+
+	switch status {
+	case "upheld":
+		h.copyright.Notify(ctx, notice.UploaderID, "upheld", &notice, nil)
+		if err := h.copyright.RecordStrike(ctx, &notice); err != nil {
+			log.Printf("Failed to record strike for DMCA notice %s: %v", notice.ID, err)
+		}
+	case "restoring":
+		if err := h.copyright.FinishRestore(ctx, &notice); err != nil {
+			log.Printf("Failed to restore content for DMCA notice %s: %v", notice.ID, err)
+		}
+	}
+
+	if notice.ComplainantUserID != "" {
+		event := status
+		if status == "restoring" {
+			event = "rejected"
+		}
+		h.copyright.Notify(ctx, notice.ComplainantUserID, event, &notice, nil)
+	}
+
+	c.JSON(http.StatusOK, notice)
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxFollowedByHints is how many "followed by" profiles are returned per user.
+const maxFollowedByHints = 3
+
+// FollowEntry is one user in a followers or following list. The relationship
+// fields describe the authenticated viewer and are false for anonymous
+// requests.
+type FollowEntry struct {
+	models.UserSummary
+	IsFollowing     bool                 `json:"isFollowing"`
+	FollowsYou      bool                 `json:"followsYou"`
+	IsMutual        bool                 `json:"isMutual"`
+	FollowedBy      []models.UserSummary `json:"followedBy"`
+	FollowedByCount int                  `json:"followedByCount"`
+}
+
+// GetFollowers lists the users following :id, most recent first.
+func (h *UserHandler) GetFollowers(c *gin.Context) {
+	h.listFollows(c, "followee_id", "follower_id")
+}
+
+// GetFollowing lists the users :id follows, most recent first.
+func (h *UserHandler) GetFollowing(c *gin.Context) {
+	h.listFollows(c, "follower_id", "followee_id")
+}
+
+// listFollows pages through the follows whose ownerField is the profile user
+// and returns the users in listedField.
+func (h *UserHandler) listFollows(c *gin.Context, ownerField, listedField string) {
+	viewerID := c.GetString("userID")
+	userID := c.Param("id")
+	page, perPage := parsePagination(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	count, err := h.db.Users().CountDocuments(ctx, bson.M{"_id": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	blocked, err := h.privacy.IsBlocked(ctx, viewerID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Private accounts only show their connections to approved followers
+	visible, err := h.privacy.CanView(ctx, viewerID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if !visible {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account is private"})
+		return
+	}
+
+	blockedIDs, err := h.privacy.BlockedUserIDs(ctx, viewerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follows"})
+		return
+	}
+
+	filter := bson.M{ownerField: userID}
+	if len(blockedIDs) > 0 {
+		filter[listedField] = bson.M{"$nin": blockedIDs}
+	}
+
+	total, err := h.db.Follows().CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follows"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * perPage)).
+		SetLimit(int64(perPage))
+
+	cursor, err := h.db.Follows().Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follows"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var follows []models.Follow
+	if err = cursor.All(ctx, &follows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode follows"})
+		return
+	}
+
+	var listedIDs []string
+	for _, follow := range follows {
+		if listedField == "follower_id" {
+			listedIDs = append(listedIDs, follow.FollowerID)
+		} else {
+			listedIDs = append(listedIDs, follow.FolloweeID)
+		}
+	}
+
+	entries, err := h.buildFollowEntries(ctx, viewerID, listedIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follows"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       entries,
+		Pagination: newPagination(page, perPage, total),
+	})
+}
+
+// buildFollowEntries loads the profiles of userIDs, in order, along with
+// their relationship to the viewer.
+func (h *UserHandler) buildFollowEntries(ctx context.Context, viewerID string, userIDs []string) ([]FollowEntry, error) {
+	entries := []FollowEntry{}
+	if len(userIDs) == 0 {
+		return entries, nil
+	}
+
+	profiles, err := findUserSummaries(ctx, h.db, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everyone the viewer follows, for both the following flag and the
+	// "followed by" hints
+	viewerFollowing := map[string]bool{}
+	followsViewer := map[string]bool{}
+	followedBy := map[string][]string{}
+
+	if viewerID != "" {
+		cursor, err := h.db.Follows().Find(ctx, bson.M{"follower_id": viewerID})
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		var viewerFollows []models.Follow
+		if err = cursor.All(ctx, &viewerFollows); err != nil {
+			return nil, err
+		}
+
+		var viewerFolloweeIDs []string
+		for _, follow := range viewerFollows {
+			viewerFollowing[follow.FolloweeID] = true
+			viewerFolloweeIDs = append(viewerFolloweeIDs, follow.FolloweeID)
+		}
+
+		backCursor, err := h.db.Follows().Find(ctx, bson.M{
+			"follower_id": bson.M{"$in": userIDs},
+			"followee_id": viewerID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		defer backCursor.Close(ctx)
+
+		var backFollows []models.Follow
+		if err = backCursor.All(ctx, &backFollows); err != nil {
+			return nil, err
+		}
+
+		for _, follow := range backFollows {
+			followsViewer[follow.FollowerID] = true
+		}
+
+		if len(viewerFolloweeIDs) > 0 {
+			hintCursor, err := h.db.Follows().Find(ctx, bson.M{
+				"followee_id": bson.M{"$in": userIDs},
+				"follower_id": bson.M{"$in": viewerFolloweeIDs},
+			}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+			if err != nil {
+				return nil, err
+			}
+			defer hintCursor.Close(ctx)
+
+			var hintFollows []models.Follow
+			if err = hintCursor.All(ctx, &hintFollows); err != nil {
+				return nil, err
+			}
+
+			for _, follow := range hintFollows {
+				followedBy[follow.FolloweeID] = append(followedBy[follow.FolloweeID], follow.FollowerID)
+			}
+		}
+	}
+
+	var hintIDs []string
+	for _, ids := range followedBy {
+		if len(ids) > maxFollowedByHints {
+			ids = ids[:maxFollowedByHints]
+		}
+		hintIDs = append(hintIDs, ids...)
+	}
+
+	hintProfiles, err := findUserSummaries(ctx, h.db, hintIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range userIDs {
+		profile, ok := profiles[id]
+		if !ok {
+			continue
+		}
+
+		entry := FollowEntry{
+			UserSummary:     profile,
+			IsFollowing:     viewerFollowing[id],
+			FollowsYou:      followsViewer[id],
+			IsMutual:        viewerFollowing[id] && followsViewer[id],
+			FollowedBy:      []models.UserSummary{},
+			FollowedByCount: len(followedBy[id]),
+		}
+
+		for _, hintID := range followedBy[id] {
+			if len(entry.FollowedBy) == maxFollowedByHints {
+				break
+			}
+			if hintProfile, ok := hintProfiles[hintID]; ok {
+				entry.FollowedBy = append(entry.FollowedBy, hintProfile)
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]{3,30}$`)
+
+// validateUsername checks a username is 3-30 letters, digits, underscores
+// and periods, without a period at either end or two in a row. It writes the
+// error response itself.
+func validateUsername(c *gin.Context, username string) bool {
+	if !usernamePattern.MatchString(username) ||
+		strings.HasPrefix(username, ".") ||
+		strings.HasSuffix(username, ".") ||
+		strings.Contains(username, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username must be 3-30 letters, digits, underscores or periods, and can't start or end with a period or contain two in a row"})
+		return false
+	}
+	return true
+}
+
+// findUserSummaries loads the public profiles of the given users keyed by ID.
+// Unknown IDs are simply absent from the result.
+func findUserSummaries(ctx context.Context, db *database.Database, userIDs []string) (map[string]models.UserSummary, error) {
+	summaries := map[string]models.UserSummary{}
+	if len(userIDs) == 0 {
+		return summaries, nil
+	}
+
+	cursor, err := db.Users().Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.UserSummary
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		summaries[user.ID] = user
+	}
+
+	return summaries, nil
+}
+
+// findVisiblePost loads a post the viewer is allowed to see. Deleted posts,
+// posts hidden from the viewer by privacy or moderation, and other users'
+// unpublished posts are reported as not found. It writes the error response
+// itself.
+func findVisiblePost(ctx context.Context, c *gin.Context, db *database.Database, privacy *services.PrivacyService, postID, viewerID string) (*models.Post, bool) {
+	var post models.Post
+	err := db.Posts().FindOne(ctx, bson.M{"_id": postID, "deleted_at": bson.M{"$exists": false}}).Decode(&post)
+	if err != nil || post.DMCADisabled || !services.IsContentVisible(post.ModerationStatus, post.UserID, viewerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+
+	// Unpublished posts are only shown to their author
+	if post.ProcessingStatus != "" && post.UserID != viewerID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+
+	visible, err := privacy.CanView(ctx, viewerID, post.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return nil, false
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+
+	return &post, true
+}
+
+// findSellablePost loads a post that can be minted, listed or bought: not
+// deleted, removed by moderators or a copyright notice, or still processing.
+// It writes the error response itself.
+func findSellablePost(ctx context.Context, c *gin.Context, db *database.Database, postID string) (*models.Post, bool) {
+	var post models.Post
+	err := db.Posts().FindOne(ctx, bson.M{
+		"_id":               postID,
+		"deleted_at":        bson.M{"$exists": false},
+		"moderation_status": bson.M{"$ne": "removed"},
+		"dmca_disabled":     bson.M{"$ne": true},
+		"processing_status": bson.M{"$exists": false},
+	}).Decode(&post)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch post"})
+		return nil, false
+	}
+
+	return &post, true
+}
+
+// isAdmin reports whether the user holds the admin role. It must run after
+// AuthMiddleware, which loads the user's current roles.
+func isAdmin(c *gin.Context) bool {
+	value, _ := c.Get("claims")
+	claims, ok := value.(*services.Claims)
+	return ok && claims.HasRole("admin")
+}
+
+// multipartOverhead allows for a form's boundaries, part headers and small
+// fields on top of the files it carries.
+const multipartOverhead = 1 << 20
+
+// limitUploadBody caps how much of a multipart request body is read, so an
+// oversized upload is refused instead of being spooled to disk while the form
+// is parsed. The cap is for the files; room for the form itself is added.
+func limitUploadBody(c *gin.Context, maxFileBytes int64) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFileBytes+multipartOverhead)
+}
+
+// isBodyTooLarge reports whether err came from a body cut off by
+// limitUploadBody.
+func isBodyTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxImageSize = 20 << 20  // 20MB
+	maxVideoSize = 500 << 20 // 500MB
+	// maxFilesPerUpload matches the most media a post can hold.
+	maxFilesPerUpload = 10
+	presignExpiry     = 15 * time.Minute
+)
+
+// mediaContentTypes maps the accepted content types to their media type.
+var mediaContentTypes = map[string]string{
+	"image/jpeg":      "image",
+	"image/png":       "image",
+	"image/gif":       "image",
+	"image/webp":      "image",
+	"image/heic":      "image",
+	"video/mp4":       "video",
+	"video/quicktime": "video",
+	"video/webm":      "video",
+}
+
+// maxMediaSize returns the size limit for a media type.
+func maxMediaSize(mediaType string) int64 {
+	if mediaType == "video" {
+		return maxVideoSize
+	}
+	return maxImageSize
+}
+
+// sniffMedia detects a file's content type from its first bytes. Clients'
+// declared types and file extensions are never trusted.
+func sniffMedia(r io.Reader) (contentType, mediaType string, ok bool) {
+	detected, err := mimetype.DetectReader(r)
+	if err != nil {
+		return "", "", false
+	}
+
+	contentType = strings.SplitN(detected.String(), ";", 2)[0]
+	mediaType, ok = mediaContentTypes[contentType]
+	return contentType, mediaType, ok
+}
+
+// mediaExtension is the file extension blobs of a content type are stored with.
+func mediaExtension(contentType string) string {
+	if detected := mimetype.Lookup(contentType); detected != nil {
+		return detected.Extension()
+	}
+	return ""
+}
+
+type MediaHandler struct {
+	db    *database.Database
+	store services.BlobStore
+}
+
+func NewMediaHandler(db *database.Database, store services.BlobStore) *MediaHandler {
+	return &MediaHandler{db: db, store: store}
+}
+
+// Upload stores one or more images or videos sent as multipart "file"
+// fields. Every file is checked before any is stored.
+func (h *MediaHandler) Upload(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	limitUploadBody(c, maxFilesPerUpload*maxVideoSize)
+
+	form, err := c.MultipartForm()
+	if isBodyTooLarge(err) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds the size limit"})
+		return
+	}
+	if err != nil || len(form.File["file"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one file is required"})
+		return
+	}
+
+	fileHeaders := form.File["file"]
+	if len(fileHeaders) > maxFilesPerUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many files; upload at most 10 at a time"})
+		return
+	}
+
+	type upload struct {
+		header      *multipart.FileHeader
+		contentType string
+		mediaType   string
+	}
+
+	uploads := make([]upload, 0, len(fileHeaders))
+	for _, fileHeader := range fileHeaders {
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+
+		contentType, mediaType, ok := sniffMedia(file)
+		file.Close()
+		if !ok {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fileHeader.Filename + ": only JPEG, PNG, GIF, WebP, HEIC, MP4, MOV and WebM files are supported"})
+			return
+		}
+
+		if fileHeader.Size > maxMediaSize(mediaType) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fileHeader.Filename + ": file is too large"})
+			return
+		}
+
+		uploads = append(uploads, upload{header: fileHeader, contentType: contentType, mediaType: mediaType})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	media := make([]models.Media, 0, len(uploads))
+	for _, u := range uploads {
+		file, err := u.header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+
+		id := primitive.NewObjectID().Hex()
+		key := "media/" + id + mediaExtension(u.contentType)
+
+		err = h.store.Put(ctx, key, file, u.header.Size, u.contentType)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+			return
+		}
+
+		now := time.Now()
+		item := models.Media{
+			ID:               id,
+			OwnerID:          userID,
+			Key:              key,
+			URL:              h.store.URL(key),
+			Type:             u.mediaType,
+			ContentType:      u.contentType,
+			Size:             u.header.Size,
+			Filename:         filepath.Base(u.header.Filename),
+			Status:           "uploaded",
+			ProcessingStatus: "queued",
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		}
+
+		if _, err := h.db.Media().InsertOne(ctx, item); err != nil {
+			h.store.Delete(ctx, key)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record media"})
+			return
+		}
+
+		media = append(media, item)
+	}
+
+	c.JSON(http.StatusCreated, media)
+}
+
+type PresignMediaRequest struct {
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"contentType" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1"`
+}
+
+// Presign starts a direct upload to the blob store. The client PUTs the file
+// to the returned URL and then calls Complete.
+func (h *MediaHandler) Presign(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req PresignMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mediaType, ok := mediaContentTypes[req.ContentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported content type: " + req.ContentType})
+		return
+	}
+	if req.Size > maxMediaSize(mediaType) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := primitive.NewObjectID().Hex()
+	key := "media/" + id + mediaExtension(req.ContentType)
+
+	uploadURL, err := h.store.PresignPut(ctx, key, req.ContentType, presignExpiry)
+	if err == services.ErrPresignNotSupported {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Direct uploads are not available; use POST /media"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare upload"})
+		return
+	}
+
+	now := time.Now()
+	media := models.Media{
+		ID:          id,
+		OwnerID:     userID,
+		Key:         key,
+		URL:         h.store.URL(key),
+		Type:        mediaType,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+		Filename:    filepath.Base(req.Filename),
+		Status:      "pending_upload",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if _, err := h.db.Media().InsertOne(ctx, media); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record media"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"media":     media,
+		"uploadUrl": uploadURL,
+		"method":    http.MethodPut,
+		"headers":   gin.H{"Content-Type": req.ContentType},
+		"expiresAt": now.Add(presignExpiry),
+	})
+}
+
+// Complete checks a direct upload once the client has sent the file. Files
+// that are missing, too large or not what they claimed to be are rejected
+// and deleted.
+func (h *MediaHandler) Complete(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var media models.Media
+	err := h.db.Media().FindOne(ctx, bson.M{
+		"_id":      c.Param("id"),
+		"owner_id": userID,
+		"status":   "pending_upload",
+	}).Decode(&media)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending upload not found"})
+		return
+	}
+
+	size, err := h.store.Size(ctx, media.Key)
+	if err == services.ErrBlobNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File has not been uploaded"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check upload"})
+		return
+	}
+
+	blob, err := h.store.Open(ctx, media.Key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check upload"})
+		return
+	}
+	contentType, mediaType, ok := sniffMedia(blob)
+	blob.Close()
+
+	if !ok || mediaType != media.Type || size > maxMediaSize(mediaType) {
+		h.store.Delete(ctx, media.Key)
+		h.db.Media().DeleteOne(ctx, bson.M{"_id": media.ID})
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Uploaded file is not a supported " + media.Type + " or is too large"})
+		return
+	}
+
+	media.ContentType = contentType
+	media.Size = size
+	media.Status = "uploaded"
+	media.UpdatedAt = time.Now()
+
+	media.ProcessingStatus = "queued"
+
+	_, err = h.db.Media().UpdateOne(ctx, bson.M{"_id": media.ID}, bson.M{"$set": bson.M{
+		"content_type":      media.ContentType,
+		"size":              media.Size,
+		"status":            media.Status,
+		"processing_status": media.ProcessingStatus,
+		"updated_at":        media.UpdatedAt,
+	}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, media)
+}
+
+func (h *MediaHandler) GetMedia(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var media models.Media
+	err := h.db.Media().FindOne(ctx, bson.M{"_id": c.Param("id"), "owner_id": userID}).Decode(&media)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, media)
+}
+
+// DeleteMedia deletes media that no post uses.
+func (h *MediaHandler) DeleteMedia(c *gin.Context) {
+	userID := c.GetString("userID")
+	mediaID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var media models.Media
+	err := h.db.Media().FindOne(ctx, bson.M{"_id": mediaID, "owner_id": userID}).Decode(&media)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return
+	}
+
+	count, err := h.db.Posts().CountDocuments(ctx, bson.M{"media.media_id": mediaID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete media"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Media is used by a post"})
+		return
+	}
+
+	keys := []string{media.Key}
+	for _, rendition := range media.Renditions {
+		keys = append(keys, rendition.Key)
+	}
+	if media.Poster != nil {
+		keys = append(keys, media.Poster.Key)
+	}
+	keys = append(keys, media.AssetKeys...)
+	for _, key := range keys {
+		if err := h.store.Delete(ctx, key); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
+			return
+		}
+	}
+
+	if _, err := h.db.Media().DeleteOne(ctx, bson.M{"_id": mediaID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Media deleted successfully"})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/services"
+)
+
+var (
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+	jpegHeader = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")
+	mp4Header  = []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+)
+
+func TestSniffMedia(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            []byte
+		wantContentType string
+		wantMediaType   string
+		wantOK          bool
+	}{
+		{"PNG", pngHeader, "image/png", "image", true},
+		{"JPEG", jpegHeader, "image/jpeg", "image", true},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00"), "image/gif", "image", true},
+		{"MP4", mp4Header, "video/mp4", "video", true},
+		{"PDF", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n"), "application/pdf", "", false},
+		{"HTML", []byte("<!DOCTYPE html><html></html>"), "text/html", "", false},
+		{"plain text", []byte("just some text"), "text/plain", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, mediaType, ok := sniffMedia(bytes.NewReader(tt.data))
+			if contentType != tt.wantContentType || mediaType != tt.wantMediaType || ok != tt.wantOK {
+				t.Errorf("sniffMedia = (%q, %q, %v), want (%q, %q, %v)",
+					contentType, mediaType, ok, tt.wantContentType, tt.wantMediaType, tt.wantOK)
+			}
+		})
+	}
+}
+
+// uploadRequest builds a multipart request with one "file" part per entry.
+func uploadRequest(t *testing.T, files map[string][]byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, data := range files {
+		part, err := form.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatalf("CreateFormFile: %v", err)
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/media", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestUploadRejectsBeforeStoring(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tooMany := map[string][]byte{}
+	for i := 0; i <= maxFilesPerUpload; i++ {
+		tooMany[strings.Repeat("a", i+1)+".png"] = pngHeader
+	}
+
+	tests := []struct {
+		name       string
+		files      map[string][]byte
+		wantStatus int
+	}{
+		{"no files", map[string][]byte{}, http.StatusBadRequest},
+		{"too many files", tooMany, http.StatusBadRequest},
+		{"one unsupported file among valid ones", map[string][]byte{
+			"photo.png":  pngHeader,
+			"clip.mp4":   mp4Header,
+			"manual.pdf": []byte("%PDF-1.7\n"),
+		}, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			// No database: every case must be refused before it is needed
+			h := NewMediaHandler(nil, services.NewFSBlobStore(dir, "/uploads"))
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = uploadRequest(t, tt.files)
+			c.Set("userID", "user1")
+
+			h.Upload(c)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			stored, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("ReadDir: %v", err)
+			}
+			if len(stored) != 0 {
+				t.Errorf("stored %d entries, want none", len(stored))
+			}
+		})
+	}
+}
+
+func TestLimitUploadBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const maxFileBytes = 1 << 10
+
+	tests := []struct {
+		name         string
+		size         int
+		wantTooLarge bool
+	}{
+		{"within the limit", maxFileBytes, false},
+		{"over the limit with the form overhead", maxFileBytes + multipartOverhead + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = uploadRequest(t, map[string][]byte{"big.png": bytes.Repeat([]byte{0}, tt.size)})
+
+			limitUploadBody(c, maxFileBytes)
+			_, err := c.MultipartForm()
+
+			if got := isBodyTooLarge(err); got != tt.wantTooLarge {
+				t.Errorf("isBodyTooLarge(%v) = %v, want %v", err, got, tt.wantTooLarge)
+			}
+			if !tt.wantTooLarge && err != nil {
+				t.Errorf("MultipartForm: %v", err)
+			}
+		})
+	}
+}
@@ -2,30 +2,55 @@ package handlers
 
 import (
 	"context"
+	"io"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/reaviseapp/rv-backend/internal/database"
 	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// maxAttachmentSize bounds a single uploaded message attachment.
+const maxAttachmentSize = 25 << 20
+
 type MessageHandler struct {
-	db *database.Database
+	db            *database.Database
+	hub           *services.RealtimeHub
+	privacy       *services.PrivacyService
+	store         services.BlobStore
+	notifications *services.NotificationService
+}
+
+// NewMessageHandler keeps attachments in store, which must not be served
+// publicly; GetAttachment serves them.
+func NewMessageHandler(db *database.Database, hub *services.RealtimeHub, privacy *services.PrivacyService, store services.BlobStore, notifications *services.NotificationService) *MessageHandler {
+	return &MessageHandler{db: db, hub: hub, privacy: privacy, store: store, notifications: notifications}
 }
 
-func NewMessageHandler(db *database.Database) *MessageHandler {
-	return &MessageHandler{db: db}
+// MessageContent is the payload of a new message. At least one of the
+// fields must be set; attachments must have been uploaded by the sender.
+type MessageContent struct {
+	Text          string   `json:"text"`
+	AttachmentIDs []string `json:"attachmentIds"`
+	SharedPostID  string   `json:"sharedPostId"`
 }
 
 type SendMessageRequest struct {
 	ReceiverID string `json:"receiverId" binding:"required"`
-	Text       string `json:"text" binding:"required"`
+	MessageContent
 }
 
+// SendMessage sends a direct message, starting the direct conversation
+// between the two users if needed. New conversations respect the receiver's
+// DM settings and start as a message request if they don't follow the sender.
 func (h *MessageHandler) SendMessage(c *gin.Context) {
 	senderID := c.GetString("userID")
 
@@ -35,20 +60,25 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		return
 	}
 
+	if req.ReceiverID == senderID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot message yourself"})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	message := models.Message{
-		ID:         primitive.NewObjectID().Hex(),
-		SenderID:   senderID,
-		ReceiverID: req.ReceiverID,
-		Text:       req.Text,
-		IsRead:     false,
-		CreatedAt:  time.Now(),
+	message, ok := h.buildMessage(ctx, c, senderID, req.MessageContent)
+	if !ok {
+		return
 	}
 
-	_, err := h.db.Messages().InsertOne(ctx, message)
-	if err != nil {
+	conversation, ok := h.directConversation(ctx, c, senderID, req.ReceiverID)
+	if !ok {
+		return
+	}
+
+	if err := h.postMessage(ctx, conversation, message); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
 		return
 	}
@@ -56,55 +86,111 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	c.JSON(http.StatusCreated, message)
 }
 
+// ConversationSummary is one entry of the authenticated user's inbox.
+// Partner is only set for direct conversations.
+type ConversationSummary struct {
+	models.Conversation
+	Members     []models.UserSummary `json:"members"`
+	Partner     *models.UserSummary  `json:"partner,omitempty"`
+	UnreadCount int                  `json:"unreadCount"`
+}
+
+// GetConversations returns the inbox. Message requests the user hasn't
+// accepted yet are listed separately by GetMessageRequests.
 func (h *MessageHandler) GetConversations(c *gin.Context) {
 	userID := c.GetString("userID")
+	h.listConversations(c, inboxFilter(userID))
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// Get unique conversation partners with their last message
-	pipeline := []bson.M{
-		{
-			"$match": bson.M{
-				"$or": []bson.M{
-					{"sender_id": userID},
-					{"receiver_id": userID},
-				},
-			},
-		},
+// GetMessageRequests returns direct conversations started by users the
+// caller doesn't follow, and groups such users added them to, until the
+// caller accepts or declines them.
+func (h *MessageHandler) GetMessageRequests(c *gin.Context) {
+	userID := c.GetString("userID")
+	h.listConversations(c, bson.M{"$or": []bson.M{
 		{
-			"$sort": bson.M{"created_at": -1},
+			"participants.user_id": userID,
+			"requested_by":         bson.M{"$exists": true, "$ne": userID},
 		},
-		{
-			"$group": bson.M{
-				"_id": bson.M{
-					"$cond": []interface{}{
-						bson.M{"$eq": []string{"$sender_id", userID}},
-						"$receiver_id",
-						"$sender_id",
-					},
-				},
-				"lastMessage": bson.M{"$first": "$$ROOT"},
-			},
+		{"participants": bson.M{"$elemMatch": bson.M{"user_id": userID, "requested": true}}},
+	}})
+}
+
+// inboxFilter matches userID's conversations except pending message
+// requests addressed to them.
+func inboxFilter(userID string) bson.M {
+	return bson.M{
+		"participants": bson.M{"$elemMatch": bson.M{"user_id": userID, "requested": bson.M{"$ne": true}}},
+		"$or": []bson.M{
+			{"requested_by": bson.M{"$exists": false}},
+			{"requested_by": userID},
 		},
 	}
+}
+
+func (h *MessageHandler) listConversations(c *gin.Context, filter bson.M) {
+	userID := c.GetString("userID")
 
-	cursor, err := h.db.Messages().Aggregate(ctx, pipeline)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "last_message_at", Value: -1}})
+
+	cursor, err := h.db.Conversations().Find(ctx, filter, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversations"})
 		return
 	}
 	defer cursor.Close(ctx)
 
-	var conversations []map[string]interface{}
+	var conversations []models.Conversation
 	if err = cursor.All(ctx, &conversations); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode conversations"})
 		return
 	}
 
-	c.JSON(http.StatusOK, conversations)
+	// Load participant profiles
+	var participantIDs []string
+	for _, conversation := range conversations {
+		participantIDs = append(participantIDs, conversation.ParticipantIDs()...)
+	}
+
+	profiles, err := findUserSummaries(ctx, h.db, participantIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversation participants"})
+		return
+	}
+
+	summaries := []ConversationSummary{}
+	for _, conversation := range conversations {
+		summary := ConversationSummary{
+			Conversation: conversation,
+			Members:      []models.UserSummary{},
+		}
+
+		for _, participant := range conversation.Participants {
+			profile, ok := profiles[participant.UserID]
+			if !ok {
+				// Deleted accounts still show up with their ID
+				profile = models.UserSummary{ID: participant.UserID}
+			}
+			summary.Members = append(summary.Members, profile)
+
+			if participant.UserID == userID {
+				summary.UnreadCount = participant.UnreadCount
+			} else if conversation.Type == "direct" {
+				partner := profile
+				summary.Partner = &partner
+			}
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	c.JSON(http.StatusOK, summaries)
 }
 
+// GetMessages returns the direct conversation with another user.
 func (h *MessageHandler) GetMessages(c *gin.Context) {
 	userID := c.GetString("userID")
 	otherUserID := c.Param("userId")
@@ -112,38 +198,536 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{
-		"$or": []bson.M{
-			{"sender_id": userID, "receiver_id": otherUserID},
-			{"sender_id": otherUserID, "receiver_id": userID},
+	var conversation models.Conversation
+	err := h.db.Conversations().FindOne(ctx, bson.M{
+		"direct_key": models.DirectConversationKey(userID, otherUserID),
+	}).Decode(&conversation)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusOK, []models.Message{})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversation"})
+		return
+	}
+
+	messages, err := h.findMessages(ctx, conversation.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+// MarkAsRead marks the direct conversation with another user as read.
+func (h *MessageHandler) MarkAsRead(c *gin.Context) {
+	userID := c.GetString("userID")
+	otherUserID := c.Param("userId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var conversation models.Conversation
+	err := h.db.Conversations().FindOne(ctx, bson.M{
+		"direct_key": models.DirectConversationKey(userID, otherUserID),
+	}).Decode(&conversation)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		return
+	}
+
+	readAt, err := h.markRead(ctx, &conversation, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark messages as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"readAt": readAt})
+}
+
+// UploadAttachment stores an image or video for use in a later message.
+func (h *MessageHandler) UploadAttachment(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	limitUploadBody(c, maxAttachmentSize)
+
+	fileHeader, err := c.FormFile("file")
+	if isBodyTooLarge(err) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment exceeds the 25MB limit"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File is required"})
+		return
+	}
+
+	if fileHeader.Size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Attachment exceeds the 25MB limit"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	contentType, mediaType, ok := sniffMedia(file)
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Only image and video attachments are supported"})
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	id := primitive.NewObjectID().Hex()
+	key := id + mediaExtension(contentType)
+
+	if err := h.store.Put(ctx, key, file, fileHeader.Size, contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+		return
+	}
+
+	attachment := models.MessageAttachment{
+		ID:          id,
+		UploaderID:  userID,
+		URL:         "/api/messages/attachments/" + id,
+		Key:         key,
+		Type:        mediaType,
+		ContentType: contentType,
+		Size:        fileHeader.Size,
+		Filename:    filepath.Base(fileHeader.Filename),
+		CreatedAt:   time.Now(),
+	}
+
+	_, err = h.db.Attachments().InsertOne(ctx, attachment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record attachment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// GetAttachment downloads a message attachment. Only its uploader and the
+// participants of a conversation it was sent to can read it.
+func (h *MessageHandler) GetAttachment(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	var attachment models.MessageAttachment
+	if err := h.db.Attachments().FindOne(ctx, bson.M{"_id": c.Param("id")}).Decode(&attachment); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	if attachment.UploaderID != userID {
+		filter := services.VisibleContentFilter("sender_id", userID)
+		filter["attachments._id"] = attachment.ID
+
+		conversationIDs, err := h.db.Messages().Distinct(ctx, "conversation_id", filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+			return
+		}
+
+		var count int64
+		if len(conversationIDs) > 0 {
+			count, err = h.db.Conversations().CountDocuments(ctx, bson.M{
+				"_id":                  bson.M{"$in": conversationIDs},
+				"participants.user_id": userID,
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+				return
+			}
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return
+		}
+	}
+
+	blob, err := h.store.Open(ctx, attachment.Key)
+	if err == services.ErrBlobNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Cache-Control", "private")
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, blob, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("inline", map[string]string{"filename": attachment.Filename}),
+	})
+}
+
+func (h *MessageHandler) GetUnreadCount(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Pending message requests don't count towards the badge
+	pipeline := []bson.M{
+		{"$match": inboxFilter(userID)},
+		{"$unwind": "$participants"},
+		{"$match": bson.M{"participants.user_id": userID}},
+		{"$group": bson.M{
+			"_id":         nil,
+			"unreadCount": bson.M{"$sum": "$participants.unread_count"},
+		}},
+	}
+
+	cursor, err := h.db.Conversations().Aggregate(ctx, pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread messages"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		UnreadCount int `bson:"unreadCount"`
+	}
+	if err = cursor.All(ctx, &totals); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread messages"})
+		return
+	}
+
+	unreadCount := 0
+	if len(totals) > 0 {
+		unreadCount = totals[0].UnreadCount
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unreadCount": unreadCount})
+}
+
+// directConversation finds the direct conversation between two users or
+// starts it if the receiver accepts messages from the sender. It writes the
+// error response itself.
+func (h *MessageHandler) directConversation(ctx context.Context, c *gin.Context, senderID, receiverID string) (*models.Conversation, bool) {
+	var conversation models.Conversation
+	err := h.db.Conversations().FindOne(ctx, bson.M{
+		"direct_key": models.DirectConversationKey(senderID, receiverID),
+	}).Decode(&conversation)
+	if err == nil {
+		return &conversation, h.checkNotBlocked(ctx, c, &conversation, senderID)
+	}
+	if err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch conversation"})
+		return nil, false
+	}
+
+	request, err := h.privacy.CheckNewConversation(ctx, senderID, receiverID)
+	switch err {
+	case nil:
+	case services.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	case services.ErrUserBlocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+		return nil, false
+	case services.ErrMessagesRestricted:
+		c.JSON(http.StatusForbidden, gin.H{"error": "This user doesn't accept messages from you"})
+		return nil, false
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check messaging permissions"})
+		return nil, false
+	}
+
+	requestedBy := ""
+	if request {
+		requestedBy = senderID
+	}
+
+	created, err := h.createDirectConversation(ctx, senderID, receiverID, requestedBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start conversation"})
+		return nil, false
+	}
+
+	return created, true
+}
+
+// checkNotBlocked rejects messages between blocked users of a direct
+// conversation. Group conversations are not affected.
+func (h *MessageHandler) checkNotBlocked(ctx context.Context, c *gin.Context, conversation *models.Conversation, userID string) bool {
+	if conversation.Type != "direct" {
+		return true
+	}
+
+	for _, otherID := range conversation.ParticipantIDs() {
+		if otherID == userID {
+			continue
+		}
+
+		blocked, err := h.privacy.IsBlocked(ctx, userID, otherID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check messaging permissions"})
+			return false
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+			return false
+		}
+	}
+
+	return true
+}
+
+func (h *MessageHandler) createDirectConversation(ctx context.Context, userA, userB, requestedBy string) (*models.Conversation, error) {
+	key := models.DirectConversationKey(userA, userB)
+
+	now := time.Now()
+	conversation := models.Conversation{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      "direct",
+		DirectKey: key,
+		Participants: []models.ConversationParticipant{
+			{UserID: userA, Role: "member", JoinedAt: now},
+			{UserID: userB, Role: "member", JoinedAt: now},
 		},
+		LastMessageAt: now,
+		CreatedBy:     userA,
+		RequestedBy:   requestedBy,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 
+	_, err := h.db.Conversations().InsertOne(ctx, conversation)
+	if mongo.IsDuplicateKeyError(err) {
+		// Started concurrently by the other user
+		err = h.db.Conversations().FindOne(ctx, bson.M{"direct_key": key}).Decode(&conversation)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &conversation, nil
+}
+
+// findMessages returns a conversation's messages as seen by viewerID,
+// leaving out messages moderators have hidden or removed.
+func (h *MessageHandler) findMessages(ctx context.Context, conversationID, viewerID string) ([]models.Message, error) {
 	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
 
+	filter := services.VisibleContentFilter("sender_id", viewerID)
+	filter["conversation_id"] = conversationID
+
 	cursor, err := h.db.Messages().Find(ctx, filter, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
-		return
+		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var messages []models.Message
+	messages := []models.Message{}
 	if err = cursor.All(ctx, &messages); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode messages"})
-		return
+		return nil, err
 	}
 
-	// Mark messages as read
-	_, _ = h.db.Messages().UpdateMany(
-		ctx,
-		bson.M{
-			"sender_id":   otherUserID,
-			"receiver_id": userID,
-			"is_read":     false,
+	return messages, nil
+}
+
+// buildMessage turns request content into an unsent message, resolving
+// attachments and shared posts. It writes the error response itself.
+func (h *MessageHandler) buildMessage(ctx context.Context, c *gin.Context, senderID string, content MessageContent) (*models.Message, bool) {
+	if content.Text == "" && len(content.AttachmentIDs) == 0 && content.SharedPostID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message must have text, attachments or a shared post"})
+		return nil, false
+	}
+
+	message := &models.Message{
+		SenderID: senderID,
+		Type:     "text",
+		Text:     content.Text,
+	}
+
+	if len(content.AttachmentIDs) > 0 {
+		cursor, err := h.db.Attachments().Find(ctx, bson.M{
+			"_id":         bson.M{"$in": content.AttachmentIDs},
+			"uploader_id": senderID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+			return nil, false
+		}
+		defer cursor.Close(ctx)
+
+		if err = cursor.All(ctx, &message.Attachments); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode attachments"})
+			return nil, false
+		}
+
+		if len(message.Attachments) != len(content.AttachmentIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Attachment not found"})
+			return nil, false
+		}
+
+		message.Type = "attachment"
+	}
+
+	if content.SharedPostID != "" {
+		post, ok := findVisiblePost(ctx, c, h.db, h.privacy, content.SharedPostID, senderID)
+		if !ok {
+			return nil, false
+		}
+
+		message.SharedPost = sharedPostFrom(post)
+		message.Type = "post"
+	}
+
+	return message, true
+}
+
+func sharedPostFrom(post *models.Post) *models.SharedPost {
+	shared := &models.SharedPost{
+		PostID:      post.ID,
+		UserID:      post.UserID,
+		Username:    post.Username,
+		Description: post.Description,
+		Category:    post.Category,
+		Revision:    models.CurrentRevision(post),
+	}
+	if len(post.Media) > 0 {
+		shared.ThumbnailURL = post.Media[0].URL
+	}
+	return shared
+}
+
+// postMessage stores a message, bumps the conversation's last message and
+// every other participant's unread count, and pushes it to all participants.
+func (h *MessageHandler) postMessage(ctx context.Context, conversation *models.Conversation, message *models.Message) error {
+	message.ID = primitive.NewObjectID().Hex()
+	message.ConversationID = conversation.ID
+	message.IsRead = false
+	message.CreatedAt = time.Now()
+
+	if conversation.Type == "direct" {
+		for _, participant := range conversation.Participants {
+			if participant.UserID != message.SenderID {
+				message.ReceiverID = participant.UserID
+			}
+		}
+	}
+
+	_, err := h.db.Messages().InsertOne(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"last_message":    message,
+			"last_message_at": message.CreatedAt,
+			"updated_at":      time.Now(),
 		},
-		bson.M{"$set": bson.M{"is_read": true}},
+		"$inc": bson.M{"participants.$[other].unread_count": 1},
+	}
+
+	filters := []interface{}{bson.M{"other.user_id": bson.M{"$ne": message.SenderID}}}
+
+	// Replying to a message request accepts it
+	if conversation.RequestedBy != "" && conversation.RequestedBy != message.SenderID {
+		update["$unset"] = bson.M{"requested_by": ""}
+		conversation.RequestedBy = ""
+	}
+	if sender := conversation.Participant(message.SenderID); sender != nil && sender.Requested {
+		update["$unset"] = bson.M{"participants.$[sender].requested": ""}
+		filters = append(filters, bson.M{"sender.user_id": message.SenderID})
+		sender.Requested = false
+	}
+
+	_, err = h.db.Conversations().UpdateOne(
+		ctx,
+		bson.M{"_id": conversation.ID},
+		update,
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: filters}),
 	)
+	if err != nil {
+		return err
+	}
 
-	c.JSON(http.StatusOK, messages)
+	h.hub.Publish("message", message, conversation.ParticipantIDs()...)
+
+	for _, participant := range conversation.Participants {
+		h.notifications.Notify(ctx, message.SenderID, models.Notification{
+			UserID:     participant.UserID,
+			Type:       "message",
+			TargetType: "conversation",
+			TargetID:   conversation.ID,
+			GroupKey:   "message:" + conversation.ID,
+		})
+	}
+
+	return nil
+}
+
+// markRead moves userID's read pointer to the conversation's last message
+// and notifies the other participants.
+func (h *MessageHandler) markRead(ctx context.Context, conversation *models.Conversation, userID string) (time.Time, error) {
+	readAt := time.Now()
+
+	set := bson.M{
+		"participants.$.unread_count": 0,
+		"participants.$.last_read_at": readAt,
+	}
+	lastReadMessageID := ""
+	if conversation.LastMessage != nil {
+		lastReadMessageID = conversation.LastMessage.ID
+		set["participants.$.last_read_message_id"] = lastReadMessageID
+	}
+
+	_, err := h.db.Conversations().UpdateOne(
+		ctx,
+		bson.M{"_id": conversation.ID, "participants.user_id": userID},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return readAt, err
+	}
+
+	// Direct messages also carry per-message receipts
+	if conversation.Type == "direct" {
+		_, err = h.db.Messages().UpdateMany(
+			ctx,
+			bson.M{
+				"conversation_id": conversation.ID,
+				"receiver_id":     userID,
+				"is_read":         false,
+			},
+			bson.M{"$set": bson.M{"is_read": true, "read_at": readAt}},
+		)
+		if err != nil {
+			return readAt, err
+		}
+	}
+
+	var others []string
+	for _, id := range conversation.ParticipantIDs() {
+		if id != userID {
+			others = append(others, id)
+		}
+	}
+
+	h.hub.Publish("read", gin.H{
+		"conversationId":    conversation.ID,
+		"readerId":          userID,
+		"readAt":            readAt,
+		"lastReadMessageId": lastReadMessageID,
+	}, others...)
+
+	return readAt, nil
 }
@@ -2,29 +2,53 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/reaviseapp/rv-backend/internal/database"
 	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// chainTimeout bounds calls that wait for on-chain confirmation, which take
+// far longer than a database round trip.
+const chainTimeout = 2 * time.Minute
+
 type NFTHandler struct {
-	db *database.Database
+	db              *database.Database
+	chain           services.ChainClient
+	mints           *services.MintService
+	privacy         *services.PrivacyService
+	notifications   *services.NotificationService
+	metadataBaseURL string
 }
 
-func NewNFTHandler(db *database.Database) *NFTHandler {
-	return &NFTHandler{db: db}
+func NewNFTHandler(db *database.Database, chain services.ChainClient, mints *services.MintService, privacy *services.PrivacyService, notifications *services.NotificationService, metadataBaseURL string) *NFTHandler {
+	return &NFTHandler{
+		db:              db,
+		chain:           chain,
+		mints:           mints,
+		privacy:         privacy,
+		notifications:   notifications,
+		metadataBaseURL: metadataBaseURL,
+	}
 }
 
 type CreateNFTListingRequest struct {
 	PostID         string    `json:"postId" binding:"required"`
+	Description    string    `json:"description"`
 	StartingBid    float64   `json:"startingBid" binding:"required"`
 	AuctionEndDate time.Time `json:"auctionEndDate" binding:"required"`
+	// RoyaltyBps is the creator's resale royalty in basis points (100 = 1%).
+	// It is fixed when the post is first listed and the token is minted.
+	RoyaltyBps *int `json:"royaltyBps" binding:"omitempty,min=0,max=1000"`
 }
 
 func (h *NFTHandler) CreateNFTListing(c *gin.Context) {
@@ -39,28 +63,173 @@ func (h *NFTHandler) CreateNFTListing(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Verify post belongs to user
-	var post models.Post
-	err := h.db.Posts().FindOne(ctx, bson.M{"_id": req.PostID, "user_id": ownerID}).Decode(&post)
+	post, ok := findSellablePost(ctx, c, h.db, req.PostID)
+	if !ok {
+		return
+	}
+
+	// Only one active listing per post
+	activeCount, err := h.db.NFTListings().CountDocuments(ctx, bson.M{
+		"post_id": req.PostID,
+		"status":  bson.M{"$in": []string{"active", "settling"}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing listings"})
+		return
+	}
+
+	if activeCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Post already has an active listing"})
+		return
+	}
+
+	// A post is minted once; later listings are resales by the current owner
+	var nft models.NFT
+	err = h.db.NFTs().FindOne(ctx, bson.M{"post_id": req.PostID}).Decode(&nft)
+	minted := err == nil
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFT"})
+		return
+	}
+
+	if minted && nft.Status == "minting" {
+		c.JSON(http.StatusConflict, gin.H{"error": "This post is already being minted"})
+		return
+	}
+
+	if minted {
+		if nft.OwnerID != ownerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the current owner can list this NFT"})
+			return
+		}
+		if req.RoyaltyBps != nil && *req.RoyaltyBps != nft.RoyaltyBps {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Royalty is fixed at first mint"})
+			return
+		}
+	} else if post.UserID != ownerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the creator can mint this post"})
+		return
+	}
+
+	// The token is minted to the owner's wallet, so one must be linked
+	var owner models.User
+	err = h.db.Users().FindOne(ctx, bson.M{"_id": ownerID}).Decode(&owner)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found or you don't own it"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	if owner.WalletAddress == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Link a wallet before listing an NFT"})
+		return
+	}
+
+	if !minted {
+		royaltyBps := 0
+		if req.RoyaltyBps != nil {
+			royaltyBps = *req.RoyaltyBps
+		}
+
+		// The NFT is recorded before minting, so a token minted by a request
+		// that then fails is never left without a record. The unique post
+		// index also stops two requests minting the same post.
+		nft = models.NFT{
+			ID:              primitive.NewObjectID().Hex(),
+			PostID:          post.ID,
+			CreatorID:       ownerID,
+			OwnerID:         ownerID,
+			Chain:           h.chain.Chain(),
+			ContractAddress: h.chain.ContractAddress(),
+			RoyaltyBps:      royaltyBps,
+			Status:          "minting",
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+
+		_, err = h.db.NFTs().InsertOne(ctx, nft)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This post is already being minted"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record NFT"})
+			return
+		}
+
+		// Mint token
+		chainCtx, chainCancel := context.WithTimeout(context.Background(), chainTimeout)
+		defer chainCancel()
+
+		result, err := h.chain.Mint(chainCtx, services.MintRequest{
+			To:              owner.WalletAddress,
+			TokenURI:        h.metadataBaseURL + post.ID,
+			RoyaltyReceiver: owner.WalletAddress,
+			RoyaltyBps:      royaltyBps,
+			Submitted: func(txHash string) {
+				// Lets the mint sweep find the transaction if this request
+				// never sees it confirm
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := h.mints.RecordSubmitted(ctx, nft.ID, txHash); err != nil {
+					log.Printf("Failed to record mint tx %s for NFT %s: %v", txHash, nft.ID, err)
+				}
+			},
+		})
+		if err != nil {
+			// A mint that timed out may still confirm, so its record is kept
+			// for the mint sweep to reconcile; other failures minted nothing
+			if errors.Is(err, context.DeadlineExceeded) {
+				log.Printf("Mint of post %s (NFT %s) timed out and will be reconciled: %v", post.ID, nft.ID, err)
+			} else {
+				h.discardMint(nft.ID)
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to mint NFT"})
+			return
+		}
+
+		// Minting can outlive the request context, so persist with a fresh one
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		finished, err := h.mints.Finish(ctx, &nft, result)
+		if err != nil {
+			// The mint sweep finishes it from the recorded transaction
+			log.Printf("Minted token %s (tx %s) for NFT %s but failed to record it: %v", result.TokenID, result.TxHash, nft.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record minted NFT"})
+			return
+		}
+		if !finished {
+			c.JSON(http.StatusConflict, gin.H{"error": "Mint was already reconciled, try listing again"})
+			return
+		}
+	}
+
 	// Create NFT listing
 	nftListing := models.NFTListing{
-		ID:             primitive.NewObjectID().Hex(),
-		PostID:         req.PostID,
-		OwnerID:        ownerID,
-		StartingBid:    req.StartingBid,
-		CurrentBid:     req.StartingBid,
-		AuctionEndDate: req.AuctionEndDate,
-		Status:         "active",
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		ID:              primitive.NewObjectID().Hex(),
+		PostID:          req.PostID,
+		NFTID:           nft.ID,
+		OwnerID:         ownerID,
+		CreatorID:       nft.CreatorID,
+		Description:     req.Description,
+		StartingBid:     req.StartingBid,
+		CurrentBid:      req.StartingBid,
+		AuctionEndDate:  req.AuctionEndDate,
+		Status:          "active",
+		Chain:           nft.Chain,
+		ContractAddress: nft.ContractAddress,
+		TokenID:         nft.TokenID,
+		MintTxHash:      nft.MintTxHash,
+		RoyaltyBps:      nft.RoyaltyBps,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
 
 	_, err = h.db.NFTListings().InsertOne(ctx, nftListing)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Post already has an active listing"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create NFT listing"})
 		return
@@ -69,15 +238,34 @@ func (h *NFTHandler) CreateNFTListing(c *gin.Context) {
 	c.JSON(http.StatusCreated, nftListing)
 }
 
+// discardMint removes the record of a mint that failed.
+func (h *NFTHandler) discardMint(nftID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := h.mints.Discard(ctx, nftID); err != nil {
+		log.Printf("Failed to discard failed mint %s: %v", nftID, err)
+	}
+}
+
 func (h *NFTHandler) GetNFTListings(c *gin.Context) {
+	viewerID := c.GetString("userID")
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	conditions, err := h.privacy.AuthorConditions(ctx, viewerID, "owner_id")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFT listings"})
+		return
+	}
+
 	status := c.Query("status")
-	filter := bson.M{}
+	filter := services.VisibleContentFilter("owner_id", viewerID)
 	if status != "" {
 		filter["status"] = status
 	}
+	filter["$and"] = conditions
 
 	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
 
@@ -98,19 +286,42 @@ func (h *NFTHandler) GetNFTListings(c *gin.Context) {
 }
 
 func (h *NFTHandler) GetNFTListing(c *gin.Context) {
+	viewerID := c.GetString("userID")
 	listingID := c.Param("id")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	listing, ok := h.findVisibleListing(ctx, c, listingID, viewerID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, listing)
+}
+
+// findVisibleListing loads a listing the viewer is allowed to see. Listings
+// removed or disabled by moderation, and those of blocked or suspended
+// sellers, are reported as not found. It writes the error response itself.
+func (h *NFTHandler) findVisibleListing(ctx context.Context, c *gin.Context, listingID, viewerID string) (*models.NFTListing, bool) {
 	var listing models.NFTListing
 	err := h.db.NFTListings().FindOne(ctx, bson.M{"_id": listingID}).Decode(&listing)
+	if err != nil || listing.DMCADisabled || !services.IsContentVisible(listing.ModerationStatus, listing.OwnerID, viewerID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "NFT listing not found"})
+		return nil, false
+	}
+
+	excluded, err := h.privacy.IsExcluded(ctx, viewerID, listing.OwnerID)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFT listing"})
+		return nil, false
+	}
+	if excluded {
 		c.JSON(http.StatusNotFound, gin.H{"error": "NFT listing not found"})
-		return
+		return nil, false
 	}
 
-	c.JSON(http.StatusOK, listing)
+	return &listing, true
 }
 
 type PlaceBidRequest struct {
@@ -133,7 +344,7 @@ func (h *NFTHandler) PlaceBid(c *gin.Context) {
 	// Get listing
 	var listing models.NFTListing
 	err := h.db.NFTListings().FindOne(ctx, bson.M{"_id": listingID}).Decode(&listing)
-	if err != nil {
+	if err != nil || listing.DMCADisabled || !services.IsContentVisible(listing.ModerationStatus, listing.OwnerID, bidderID) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "NFT listing not found"})
 		return
 	}
@@ -159,26 +370,323 @@ func (h *NFTHandler) PlaceBid(c *gin.Context) {
 		return
 	}
 
-	// Update current bid
+	// The token is transferred to the winner's wallet on settlement
+	var bidder models.User
+	err = h.db.Users().FindOne(ctx, bson.M{"_id": bidderID}).Decode(&bidder)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	if bidder.WalletAddress == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Link a wallet before bidding"})
+		return
+	}
+
+	// Update current bid, guarding against a higher bid placed concurrently
 	update := bson.M{
 		"$set": bson.M{
-			"current_bid": req.BidAmount,
-			"updated_at":  time.Now(),
+			"current_bid":       req.BidAmount,
+			"highest_bidder_id": bidderID,
+			"updated_at":        time.Now(),
 		},
 	}
 
-	_, err = h.db.NFTListings().UpdateOne(ctx, bson.M{"_id": listingID}, update)
+	// The listing as it was tells who has just been outbid
+	var previous models.NFTListing
+	err = h.db.NFTListings().FindOneAndUpdate(ctx, bson.M{
+		"_id":         listingID,
+		"status":      "active",
+		"current_bid": bson.M{"$lt": req.BidAmount},
+	}, update).Decode(&previous)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bid must be higher than current bid"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place bid"})
 		return
 	}
 
+	h.notifications.Notify(ctx, bidderID, models.Notification{
+		UserID:     previous.HighestBidderID,
+		Type:       "outbid",
+		TargetType: "listing",
+		TargetID:   listingID,
+		Data:       map[string]interface{}{"currentBid": req.BidAmount},
+		GroupKey:   "outbid:" + listingID,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":    "Bid placed successfully",
 		"currentBid": req.BidAmount,
 	})
 }
 
+func (h *NFTHandler) CancelNFTListing(c *gin.Context) {
+	listingID := c.Param("id")
+	ownerID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var listing models.NFTListing
+	err := h.db.NFTListings().FindOne(ctx, bson.M{"_id": listingID}).Decode(&listing)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "NFT listing not found"})
+		return
+	}
+
+	if listing.OwnerID != ownerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owner can cancel listing"})
+		return
+	}
+
+	if listing.Status != "active" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Auction is not active"})
+		return
+	}
+
+	// Guard against a bid placed since the listing was read
+	result, err := h.db.NFTListings().UpdateOne(ctx, bson.M{
+		"_id":               listingID,
+		"status":            "active",
+		"highest_bidder_id": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{
+			"status":     "cancelled",
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel listing"})
+		return
+	}
+
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot cancel a listing that has bids"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Listing cancelled successfully"})
+}
+
+type UpdateNFTListingRequest struct {
+	Description    *string    `json:"description"`
+	AuctionEndDate *time.Time `json:"auctionEndDate"`
+}
+
+func (h *NFTHandler) UpdateNFTListing(c *gin.Context) {
+	listingID := c.Param("id")
+	ownerID := c.GetString("userID")
+
+	var req UpdateNFTListingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var listing models.NFTListing
+	err := h.db.NFTListings().FindOne(ctx, bson.M{"_id": listingID}).Decode(&listing)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "NFT listing not found"})
+		return
+	}
+
+	if listing.OwnerID != ownerID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owner can edit listing"})
+		return
+	}
+
+	if listing.Status != "active" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Auction is not active"})
+		return
+	}
+
+	if time.Now().After(listing.AuctionEndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Auction has ended"})
+		return
+	}
+
+	set := bson.M{}
+	var edits []interface{}
+
+	if req.Description != nil && *req.Description != listing.Description {
+		set["description"] = *req.Description
+		edits = append(edits, models.NFTListingEdit{
+			ID:        primitive.NewObjectID().Hex(),
+			ListingID: listingID,
+			UserID:    ownerID,
+			Field:     "description",
+			OldValue:  listing.Description,
+			NewValue:  *req.Description,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	if req.AuctionEndDate != nil && !req.AuctionEndDate.Equal(listing.AuctionEndDate) {
+		// Bidders rely on the advertised end date, so it can only move later
+		if req.AuctionEndDate.Before(listing.AuctionEndDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Auction end date can only be extended"})
+			return
+		}
+
+		set["auction_end_date"] = *req.AuctionEndDate
+		edits = append(edits, models.NFTListingEdit{
+			ID:        primitive.NewObjectID().Hex(),
+			ListingID: listingID,
+			UserID:    ownerID,
+			Field:     "auctionEndDate",
+			OldValue:  listing.AuctionEndDate.Format(time.RFC3339),
+			NewValue:  req.AuctionEndDate.Format(time.RFC3339),
+			CreatedAt: time.Now(),
+		})
+	}
+
+	if len(edits) == 0 {
+		c.JSON(http.StatusOK, listing)
+		return
+	}
+
+	set["updated_at"] = time.Now()
+
+	var updated models.NFTListing
+	err = h.db.NFTListings().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": listingID, "status": "active"},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update listing"})
+		return
+	}
+
+	_, err = h.db.NFTListingEdits().InsertMany(ctx, edits)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record listing edits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *NFTHandler) GetNFTListingEdits(c *gin.Context) {
+	viewerID := c.GetString("userID")
+	listingID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := h.findVisibleListing(ctx, c, listingID, viewerID); !ok {
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := h.db.NFTListingEdits().Find(ctx, bson.M{"listing_id": listingID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch listing edits"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	edits := []models.NFTListingEdit{}
+	if err = cursor.All(ctx, &edits); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode listing edits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, edits)
+}
+
+// ProvenanceEntry is one owner in an NFT's ownership history.
+type ProvenanceEntry struct {
+	UserID     string    `json:"userId"`
+	Username   string    `json:"username"`
+	Event      string    `json:"event"` // mint, sale
+	Price      float64   `json:"price"`
+	ListingID  string    `json:"listingId,omitempty"`
+	TxHash     string    `json:"txHash"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+}
+
+func (h *NFTHandler) GetProvenance(c *gin.Context) {
+	viewerID := c.GetString("userID")
+	listingID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listing, ok := h.findVisibleListing(ctx, c, listingID, viewerID)
+	if !ok {
+		return
+	}
+
+	var nft models.NFT
+	err := h.db.NFTs().FindOne(ctx, bson.M{"_id": listing.NFTID}).Decode(&nft)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "NFT not found"})
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := h.db.NFTTransfers().Find(ctx, bson.M{"nft_id": nft.ID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NFT transfers"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var transfers []models.NFTTransfer
+	if err = cursor.All(ctx, &transfers); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode NFT transfers"})
+		return
+	}
+
+	// Resolve usernames of every owner
+	var userIDs []string
+	for _, transfer := range transfers {
+		userIDs = append(userIDs, transfer.ToUserID)
+	}
+
+	owners, err := findUserSummaries(ctx, h.db, userIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch owners"})
+		return
+	}
+
+	history := []ProvenanceEntry{}
+	for _, transfer := range transfers {
+		event := "sale"
+		if transfer.FromUserID == "" {
+			event = "mint"
+		}
+
+		history = append(history, ProvenanceEntry{
+			UserID:     transfer.ToUserID,
+			Username:   owners[transfer.ToUserID].Username,
+			Event:      event,
+			Price:      transfer.Price,
+			ListingID:  transfer.ListingID,
+			TxHash:     transfer.TxHash,
+			AcquiredAt: transfer.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"nft":    nft,
+		"owners": history,
+	})
+}
+
+// CompleteAuction settles an ended auction. Settlement claims the listing
+// before moving the token and records each step so that, if it fails part
+// way, calling it again finishes the job instead of transferring twice.
 func (h *NFTHandler) CompleteAuction(c *gin.Context) {
 	listingID := c.Param("id")
 	ownerID := c.GetString("userID")
@@ -200,25 +708,296 @@ func (h *NFTHandler) CompleteAuction(c *gin.Context) {
 		return
 	}
 
+	if listing.Status != "active" && listing.Status != "settling" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Auction is not active"})
+		return
+	}
+
 	// Check if auction has ended
 	if time.Now().Before(listing.AuctionEndDate) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Auction has not ended yet"})
 		return
 	}
 
-	// Update status
-	update := bson.M{
+	// No bids: the token stays with the owner
+	if listing.HighestBidderID == "" {
+		result, err := h.db.NFTListings().UpdateOne(ctx, bson.M{
+			"_id":               listingID,
+			"status":            "active",
+			"highest_bidder_id": bson.M{"$exists": false},
+		}, bson.M{
+			"$set": bson.M{
+				"status":     "expired",
+				"updated_at": time.Now(),
+			},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete auction"})
+			return
+		}
+
+		if result.ModifiedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Auction changed while completing it, try again"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Auction ended without bids"})
+		return
+	}
+
+	// Claiming the listing stops further bids and concurrent settlements.
+	// The claimed copy has the final highest bid.
+	now := time.Now()
+	var claimed models.NFTListing
+	err = h.db.NFTListings().FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id": listingID,
+			"$or": []bson.M{
+				{"status": "active"},
+				{"status": "settling", "settling_at": bson.M{"$not": bson.M{"$gt": now.Add(-settlementLease)}}},
+			},
+		},
+		bson.M{"$set": bson.M{"status": "settling", "settling_at": now, "updated_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&claimed)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusConflict, gin.H{"error": "Auction is already being settled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete auction"})
+		return
+	}
+
+	if !h.settleAuction(c, &claimed) {
+		h.releaseSettlement(listingID)
+	}
+}
+
+// settlementLease is how long a settling listing stays claimed by a request
+// that stopped without releasing it, e.g. because the server went down.
+const settlementLease = chainTimeout + time.Minute
+
+// settleAuction transfers a claimed listing's token to the winner and
+// records the sale. Steps already done by an earlier attempt are skipped or
+// repeated harmlessly. It writes the response itself and reports whether the
+// listing was settled.
+func (h *NFTHandler) settleAuction(c *gin.Context, listing *models.NFTListing) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var seller, winner models.User
+	if err := h.db.Users().FindOne(ctx, bson.M{"_id": listing.OwnerID}).Decode(&seller); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Seller not found"})
+		return false
+	}
+	if err := h.db.Users().FindOne(ctx, bson.M{"_id": listing.HighestBidderID}).Decode(&winner); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Winning bidder not found"})
+		return false
+	}
+
+	salePrice := listing.CurrentBid
+	txHash := listing.TransferTxHash
+	royaltyAmount := listing.RoyaltyAmount
+
+	if txHash == "" {
+		// Transfer token to the winner
+		chainCtx, chainCancel := context.WithTimeout(context.Background(), chainTimeout)
+		defer chainCancel()
+
+		onChainOwner, err := h.chain.OwnerOf(chainCtx, listing.TokenID)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read NFT owner"})
+			return false
+		}
+
+		// An earlier attempt may have transferred the token without managing
+		// to record it; its hash is then unknown
+		transferred := onChainOwner == winner.WalletAddress
+		if !transferred && onChainOwner != seller.WalletAddress {
+			c.JSON(http.StatusConflict, gin.H{"error": "NFT is no longer held by the seller's wallet"})
+			return false
+		}
+
+		// On resales the creator's royalty is taken out of the sale price
+		royaltyAmount = 0
+		if listing.CreatorID != "" && listing.CreatorID != listing.OwnerID {
+			royalty, err := h.chain.RoyaltyInfo(chainCtx, listing.TokenID, salePrice)
+			if err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read NFT royalty"})
+				return false
+			}
+			royaltyAmount = royalty.Amount
+		}
+
+		if !transferred {
+			txHash, err = h.chain.Transfer(chainCtx, listing.TokenID, seller.WalletAddress, winner.WalletAddress)
+			if err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to transfer NFT"})
+				return false
+			}
+		}
+	}
+
+	writeCtx, writeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer writeCancel()
+
+	// Recorded before anything else so a retry never transfers again
+	_, err := h.db.NFTListings().UpdateOne(writeCtx, bson.M{"_id": listing.ID, "status": "settling"}, bson.M{
+		"$set": bson.M{
+			"winner_id":        listing.HighestBidderID,
+			"transfer_tx_hash": txHash,
+			"royalty_amount":   royaltyAmount,
+			"updated_at":       time.Now(),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete auction"})
+		return false
+	}
+
+	// Record new owner
+	_, err = h.db.NFTs().UpdateOne(writeCtx, bson.M{"_id": listing.NFTID}, bson.M{
 		"$set": bson.M{
-			"status":     "sold",
+			"owner_id":   listing.HighestBidderID,
 			"updated_at": time.Now(),
 		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update NFT owner"})
+		return false
 	}
 
-	_, err = h.db.NFTListings().UpdateOne(ctx, bson.M{"_id": listingID}, update)
+	// The transfer and earnings are keyed by listing, so retries don't
+	// record them twice
+	_, err = h.db.NFTTransfers().UpdateOne(
+		writeCtx,
+		bson.M{"listing_id": listing.ID},
+		bson.M{"$setOnInsert": models.NFTTransfer{
+			ID:         primitive.NewObjectID().Hex(),
+			NFTID:      listing.NFTID,
+			FromUserID: listing.OwnerID,
+			ToUserID:   listing.HighestBidderID,
+			ListingID:  listing.ID,
+			Price:      salePrice,
+			TxHash:     txHash,
+			CreatedAt:  time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record NFT transfer"})
+		return false
+	}
+
+	// Record seller proceeds and creator royalty
+	earnings := []models.Earning{
+		{
+			ID:        primitive.NewObjectID().Hex(),
+			UserID:    listing.OwnerID,
+			Type:      "sale",
+			NFTID:     listing.NFTID,
+			ListingID: listing.ID,
+			Amount:    salePrice - royaltyAmount,
+			CreatedAt: time.Now(),
+		},
+	}
+	if royaltyAmount > 0 {
+		earnings = append(earnings, models.Earning{
+			ID:        primitive.NewObjectID().Hex(),
+			UserID:    listing.CreatorID,
+			Type:      "royalty",
+			NFTID:     listing.NFTID,
+			ListingID: listing.ID,
+			Amount:    royaltyAmount,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	for _, earning := range earnings {
+		_, err = h.db.Earnings().UpdateOne(
+			writeCtx,
+			bson.M{"listing_id": listing.ID, "type": earning.Type},
+			bson.M{"$setOnInsert": earning},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record earnings"})
+			return false
+		}
+	}
+
+	_, err = h.db.NFTListings().UpdateOne(writeCtx, bson.M{"_id": listing.ID, "status": "settling"}, bson.M{
+		"$set":   bson.M{"status": "sold", "updated_at": time.Now()},
+		"$unset": bson.M{"settling_at": ""},
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete auction"})
+		return false
+	}
+
+	h.notifications.Notify(writeCtx, listing.OwnerID, models.Notification{
+		UserID:     listing.HighestBidderID,
+		Type:       "auction_won",
+		TargetType: "listing",
+		TargetID:   listing.ID,
+		Data:       map[string]interface{}{"price": salePrice},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Auction completed successfully",
+		"winnerId":       listing.HighestBidderID,
+		"transferTxHash": txHash,
+		"royaltyAmount":  royaltyAmount,
+	})
+	return true
+}
+
+// releaseSettlement lets a failed settlement be retried straight away rather
+// than after its lease runs out.
+func (h *NFTHandler) releaseSettlement(listingID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := h.db.NFTListings().UpdateOne(
+		ctx,
+		bson.M{"_id": listingID, "status": "settling"},
+		bson.M{"$unset": bson.M{"settling_at": ""}},
+	)
+	if err != nil {
+		log.Printf("Failed to release settlement of listing %s: %v", listingID, err)
+	}
+}
+
+func (h *NFTHandler) GetEarnings(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := h.db.Earnings().Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch earnings"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	earnings := []models.Earning{}
+	if err = cursor.All(ctx, &earnings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode earnings"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Auction completed successfully"})
+	total := 0.0
+	for _, earning := range earnings {
+		total += earning.Amount
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"earnings": earnings,
+		"total":    total,
+	})
 }
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NotificationHandler struct {
+	db *database.Database
+}
+
+func NewNotificationHandler(db *database.Database) *NotificationHandler {
+	return &NotificationHandler{db: db}
+}
+
+// NotificationEntry is a notification with its actors resolved and a
+// ready-to-show summary.
+type NotificationEntry struct {
+	models.Notification
+	Actors []models.UserSummary `json:"actors"`
+	Text   string               `json:"text"`
+}
+
+// notificationActions completes "<actors> ..." for each notification type.
+var notificationActions = map[string]string{
+	"like":           "liked your post",
+	"comment":        "commented on your post",
+	"reply":          "replied to your comment",
+	"mention":        "mentioned you in a comment",
+	"follow":         "started following you",
+	"follow_request": "asked to follow you",
+	"message":        "sent you a message",
+	"outbid":         "outbid you",
+}
+
+// copyrightEvents describe copyright notifications by their data.event.
+var copyrightEvents = map[string]string{
+	"takedown":       "Your content was disabled in response to a copyright notice",
+	"strike":         "A copyright strike was recorded against your account",
+	"upheld":         "A copyright notice about your content was upheld",
+	"counter_notice": "A counter-notice was filed against your copyright notice",
+	"rejected":       "A copyright notice was rejected",
+	"restored":       "Content disabled by a copyright notice was restored",
+}
+
+// notificationText summarizes a notification, e.g. "jane and 4 others liked
+// your post".
+func notificationText(n models.Notification, actors []models.UserSummary) string {
+	switch n.Type {
+	case "auction_won":
+		return "You won an auction"
+	case "order":
+		if status, _ := n.Data["status"].(string); status != "" && status != "pending" {
+			return fmt.Sprintf("Your order is %s", status)
+		}
+		return "You have a new order"
+	case "copyright":
+		event, _ := n.Data["event"].(string)
+		if text, ok := copyrightEvents[event]; ok {
+			return text
+		}
+		return "A copyright notice was updated"
+	}
+
+	name := "Someone"
+	if len(actors) > 0 {
+		name = actors[0].Username
+	}
+	switch others := n.ActorsCount - 1; {
+	case others == 1 && len(actors) > 1:
+		name = fmt.Sprintf("%s and %s", name, actors[1].Username)
+	case others == 1:
+		name += " and 1 other"
+	case others > 1:
+		name = fmt.Sprintf("%s and %d others", name, others)
+	}
+	return name + " " + notificationActions[n.Type]
+}
+
+// GetNotifications lists the user's notifications, most recently active
+// first. Pass unread=true for unread ones only.
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	userID := c.GetString("userID")
+	page, perPage := parsePagination(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if c.Query("unread") == "true" {
+		filter["is_read"] = false
+	}
+
+	total, err := h.db.Notifications().CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"actor_set": 0}).
+		SetSort(bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * perPage)).
+		SetLimit(int64(perPage))
+
+	cursor, err := h.db.Notifications().Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var notifications []models.Notification
+	if err = cursor.All(ctx, &notifications); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode notifications"})
+		return
+	}
+
+	var actorIDs []string
+	for _, n := range notifications {
+		actorIDs = append(actorIDs, n.ActorIDs...)
+	}
+	summaries, err := findUserSummaries(ctx, h.db, actorIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	entries := make([]NotificationEntry, len(notifications))
+	for i, n := range notifications {
+		actors := []models.UserSummary{}
+		for _, id := range n.ActorIDs {
+			// Deleted accounts drop out of the group
+			if summary, ok := summaries[id]; ok {
+				actors = append(actors, summary)
+			}
+		}
+		entries[i] = NotificationEntry{Notification: n, Actors: actors, Text: notificationText(n, actors)}
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       entries,
+		Pagination: newPagination(page, perPage, total),
+	})
+}
+
+// GetUnreadCount returns how many unread notifications the user has.
+func (h *NotificationHandler) GetUnreadCount(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := h.db.Notifications().CountDocuments(ctx, bson.M{"user_id": userID, "is_read": false})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"count": count})
+}
+
+// MarkRead marks one notification read. Later activity on the same thing
+// starts a new group.
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID := c.GetString("userID")
+	notificationID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.db.Notifications().UpdateOne(
+		ctx,
+		bson.M{"_id": notificationID, "user_id": userID},
+		bson.M{"$set": bson.M{"is_read": true, "read_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// MarkAllRead marks all of the user's notifications read.
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.db.Notifications().UpdateMany(
+		ctx,
+		bson.M{"user_id": userID, "is_read": false},
+		bson.M{"$set": bson.M{"is_read": true, "read_at": time.Now()}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.ModifiedCount})
+}
+
+// GetPreferences returns whether each notification type is turned on.
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := h.db.Users().FindOne(
+		ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"notifications_off": 1}),
+	).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notificationPreferences(user.NotificationsOff))
+}
+
+// UpdatePreferences turns notification types on or off. Types left out of
+// the request keep their setting.
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var req map[string]bool
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	on, off := []string{}, []string{}
+	for notificationType, enabled := range req {
+		if !models.NotificationTypes[notificationType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown notification type %q", notificationType)})
+			return
+		}
+		if !enabled && models.RequiredNotificationTypes[notificationType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Notification type %q can't be turned off", notificationType)})
+			return
+		}
+		if enabled {
+			on = append(on, notificationType)
+		} else {
+			off = append(off, notificationType)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// $addToSet and $pull can't touch the same field in one update
+	updates := []bson.M{
+		{"$pull": bson.M{"notifications_off": bson.M{"$in": on}}},
+		{"$addToSet": bson.M{"notifications_off": bson.M{"$each": off}}},
+	}
+	for _, update := range updates {
+		if _, err := h.db.Users().UpdateOne(ctx, bson.M{"_id": userID}, update); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+			return
+		}
+	}
+
+	var user models.User
+	err := h.db.Users().FindOne(
+		ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"notifications_off": 1}),
+	).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notificationPreferences(user.NotificationsOff))
+}
+
+func notificationPreferences(off []string) map[string]bool {
+	preferences := make(map[string]bool, len(models.NotificationTypes))
+	for notificationType := range models.NotificationTypes {
+		preferences[notificationType] = true
+	}
+	for _, notificationType := range off {
+		preferences[notificationType] = false
+	}
+	return preferences
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// Pagination describes one page of a list response.
+type Pagination struct {
+	Page       int   `json:"page"`
+	PerPage    int   `json:"perPage"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"totalPages"`
+}
+
+// PaginatedResponse wraps a page of items and its pagination metadata.
+type PaginatedResponse struct {
+	Data       interface{} `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+}
+
+// parsePagination reads the page and perPage query parameters, falling back
+// to the defaults for missing or invalid values.
+func parsePagination(c *gin.Context) (page, perPage int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	perPage, err = strconv.Atoi(c.Query("perPage"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+
+	return page, perPage
+}
+
+func newPagination(page, perPage int, total int64) Pagination {
+	return Pagination{
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: int((total + int64(perPage) - 1) / int64(perPage)),
+	}
+}
@@ -8,21 +8,31 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/reaviseapp/rv-backend/internal/database"
 	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type PostHandler struct {
-	db *database.Database
+	db            *database.Database
+	privacy       *services.PrivacyService
+	notifications *services.NotificationService
 }
 
-func NewPostHandler(db *database.Database) *PostHandler {
-	return &PostHandler{db: db}
+func NewPostHandler(db *database.Database, privacy *services.PrivacyService, notifications *services.NotificationService) *PostHandler {
+	return &PostHandler{db: db, privacy: privacy, notifications: notifications}
+}
+
+// PostMediaRequest attaches uploaded media (see MediaHandler) to a post.
+type PostMediaRequest struct {
+	MediaID  string `json:"mediaId" binding:"required"`
+	Category string `json:"category"`
 }
 
 type CreatePostRequest struct {
-	Media       []models.MediaItem `json:"media" binding:"required"`
+	Media       []PostMediaRequest `json:"media" binding:"required,min=1,max=10,dive"`
 	Description string             `json:"description" binding:"required"`
 	Category    string             `json:"category" binding:"required"`
 	Hashtags    []string           `json:"hashtags"`
@@ -37,6 +47,10 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		return
 	}
 
+	if !validateCategories(c, req.Category, req.Media) {
+		return
+	}
+
 	// Get user info
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -48,6 +62,11 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		return
 	}
 
+	media, processing, ok := h.resolveMedia(ctx, c, userID, req.Media)
+	if !ok {
+		return
+	}
+
 	// Create post
 	post := models.Post{
 		ID:            primitive.NewObjectID().Hex(),
@@ -55,15 +74,22 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		Username:      user.Username,
 		UserAvatar:    user.ProfilePhoto,
 		UserLocation:  user.Location,
-		Media:         req.Media,
+		AuthorPrivate: user.IsPrivate,
+		Media:         media,
 		Description:   req.Description,
 		Category:      req.Category,
+		Categories:    models.CategoriesOf(req.Category, media),
 		Hashtags:      req.Hashtags,
 		LikesCount:    0,
 		CommentsCount: 0,
+		Revision:      1,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
+	// The media processor publishes the post once its media is ready
+	if processing {
+		post.ProcessingStatus = "processing"
+	}
 
 	_, err = h.db.Posts().InsertOne(ctx, post)
 	if err != nil {
@@ -71,24 +97,52 @@ func (h *PostHandler) CreatePost(c *gin.Context) {
 		return
 	}
 
+	revision := models.RevisionOf(&post)
+	revision.ID = primitive.NewObjectID().Hex()
+	if _, err := h.db.PostRevisions().InsertOne(ctx, revision); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record post revision"})
+		return
+	}
+
 	c.JSON(http.StatusCreated, post)
 }
 
 func (h *PostHandler) GetPosts(c *gin.Context) {
+	viewerID := c.GetString("userID")
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Get query parameters
 	category := c.Query("category")
 	userID := c.Query("userId")
+	if category != "" && !models.PostCategories[category] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category: " + category})
+		return
+	}
 
-	filter := bson.M{}
+	// Leave out posts by blocked users and private accounts the viewer
+	// doesn't follow
+	conditions, err := h.privacy.PostConditions(ctx, viewerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+
+	filter := services.VisibleContentFilter("user_id", viewerID)
+	filter["deleted_at"] = bson.M{"$exists": false}
+	// Authors see their own posts while the media is processed
+	if userID == "" || userID != viewerID {
+		filter["processing_status"] = bson.M{"$exists": false}
+	}
+	// Match posts whose media carries the category too
 	if category != "" {
-		filter["category"] = category
+		filter["categories"] = category
 	}
 	if userID != "" {
 		filter["user_id"] = userID
 	}
+	filter["$and"] = conditions
 
 	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(50)
 
@@ -109,15 +163,14 @@ func (h *PostHandler) GetPosts(c *gin.Context) {
 }
 
 func (h *PostHandler) GetPost(c *gin.Context) {
+	viewerID := c.GetString("userID")
 	postID := c.Param("id")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var post models.Post
-	err := h.db.Posts().FindOne(ctx, bson.M{"_id": postID}).Decode(&post)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	post, ok := findVisiblePost(ctx, c, h.db, h.privacy, postID, viewerID)
+	if !ok {
 		return
 	}
 
@@ -131,6 +184,11 @@ func (h *PostHandler) LikePost(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	post, ok := findVisiblePost(ctx, c, h.db, h.privacy, postID, userID)
+	if !ok {
+		return
+	}
+
 	// Check if already liked
 	var existingLike models.Like
 	err := h.db.Likes().FindOne(ctx, bson.M{"user_id": userID, "post_id": postID}).Decode(&existingLike)
@@ -164,5 +222,488 @@ func (h *PostHandler) LikePost(c *gin.Context) {
 		return
 	}
 
+	h.notifications.Notify(ctx, userID, models.Notification{
+		UserID:     post.UserID,
+		Type:       "like",
+		TargetType: "post",
+		TargetID:   postID,
+		GroupKey:   "like:" + postID,
+	})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Post liked successfully"})
 }
+
+// resolveMedia turns a post's media references into MediaItems. Every media
+// must be the author's own, fully uploaded file that hasn't failed
+// processing; processing reports whether any is still being processed. It
+// writes the error response itself on failure.
+func (h *PostHandler) resolveMedia(ctx context.Context, c *gin.Context, userID string, refs []PostMediaRequest) (items []models.MediaItem, processing bool, ok bool) {
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		ids = append(ids, ref.MediaID)
+	}
+
+	cursor, err := h.db.Media().Find(ctx, bson.M{
+		"_id":               bson.M{"$in": ids},
+		"owner_id":          userID,
+		"status":            "uploaded",
+		"processing_status": bson.M{"$ne": "failed"},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch media"})
+		return nil, false, false
+	}
+	defer cursor.Close(ctx)
+
+	var found []models.Media
+	if err = cursor.All(ctx, &found); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode media"})
+		return nil, false, false
+	}
+
+	byID := map[string]models.Media{}
+	for _, media := range found {
+		byID[media.ID] = media
+	}
+
+	items = make([]models.MediaItem, 0, len(refs))
+	for _, ref := range refs {
+		media, found := byID[ref.MediaID]
+		if !found {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Media not found or not uploaded: " + ref.MediaID})
+			return nil, false, false
+		}
+
+		items = append(items, services.NewMediaItem(&media, ref.Category))
+		if !services.IsMediaReady(&media) {
+			processing = true
+		}
+	}
+
+	return items, processing, true
+}
+
+// validateCategories checks a post's category and its media's labels against
+// models.PostCategories. It writes the error response itself.
+func validateCategories(c *gin.Context, category string, refs []PostMediaRequest) bool {
+	if !models.PostCategories[category] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category: " + category})
+		return false
+	}
+	for _, ref := range refs {
+		if ref.Category != "" && !models.PostCategories[ref.Category] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid media category: " + ref.Category})
+			return false
+		}
+	}
+	return true
+}
+
+// UpdatePostRequest changes the fields that are set.
+type UpdatePostRequest struct {
+	Media       *[]PostMediaRequest `json:"media" binding:"omitempty,min=1,max=10,dive"`
+	Description *string             `json:"description"`
+	Category    *string             `json:"category"`
+	Hashtags    *[]string           `json:"hashtags"`
+}
+
+// UpdatePost edits a post and records the result as a new revision.
+func (h *PostHandler) UpdatePost(c *gin.Context) {
+	userID := c.GetString("userID")
+	postID := c.Param("id")
+
+	var req UpdatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Description != nil && *req.Description == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Description cannot be empty"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	post, ok := h.findOwnPost(ctx, c, postID, userID)
+	if !ok {
+		return
+	}
+
+	if post.ProcessingStatus != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Post is still being processed"})
+		return
+	}
+
+	updated := *post
+	if req.Description != nil {
+		updated.Description = *req.Description
+	}
+	if req.Category != nil {
+		updated.Category = *req.Category
+	}
+	if req.Hashtags != nil {
+		updated.Hashtags = *req.Hashtags
+	}
+
+	refs := make([]PostMediaRequest, 0, len(post.Media))
+	if req.Media != nil {
+		refs = *req.Media
+	} else {
+		for _, item := range post.Media {
+			refs = append(refs, PostMediaRequest{MediaID: item.MediaID, Category: item.Category})
+		}
+	}
+
+	if !validateCategories(c, updated.Category, refs) {
+		return
+	}
+
+	if req.Media != nil {
+		media, processing, ok := h.resolveMedia(ctx, c, userID, refs)
+		if !ok {
+			return
+		}
+		// Published posts stay published, so new media must be ready
+		if processing {
+			c.JSON(http.StatusConflict, gin.H{"error": "Media is still being processed"})
+			return
+		}
+		updated.Media = media
+	}
+
+	updated.Categories = models.CategoriesOf(updated.Category, updated.Media)
+
+	now := time.Now()
+	updated.Revision = models.CurrentRevision(post) + 1
+	updated.EditedAt = &now
+	updated.UpdatedAt = now
+
+	// Posts created before revisions were kept get their original content
+	// recorded first
+	if post.Revision == 0 {
+		original := models.RevisionOf(post)
+		original.ID = primitive.NewObjectID().Hex()
+		if _, err := h.db.PostRevisions().InsertOne(ctx, original); err != nil && !mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record post revision"})
+			return
+		}
+	}
+
+	// Guard against another edit since the post was read
+	revisionFilter := interface{}(post.Revision)
+	if post.Revision == 0 {
+		revisionFilter = bson.M{"$exists": false}
+	}
+
+	result, err := h.db.Posts().UpdateOne(ctx, bson.M{
+		"_id":        postID,
+		"revision":   revisionFilter,
+		"deleted_at": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{
+		"media":       updated.Media,
+		"description": updated.Description,
+		"category":    updated.Category,
+		"categories":  updated.Categories,
+		"hashtags":    updated.Hashtags,
+		"revision":    updated.Revision,
+		"edited_at":   updated.EditedAt,
+		"updated_at":  updated.UpdatedAt,
+	}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update post"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Post was changed meanwhile; reload and try again"})
+		return
+	}
+
+	revision := models.RevisionOf(&updated)
+	revision.ID = primitive.NewObjectID().Hex()
+	if _, err := h.db.PostRevisions().InsertOne(ctx, revision); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record post revision"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeletePost soft-deletes a post. Its active NFT listings, pending purchases
+// and pending offers are cancelled; its likes and comments are kept until
+// the restore window has passed.
+func (h *PostHandler) DeletePost(c *gin.Context) {
+	userID := c.GetString("userID")
+	postID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, ok := h.findOwnPost(ctx, c, postID, userID); !ok {
+		return
+	}
+
+	now := time.Now()
+
+	// Auctions with bids must run their course, as with CancelNFTListing
+	_, err := h.db.NFTListings().UpdateMany(ctx, bson.M{
+		"post_id":           postID,
+		"status":            "active",
+		"highest_bidder_id": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"status": "cancelled", "updated_at": now}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel listings"})
+		return
+	}
+
+	activeCount, err := h.db.NFTListings().CountDocuments(ctx, bson.M{
+		"post_id": postID,
+		"status":  bson.M{"$in": []string{"active", "settling"}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check listings"})
+		return
+	}
+	if activeCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot delete a post with an auction that has bids"})
+		return
+	}
+
+	_, err = h.db.Transactions().UpdateMany(
+		ctx,
+		bson.M{"post_id": postID, "status": "pending"},
+		bson.M{"$set": bson.M{"status": "cancelled", "updated_at": now}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel purchases"})
+		return
+	}
+
+	_, err = h.db.Messages().UpdateMany(
+		ctx,
+		bson.M{"offer.post_id": postID, "offer.status": "pending"},
+		bson.M{"$set": bson.M{
+			"offer.status":       "withdrawn",
+			"offer.responded_by": userID,
+			"offer.responded_at": now,
+		}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to withdraw offers"})
+		return
+	}
+
+	result, err := h.db.Posts().UpdateOne(
+		ctx,
+		bson.M{"_id": postID, "deleted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete post"})
+		return
+	}
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Post deleted successfully",
+		"restoreUntil": now.Add(models.PostRestoreWindow),
+	})
+}
+
+// RestorePost undoes a deletion within the restore window. Cancelled
+// listings, purchases and offers stay cancelled.
+func (h *PostHandler) RestorePost(c *gin.Context) {
+	userID := c.GetString("userID")
+	postID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var post models.Post
+	err := h.db.Posts().FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"_id":        postID,
+			"user_id":    userID,
+			"deleted_at": bson.M{"$gt": time.Now().Add(-models.PostRestoreWindow)},
+		},
+		bson.M{
+			"$unset": bson.M{"deleted_at": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&post)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted post not found or no longer restorable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// GetDeletedPosts lists the user's deleted posts that can still be restored.
+func (h *PostHandler) GetDeletedPosts(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := h.db.Posts().Find(
+		ctx,
+		bson.M{"user_id": userID, "deleted_at": bson.M{"$gt": time.Now().Add(-models.PostRestoreWindow)}},
+		options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	posts := []models.Post{}
+	if err = cursor.All(ctx, &posts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode posts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, posts)
+}
+
+// GetPostRevisions lists a post's revisions, oldest first. The parties to a
+// transaction, offer or NFT listing for the post can see them even after it
+// is deleted.
+func (h *PostHandler) GetPostRevisions(c *gin.Context) {
+	viewerID := c.GetString("userID")
+	postID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	party, err := h.isPostParty(ctx, postID, viewerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	if !party {
+		if _, ok := findVisiblePost(ctx, c, h.db, h.privacy, postID, viewerID); !ok {
+			return
+		}
+	}
+
+	cursor, err := h.db.PostRevisions().Find(
+		ctx,
+		bson.M{"post_id": postID},
+		options.Find().SetSort(bson.D{{Key: "revision", Value: 1}}),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.PostRevision{}
+	if err = cursor.All(ctx, &revisions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// isPostParty reports whether userID bought or sold the post, was offered
+// it, or owns or won an NFT listing of it.
+func (h *PostHandler) isPostParty(ctx context.Context, postID, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+
+	references := map[*mongo.Collection]bson.M{
+		h.db.Transactions(): {
+			"post_id": postID,
+			"$or":     []bson.M{{"buyer_id": userID}, {"seller_id": userID}},
+		},
+		h.db.Messages(): {
+			"offer.post_id": postID,
+			"$or":           []bson.M{{"offer.buyer_id": userID}, {"offer.seller_id": userID}},
+		},
+		h.db.NFTListings(): {
+			"post_id": postID,
+			"$or":     []bson.M{{"owner_id": userID}, {"winner_id": userID}},
+		},
+	}
+
+	for collection, filter := range references {
+		n, err := collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// UpdateCommentSettings turns comments on a post off or on. Existing
+// comments stay visible either way.
+func (h *PostHandler) UpdateCommentSettings(c *gin.Context) {
+	userID := c.GetString("userID")
+	postID := c.Param("id")
+
+	var req struct {
+		CommentsDisabled *bool `json:"commentsDisabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, ok := h.findOwnPost(ctx, c, postID, userID); !ok {
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"comments_disabled": true}}
+	if !*req.CommentsDisabled {
+		update = bson.M{"$unset": bson.M{"comments_disabled": ""}}
+	}
+
+	var post models.Post
+	err := h.db.Posts().FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": postID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&post)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
+
+// findOwnPost loads a live post for its author to change. It writes the
+// error response itself.
+func (h *PostHandler) findOwnPost(ctx context.Context, c *gin.Context, postID, userID string) (*models.Post, bool) {
+	var post models.Post
+	err := h.db.Posts().FindOne(ctx, bson.M{"_id": postID, "deleted_at": bson.M{"$exists": false}}).Decode(&post)
+	if err != nil || post.ModerationStatus == "removed" || post.DMCADisabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+		return nil, false
+	}
+
+	if post.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change other user's post"})
+		return nil, false
+	}
+
+	return &post, true
+}
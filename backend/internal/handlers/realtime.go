@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingPeriod     = (wsPongWait * 9) / 10
+	wsMaxMessageSize = 4096
+)
+
+type RealtimeHandler struct {
+	db          *database.Database
+	authService *services.AuthService
+	hub         *services.RealtimeHub
+	privacy     *services.PrivacyService
+	upgrader    websocket.Upgrader
+}
+
+func NewRealtimeHandler(db *database.Database, authService *services.AuthService, hub *services.RealtimeHub, privacy *services.PrivacyService, allowedOrigins []string) *RealtimeHandler {
+	return &RealtimeHandler{
+		db:          db,
+		authService: authService,
+		hub:         hub,
+		privacy:     privacy,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				if origin == "" {
+					return true
+				}
+				for _, allowed := range allowedOrigins {
+					if origin == allowed {
+						return true
+					}
+				}
+				return false
+			},
+		},
+	}
+}
+
+// inboundEvent is a frame sent by the client over the socket. Typing
+// indicators target either a conversation or, for direct chats, a user.
+type inboundEvent struct {
+	Type           string `json:"type"` // typing
+	ConversationID string `json:"conversationId"`
+	To             string `json:"to"`
+	IsTyping       bool   `json:"isTyping"`
+}
+
+// Connect upgrades the request to a WebSocket and streams realtime events to
+// the authenticated user. Browsers cannot set headers on WebSocket requests,
+// so the JWT may also be passed as the token query parameter.
+func (h *RealtimeHandler) Connect(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+		return
+	}
+
+	claims, err := h.authService.ValidateToken(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	userID := claims.UserID
+
+	suspension, err := h.authService.ActiveSuspension(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+		return
+	}
+	if suspension != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended"})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		return
+	}
+
+	client := h.hub.Register(userID)
+
+	go h.writePump(conn, client)
+	h.readPump(conn, client)
+}
+
+func (h *RealtimeHandler) readPump(conn *websocket.Conn, client *services.RealtimeClient) {
+	defer func() {
+		h.hub.Unregister(client)
+		conn.Close()
+	}()
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var event inboundEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return
+		}
+
+		switch event.Type {
+		case "typing":
+			h.publishTyping(client.UserID, event)
+		}
+	}
+}
+
+func (h *RealtimeHandler) publishTyping(userID string, event inboundEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Typing to a user is only relayed within an existing direct conversation
+	filter := bson.M{"_id": event.ConversationID, "participants.user_id": userID}
+	if event.ConversationID == "" {
+		if event.To == "" || event.To == userID {
+			return
+		}
+		filter = bson.M{"direct_key": models.DirectConversationKey(userID, event.To)}
+	}
+
+	var conversation models.Conversation
+	if err := h.db.Conversations().FindOne(ctx, filter).Decode(&conversation); err != nil {
+		return
+	}
+
+	if conversation.Type == "direct" {
+		for _, id := range conversation.ParticipantIDs() {
+			if blocked, err := h.privacy.IsBlocked(ctx, userID, id); err != nil || blocked {
+				return
+			}
+		}
+	}
+
+	var others []string
+	for _, id := range conversation.ParticipantIDs() {
+		if id != userID {
+			others = append(others, id)
+		}
+	}
+
+	h.hub.Publish("typing", gin.H{
+		"conversationId": conversation.ID,
+		"userId":         userID,
+		"isTyping":       event.IsTyping,
+	}, others...)
+}
+
+func (h *RealtimeHandler) writePump(conn *websocket.Conn, client *services.RealtimeClient) {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case event, ok := <-client.Send:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				// The hub dropped this client
+				_ = conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
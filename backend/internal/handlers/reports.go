@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReportHandler struct {
+	db         *database.Database
+	moderation *services.ModerationService
+}
+
+func NewReportHandler(db *database.Database, moderation *services.ModerationService) *ReportHandler {
+	return &ReportHandler{db: db, moderation: moderation}
+}
+
+type CreateReportRequest struct {
+	TargetType string `json:"targetType" binding:"required"`
+	TargetID   string `json:"targetId" binding:"required"`
+	Reason     string `json:"reason" binding:"required"`
+	Details    string `json:"details" binding:"max=2000"`
+}
+
+// CreateReport files a report about a post, comment, user, message or listing.
+func (h *ReportHandler) CreateReport(c *gin.Context) {
+	reporterID := c.GetString("userID")
+
+	var req CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.ReportTargetTypes[req.TargetType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target type: " + req.TargetType})
+		return
+	}
+
+	if !models.ReportReasons[req.Reason] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason: " + req.Reason})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ownerID, err := h.moderation.TargetOwner(ctx, req.TargetType, req.TargetID)
+	if err == services.ErrTargetNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reported content not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reported content"})
+		return
+	}
+
+	if ownerID == reporterID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot report yourself or your own content"})
+		return
+	}
+
+	// Messages can only be reported by someone who could read them
+	if req.TargetType == "message" {
+		var message models.Message
+		if err := h.db.Messages().FindOne(ctx, bson.M{"_id": req.TargetID}).Decode(&message); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reported content not found"})
+			return
+		}
+
+		count, err := h.db.Conversations().CountDocuments(ctx, bson.M{
+			"_id":                  message.ConversationID,
+			"participants.user_id": reporterID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reported content"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reported content not found"})
+			return
+		}
+	}
+
+	// One pending report per reporter and target
+	count, err := h.db.Reports().CountDocuments(ctx, bson.M{
+		"reporter_id": reporterID,
+		"target_type": req.TargetType,
+		"target_id":   req.TargetID,
+		"status":      bson.M{"$in": []string{"open", "reviewing"}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this"})
+		return
+	}
+
+	now := time.Now()
+	report := models.Report{
+		ID:            primitive.NewObjectID().Hex(),
+		ReporterID:    reporterID,
+		TargetType:    req.TargetType,
+		TargetID:      req.TargetID,
+		TargetOwnerID: ownerID,
+		Reason:        req.Reason,
+		Details:       req.Details,
+		Status:        "open",
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	_, err = h.db.Reports().InsertOne(ctx, report)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetReports is the moderator queue, oldest first. It defaults to open reports.
+func (h *ReportHandler) GetReports(c *gin.Context) {
+	page, perPage := parsePagination(c)
+
+	filter := bson.M{"status": c.DefaultQuery("status", "open")}
+	if targetType := c.Query("targetType"); targetType != "" {
+		filter["target_type"] = targetType
+	}
+	if reason := c.Query("reason"); reason != "" {
+		filter["reason"] = reason
+	}
+	if assignedTo := c.Query("assignedTo"); assignedTo != "" {
+		filter["assigned_to"] = assignedTo
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := h.db.Reports().CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetSkip(int64((page - 1) * perPage)).
+		SetLimit(int64(perPage))
+
+	cursor, err := h.db.Reports().Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reports"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	reports := []models.Report{}
+	if err = cursor.All(ctx, &reports); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       reports,
+		Pagination: newPagination(page, perPage, total),
+	})
+}
+
+// ReportDetail is a report with the context a moderator needs to triage it.
+type ReportDetail struct {
+	models.Report
+	TargetOwner        *models.UserSummary       `json:"targetOwner,omitempty"`
+	OpenReportsCount   int64                     `json:"openReportsCount"`
+	OwnerActionHistory []models.ModerationAction `json:"ownerActionHistory"`
+}
+
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	reportID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var report models.Report
+	if err := h.db.Reports().FindOne(ctx, bson.M{"_id": reportID}).Decode(&report); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	detail := ReportDetail{Report: report, OwnerActionHistory: []models.ModerationAction{}}
+
+	profiles, err := findUserSummaries(ctx, h.db, []string{report.TargetOwnerID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report"})
+		return
+	}
+	if profile, ok := profiles[report.TargetOwnerID]; ok {
+		detail.TargetOwner = &profile
+	}
+
+	detail.OpenReportsCount, err = h.db.Reports().CountDocuments(ctx, bson.M{
+		"target_type": report.TargetType,
+		"target_id":   report.TargetID,
+		"status":      bson.M{"$in": []string{"open", "reviewing"}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch report"})
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(20)
+
+	cursor, err := h.db.ModerationActions().Find(ctx, bson.M{"user_id": report.TargetOwnerID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch moderation history"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &detail.OwnerActionHistory); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode moderation history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// UpdateReportStatus triages a report: start reviewing it (assigning it to
+// the caller), dismiss it, or put it back in the queue.
+func (h *ReportHandler) UpdateReportStatus(c *gin.Context) {
+	moderatorID := c.GetString("userID")
+	reportID := c.Param("id")
+
+	var req struct {
+		Status string `json:"status" binding:"required,oneof=open reviewing dismissed"`
+		Note   string `json:"note"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{"status": req.Status, "updated_at": now}
+	update := bson.M{"$set": set}
+
+	switch req.Status {
+	case "open":
+		update["$unset"] = bson.M{"assigned_to": ""}
+	case "reviewing":
+		set["assigned_to"] = moderatorID
+	case "dismissed":
+		set["resolution_note"] = req.Note
+		set["resolved_by"] = moderatorID
+		set["resolved_at"] = now
+	}
+
+	// Resolved and dismissed reports are closed
+	result, err := h.db.Reports().UpdateOne(
+		ctx,
+		bson.M{"_id": reportID, "status": bson.M{"$in": []string{"open", "reviewing"}}},
+		update,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found or already closed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Report updated successfully"})
+}
+
+type ModerationActionRequest struct {
+	Action string `json:"action" binding:"required,oneof=hide remove restore warn suspend"`
+	Note   string `json:"note"`
+	// DurationDays limits a suspension; omit for a permanent ban.
+	DurationDays int `json:"durationDays" binding:"min=0"`
+}
+
+// TakeAction applies a moderation action to a report's target and resolves
+// every pending report about the same target.
+func (h *ReportHandler) TakeAction(c *gin.Context) {
+	moderatorID := c.GetString("userID")
+	reportID := c.Param("id")
+
+	var req ModerationActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var report models.Report
+	if err := h.db.Reports().FindOne(ctx, bson.M{"_id": reportID}).Decode(&report); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	action := models.ModerationAction{
+		ModeratorID: moderatorID,
+		ReportID:    report.ID,
+		Action:      req.Action,
+		TargetType:  report.TargetType,
+		TargetID:    report.TargetID,
+		Note:        req.Note,
+	}
+	if req.Action == "suspend" && req.DurationDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.DurationDays)
+		action.ExpiresAt = &expiresAt
+	}
+
+	entry, ok := beginAudit(ctx, c, h.db, "resolve_report", report.TargetType, report.TargetID, map[string]interface{}{
+		"reportId":  report.ID,
+		"action":    req.Action,
+		"note":      req.Note,
+		"expiresAt": action.ExpiresAt,
+	})
+	if !ok {
+		return
+	}
+
+	err := h.moderation.Apply(ctx, &action)
+	if err != nil {
+		finishAudit(h.db, entry, err, nil)
+	}
+
+	switch err {
+	case nil:
+	case services.ErrTargetNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reported content no longer exists"})
+		return
+	case services.ErrInvalidAction:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Action " + req.Action + " does not apply to a " + report.TargetType})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply moderation action"})
+		return
+	}
+
+	now := time.Now()
+	_, err = h.db.Reports().UpdateMany(
+		ctx,
+		bson.M{
+			"target_type": report.TargetType,
+			"target_id":   report.TargetID,
+			"status":      bson.M{"$in": []string{"open", "reviewing"}},
+		},
+		bson.M{"$set": bson.M{
+			"status":          "resolved",
+			"action":          req.Action,
+			"resolution_note": req.Note,
+			"resolved_by":     moderatorID,
+			"resolved_at":     now,
+			"updated_at":      now,
+		}},
+	)
+	// The action itself was taken even if the reports stay open
+	finishAudit(h.db, entry, nil, map[string]interface{}{
+		"ownerId":  action.UserID,
+		"actionId": action.ID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, action)
+}
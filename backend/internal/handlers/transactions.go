@@ -8,17 +8,19 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/reaviseapp/rv-backend/internal/database"
 	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type TransactionHandler struct {
-	db *database.Database
+	db            *database.Database
+	notifications *services.NotificationService
 }
 
-func NewTransactionHandler(db *database.Database) *TransactionHandler {
-	return &TransactionHandler{db: db}
+func NewTransactionHandler(db *database.Database, notifications *services.NotificationService) *TransactionHandler {
+	return &TransactionHandler{db: db, notifications: notifications}
 }
 
 type CreateTransactionRequest struct {
@@ -40,10 +42,8 @@ func (h *TransactionHandler) CreateTransaction(c *gin.Context) {
 	defer cancel()
 
 	// Get post to determine seller
-	var post models.Post
-	err := h.db.Posts().FindOne(ctx, bson.M{"_id": req.PostID}).Decode(&post)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+	post, ok := findSellablePost(ctx, c, h.db, req.PostID)
+	if !ok {
 		return
 	}
 
@@ -56,16 +56,19 @@ func (h *TransactionHandler) CreateTransaction(c *gin.Context) {
 		Amount:        req.Amount,
 		Status:        "pending",
 		PaymentMethod: req.PaymentMethod,
+		PostRevision:  models.CurrentRevision(post),
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
-	_, err = h.db.Transactions().InsertOne(ctx, transaction)
+	_, err := h.db.Transactions().InsertOne(ctx, transaction)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction"})
 		return
 	}
 
+	notifyOrder(ctx, h.notifications, buyerID, transaction.SellerID, &transaction)
+
 	c.JSON(http.StatusCreated, transaction)
 }
 
@@ -167,5 +170,21 @@ func (h *TransactionHandler) UpdateTransactionStatus(c *gin.Context) {
 		return
 	}
 
+	transaction.Status = req.Status
+	notifyOrder(ctx, h.notifications, userID, transaction.BuyerID, &transaction)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Transaction updated successfully"})
 }
+
+// notifyOrder tells a party to a transaction that it was placed or changed
+// status.
+func notifyOrder(ctx context.Context, notifications *services.NotificationService, actorID, userID string, transaction *models.Transaction) {
+	notifications.Notify(ctx, actorID, models.Notification{
+		UserID:     userID,
+		Type:       "order",
+		TargetType: "transaction",
+		TargetID:   transaction.ID,
+		PostID:     transaction.PostID,
+		Data:       map[string]interface{}{"status": transaction.Status, "amount": transaction.Amount},
+	})
+}
@@ -2,33 +2,73 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/reaviseapp/rv-backend/internal/database"
 	"github.com/reaviseapp/rv-backend/internal/models"
+	"github.com/reaviseapp/rv-backend/internal/services"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type UserHandler struct {
-	db *database.Database
+	db            *database.Database
+	privacy       *services.PrivacyService
+	profiles      *services.ProfileSyncService
+	notifications *services.NotificationService
 }
 
-func NewUserHandler(db *database.Database) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *database.Database, privacy *services.PrivacyService, profiles *services.ProfileSyncService, notifications *services.NotificationService) *UserHandler {
+	return &UserHandler{db: db, privacy: privacy, profiles: profiles, notifications: notifications}
 }
 
 func (h *UserHandler) GetUser(c *gin.Context) {
-	userID := c.Param("id")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h.respondWithUser(ctx, c, bson.M{"_id": c.Param("id")})
+}
 
+// GetUserByUsername looks a user up by username, ignoring case.
+func (h *UserHandler) GetUserByUsername(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	h.respondWithUser(ctx, c, bson.M{"username_key": models.UsernameKey(c.Param("username"))})
+}
+
+// respondWithUser writes the profile matching filter, if the viewer may see
+// it.
+func (h *UserHandler) respondWithUser(ctx context.Context, c *gin.Context, filter bson.M) {
+	viewerID := c.GetString("userID")
+
 	var user models.User
-	err := h.db.Users().FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	err := h.db.Users().FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Blocked users don't see each other's profiles. Private profiles stay
+	// visible; only their posts are restricted.
+	blocked, err := h.privacy.IsBlocked(ctx, viewerID, user.ID)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Suspended profiles are hidden from everyone else along with their content
+	if viewerID != user.ID && user.Suspension.Active(time.Now()) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
@@ -36,6 +76,16 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// UpdateUserRequest changes the profile fields that are set. Empty strings
+// clear the optional fields.
+type UpdateUserRequest struct {
+	Username     *string `json:"username"`
+	Bio          *string `json:"bio" binding:"omitempty,max=500"`
+	Website      *string `json:"website" binding:"omitempty,max=200"`
+	Location     *string `json:"location" binding:"omitempty,max=100"`
+	ProfilePhoto *string `json:"profilePhoto"`
+}
+
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	currentUserID := c.GetString("userID")
 	userID := c.Param("id")
@@ -46,36 +96,193 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var updateData struct {
-		Username     string `json:"username"`
-		Bio          string `json:"bio"`
-		Website      string `json:"website"`
-		Location     string `json:"location"`
-		ProfilePhoto string `json:"profilePhoto"`
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Username != nil && !validateUsername(c, *req.Username) {
+		return
+	}
+
+	if req.Website != nil && *req.Website != "" && !isWebURL(*req.Website) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Website must be an http or https URL"})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&updateData); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := h.db.Users().FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{"updated_at": now}
+	unset := bson.M{}
+	filter := bson.M{"_id": userID}
+
+	setOrUnset := func(field string, value *string) {
+		if value == nil {
+			return
+		}
+		if *value == "" {
+			unset[field] = ""
+		} else {
+			set[field] = *value
+		}
+	}
+	setOrUnset("bio", req.Bio)
+	setOrUnset("website", req.Website)
+	setOrUnset("location", req.Location)
+	setOrUnset("profile_photo", req.ProfilePhoto)
+
+	if req.Username != nil && *req.Username != user.Username {
+		key := models.UsernameKey(*req.Username)
+
+		// Changing only the case keeps the same name
+		if key != user.UsernameKey {
+			if user.UsernameChangedAt != nil && now.Before(user.UsernameChangedAt.Add(models.UsernameChangeCooldown)) {
+				c.JSON(http.StatusTooManyRequests, gin.H{
+					"error":        "Username was changed recently",
+					"nextChangeAt": user.UsernameChangedAt.Add(models.UsernameChangeCooldown),
+				})
+				return
+			}
+			set["username_changed_at"] = now
+		}
+
+		set["username"] = *req.Username
+		set["username_key"] = key
+
+		// Another change racing this one can't skip the cooldown
+		if user.UsernameChangedAt == nil {
+			filter["username_changed_at"] = bson.M{"$exists": false}
+		} else {
+			filter["username_changed_at"] = user.UsernameChangedAt
+		}
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var updated models.User
+	err := h.db.Users().FindOneAndUpdate(
+		ctx,
+		filter,
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username is already taken"})
+		return
+	}
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username was changed meanwhile; reload and try again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	// Posts and comments carry a copy of these, updated in the background
+	if updated.Username != user.Username ||
+		updated.ProfilePhoto != user.ProfilePhoto ||
+		updated.Location != user.Location {
+		if err := h.profiles.Enqueue(ctx, userID); err != nil {
+			log.Printf("Failed to schedule profile sync for user %s: %v", userID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// isWebURL reports whether s is an absolute http or https URL.
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (h *UserHandler) LinkWallet(c *gin.Context) {
+	currentUserID := c.GetString("userID")
+	userID := c.Param("id")
+
+	// Users can only link a wallet to their own account
+	if currentUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot link wallet to other user's account"})
+		return
+	}
+
+	var req struct {
+		WalletAddress string `json:"walletAddress" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if !services.IsValidWalletAddress(req.WalletAddress) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet address"})
+		return
+	}
+	walletAddress := services.NormalizeWalletAddress(req.WalletAddress)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// A wallet can only be linked to one account
+	var existingUser models.User
+	err := h.db.Users().FindOne(ctx, bson.M{
+		"wallet_address": walletAddress,
+		"_id":            bson.M{"$ne": userID},
+	}).Decode(&existingUser)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Wallet is already linked to another account"})
+		return
+	}
+
+	var user models.User
+	err = h.db.Users().FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	// Tokens are held by the old wallet, so replacing it would leave the
+	// user's NFTs and sales pointing at an address they no longer use
+	if user.WalletAddress != "" && user.WalletAddress != walletAddress {
+		holding, err := h.holdsNFTs(ctx, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link wallet"})
+			return
+		}
+		if holding {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot change wallet while you own NFTs or have open NFT listings"})
+			return
+		}
+	}
+
 	update := bson.M{
 		"$set": bson.M{
-			"username":      updateData.Username,
-			"bio":           updateData.Bio,
-			"website":       updateData.Website,
-			"location":      updateData.Location,
-			"profile_photo": updateData.ProfilePhoto,
-			"updated_at":    time.Now(),
+			"wallet_address": walletAddress,
+			"updated_at":     time.Now(),
 		},
 	}
 
 	result, err := h.db.Users().UpdateOne(ctx, bson.M{"_id": userID}, update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link wallet"})
 		return
 	}
 
@@ -84,7 +291,25 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Wallet linked successfully",
+		"walletAddress": walletAddress,
+	})
+}
+
+// holdsNFTs reports whether the user owns a token, including one still being
+// minted to their wallet, or has a listing that is open or being settled.
+func (h *UserHandler) holdsNFTs(ctx context.Context, userID string) (bool, error) {
+	count, err := h.db.NFTs().CountDocuments(ctx, bson.M{"owner_id": userID}, options.Count().SetLimit(1))
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	count, err = h.db.NFTListings().CountDocuments(ctx, bson.M{
+		"owner_id": userID,
+		"status":   bson.M{"$in": []string{"active", "settling"}},
+	}, options.Count().SetLimit(1))
+	return count > 0, err
 }
 
 func (h *UserHandler) FollowUser(c *gin.Context) {
@@ -99,9 +324,26 @@ func (h *UserHandler) FollowUser(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	var followee models.User
+	err := h.db.Users().FindOne(ctx, bson.M{"_id": followeeID}).Decode(&followee)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	blocked, err := h.privacy.IsBlocked(ctx, followerID, followeeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow user"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot follow this user"})
+		return
+	}
+
 	// Check if already following
 	var existingFollow models.Follow
-	err := h.db.Follows().FindOne(ctx, bson.M{
+	err = h.db.Follows().FindOne(ctx, bson.M{
 		"follower_id": followerID,
 		"followee_id": followeeID,
 	}).Decode(&existingFollow)
@@ -111,7 +353,61 @@ func (h *UserHandler) FollowUser(c *gin.Context) {
 		return
 	}
 
-	// Create follow relationship
+	// Private accounts approve their followers
+	if followee.IsPrivate {
+		request := models.FollowRequest{
+			ID:          primitive.NewObjectID().Hex(),
+			RequesterID: followerID,
+			TargetID:    followeeID,
+			CreatedAt:   time.Now(),
+		}
+
+		_, err = h.db.FollowRequests().InsertOne(ctx, request)
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Follow request already sent"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send follow request"})
+			return
+		}
+
+		h.notifications.Notify(ctx, followerID, models.Notification{
+			UserID:     followeeID,
+			Type:       "follow_request",
+			TargetType: "user",
+			TargetID:   followeeID,
+			GroupKey:   "follow_request",
+		})
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Follow request sent",
+			"status":  "requested",
+		})
+		return
+	}
+
+	if err := h.createFollow(ctx, followerID, followeeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow user"})
+		return
+	}
+
+	h.notifications.Notify(ctx, followerID, models.Notification{
+		UserID:     followeeID,
+		Type:       "follow",
+		TargetType: "user",
+		TargetID:   followeeID,
+		GroupKey:   "follow",
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User followed successfully",
+		"status":  "following",
+	})
+}
+
+// createFollow records a follow and updates both users' counts.
+func (h *UserHandler) createFollow(ctx context.Context, followerID, followeeID string) error {
 	follow := models.Follow{
 		ID:         primitive.NewObjectID().Hex(),
 		FollowerID: followerID,
@@ -119,10 +415,9 @@ func (h *UserHandler) FollowUser(c *gin.Context) {
 		CreatedAt:  time.Now(),
 	}
 
-	_, err = h.db.Follows().InsertOne(ctx, follow)
+	_, err := h.db.Follows().InsertOne(ctx, follow)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow user"})
-		return
+		return err
 	}
 
 	// Update follower and followee counts
@@ -138,7 +433,7 @@ func (h *UserHandler) FollowUser(c *gin.Context) {
 		bson.M{"$inc": bson.M{"followers_count": 1}},
 	)
 
-	c.JSON(http.StatusOK, gin.H{"message": "User followed successfully"})
+	return nil
 }
 
 func (h *UserHandler) UnfollowUser(c *gin.Context) {
@@ -159,6 +454,16 @@ func (h *UserHandler) UnfollowUser(c *gin.Context) {
 	}
 
 	if result.DeletedCount == 0 {
+		// Unfollowing a private account withdraws a pending request
+		requestResult, err := h.db.FollowRequests().DeleteOne(ctx, bson.M{
+			"requester_id": followerID,
+			"target_id":    followeeID,
+		})
+		if err == nil && requestResult.DeletedCount > 0 {
+			c.JSON(http.StatusOK, gin.H{"message": "Follow request withdrawn"})
+			return
+		}
+
 		c.JSON(http.StatusNotFound, gin.H{"error": "Not following this user"})
 		return
 	}
@@ -179,6 +484,345 @@ func (h *UserHandler) UnfollowUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User unfollowed successfully"})
 }
 
+// UpdatePrivacy changes whether the account is private and who can start a
+// direct conversation with the user. Making an account public approves all
+// pending follow requests.
+func (h *UserHandler) UpdatePrivacy(c *gin.Context) {
+	currentUserID := c.GetString("userID")
+	userID := c.Param("id")
+
+	if currentUserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot update other user's privacy settings"})
+		return
+	}
+
+	var req struct {
+		DMPrivacy string `json:"dmPrivacy"`
+		IsPrivate *bool  `json:"isPrivate"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	if req.DMPrivacy != "" {
+		if !models.DMPrivacySettings[req.DMPrivacy] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid DM privacy setting: " + req.DMPrivacy})
+			return
+		}
+		set["dm_privacy"] = req.DMPrivacy
+	}
+	if req.IsPrivate != nil {
+		set["is_private"] = *req.IsPrivate
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := h.db.Users().UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$set": set})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update privacy settings"})
+		return
+	}
+
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Posts carry a copy of this for feeds, updated in the background
+	if req.IsPrivate != nil {
+		if err := h.profiles.Enqueue(ctx, userID); err != nil {
+			log.Printf("Failed to schedule profile sync for user %s: %v", userID, err)
+		}
+	}
+
+	if req.IsPrivate != nil && !*req.IsPrivate {
+		if err := h.approveAllFollowRequests(ctx, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve pending follow requests"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Privacy settings updated successfully"})
+}
+
+// FollowRequestEntry is a pending follow request with the requester's profile.
+type FollowRequestEntry struct {
+	models.FollowRequest
+	Requester models.UserSummary `json:"requester"`
+}
+
+// GetFollowRequests lists pending requests to follow the caller, newest first.
+func (h *UserHandler) GetFollowRequests(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := h.db.FollowRequests().Find(ctx, bson.M{"target_id": userID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follow requests"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var requests []models.FollowRequest
+	if err = cursor.All(ctx, &requests); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode follow requests"})
+		return
+	}
+
+	var requesterIDs []string
+	for _, request := range requests {
+		requesterIDs = append(requesterIDs, request.RequesterID)
+	}
+
+	profiles, err := findUserSummaries(ctx, h.db, requesterIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follow requests"})
+		return
+	}
+
+	entries := []FollowRequestEntry{}
+	for _, request := range requests {
+		profile, ok := profiles[request.RequesterID]
+		if !ok {
+			continue
+		}
+		entries = append(entries, FollowRequestEntry{FollowRequest: request, Requester: profile})
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// ApproveFollowRequest turns a pending request from the given user into a follow.
+func (h *UserHandler) ApproveFollowRequest(c *gin.Context) {
+	userID := c.GetString("userID")
+	requesterID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.db.FollowRequests().DeleteOne(ctx, bson.M{
+		"requester_id": requesterID,
+		"target_id":    userID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve follow request"})
+		return
+	}
+
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Follow request not found"})
+		return
+	}
+
+	if err := h.createFollow(ctx, requesterID, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve follow request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Follow request approved"})
+}
+
+func (h *UserHandler) RejectFollowRequest(c *gin.Context) {
+	userID := c.GetString("userID")
+	requesterID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.db.FollowRequests().DeleteOne(ctx, bson.M{
+		"requester_id": requesterID,
+		"target_id":    userID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject follow request"})
+		return
+	}
+
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Follow request not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Follow request rejected"})
+}
+
+func (h *UserHandler) approveAllFollowRequests(ctx context.Context, userID string) error {
+	cursor, err := h.db.FollowRequests().Find(ctx, bson.M{"target_id": userID})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var requests []models.FollowRequest
+	if err = cursor.All(ctx, &requests); err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		result, err := h.db.FollowRequests().DeleteOne(ctx, bson.M{"_id": request.ID})
+		if err != nil {
+			return err
+		}
+		if result.DeletedCount == 0 {
+			continue
+		}
+
+		if err := h.createFollow(ctx, request.RequesterID, userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BlockUser blocks another user and removes any follows and follow requests
+// between the two.
+func (h *UserHandler) BlockUser(c *gin.Context) {
+	blockerID := c.GetString("userID")
+	blockedID := c.Param("id")
+
+	if blockerID == blockedID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot block yourself"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := h.db.Users().CountDocuments(ctx, bson.M{"_id": blockedID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	_, err = h.db.Blocks().UpdateOne(
+		ctx,
+		bson.M{"blocker_id": blockerID, "blocked_id": blockedID},
+		bson.M{"$setOnInsert": models.Block{
+			ID:        primitive.NewObjectID().Hex(),
+			BlockerID: blockerID,
+			BlockedID: blockedID,
+			CreatedAt: time.Now(),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to block user"})
+		return
+	}
+
+	// Remove follows in both directions
+	for _, pair := range [][2]string{{blockerID, blockedID}, {blockedID, blockerID}} {
+		result, err := h.db.Follows().DeleteOne(ctx, bson.M{
+			"follower_id": pair[0],
+			"followee_id": pair[1],
+		})
+		if err != nil || result.DeletedCount == 0 {
+			continue
+		}
+
+		_, _ = h.db.Users().UpdateOne(
+			ctx,
+			bson.M{"_id": pair[0]},
+			bson.M{"$inc": bson.M{"following_count": -1}},
+		)
+
+		_, _ = h.db.Users().UpdateOne(
+			ctx,
+			bson.M{"_id": pair[1]},
+			bson.M{"$inc": bson.M{"followers_count": -1}},
+		)
+	}
+
+	_, _ = h.db.FollowRequests().DeleteMany(ctx, bson.M{
+		"$or": []bson.M{
+			{"requester_id": blockerID, "target_id": blockedID},
+			{"requester_id": blockedID, "target_id": blockerID},
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked successfully"})
+}
+
+func (h *UserHandler) UnblockUser(c *gin.Context) {
+	blockerID := c.GetString("userID")
+	blockedID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := h.db.Blocks().DeleteOne(ctx, bson.M{
+		"blocker_id": blockerID,
+		"blocked_id": blockedID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unblock user"})
+		return
+	}
+
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked successfully"})
+}
+
+// GetBlockedUsers lists the users the caller has blocked.
+func (h *UserHandler) GetBlockedUsers(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	cursor, err := h.db.Blocks().Find(ctx, bson.M{"blocker_id": userID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked users"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []models.Block
+	if err = cursor.All(ctx, &blocks); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode blocked users"})
+		return
+	}
+
+	var blockedIDs []string
+	for _, block := range blocks {
+		blockedIDs = append(blockedIDs, block.BlockedID)
+	}
+
+	profiles, err := findUserSummaries(ctx, h.db, blockedIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch blocked users"})
+		return
+	}
+
+	users := []models.UserSummary{}
+	for _, id := range blockedIDs {
+		if profile, ok := profiles[id]; ok {
+			users = append(users, profile)
+		}
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	currentUserID := c.GetString("userID")
 	userID := c.Param("id")
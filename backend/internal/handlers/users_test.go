@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidateUsername(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		username string
+		want     bool
+	}{
+		{"abc", true},
+		{"jane_doe", true},
+		{"jane.doe", true},
+		{"j.a.n.e", true},
+		{"_jane_", true},
+		{"Jane2024", true},
+		{strings.Repeat("a", 30), true},
+		{"", false},
+		{"ab", false},
+		{strings.Repeat("a", 31), false},
+		{".jane", false},
+		{"jane.", false},
+		{"jane..doe", false},
+		{"...", false},
+		{"jane doe", false},
+		{"jane-doe", false},
+		{"jane@doe", false},
+		{"jäne", false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		got := validateUsername(c, tt.username)
+		if got != tt.want {
+			t.Errorf("validateUsername(%q) = %v, want %v", tt.username, got, tt.want)
+		}
+		if !got && w.Code != http.StatusBadRequest {
+			t.Errorf("validateUsername(%q) responded %d, want %d", tt.username, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestIsWebURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com", true},
+		{"http://example.com/shop?ref=1", true},
+		{"HTTPS://EXAMPLE.COM", true},
+		{"", false},
+		{"example.com", false},
+		{"//example.com", false},
+		{"ftp://example.com", false},
+		{"javascript:alert(1)", false},
+		{"https://", false},
+		{"https:///path", false},
+	}
+
+	for _, tt := range tests {
+		if got := isWebURL(tt.url); got != tt.want {
+			t.Errorf("isWebURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
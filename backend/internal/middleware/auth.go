@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/reaviseapp/rv-backend/internal/database"
 	"github.com/reaviseapp/rv-backend/internal/services"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
@@ -26,15 +30,99 @@ func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 		}
 
 		token := parts[1]
-		userID, err := authService.ValidateToken(token)
+		claims, err := authService.ValidateToken(token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		// Set user ID in context
-		c.Set("userID", userID)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		roles, suspension, err := authService.CurrentAccess(ctx, claims.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+			c.Abort()
+			return
+		}
+		if suspension != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":  "Account suspended",
+				"reason": suspension.Reason,
+				"until":  suspension.Until,
+			})
+			c.Abort()
+			return
+		}
+
+		// Roles in the token may have been revoked since it was issued
+		claims.Roles = roles
+
+		// Set user ID and roles in context
+		c.Set("userID", claims.UserID)
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// OptionalAuth sets the user ID when a valid bearer token is present and
+// otherwise lets the request through anonymously. Public reads use it to
+// apply the viewer's blocks and privacy. The claims' roles are the token's
+// and must not be trusted.
+func OptionalAuth(authService *services.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) == 2 && parts[0] == "Bearer" {
+			if claims, err := authService.ValidateToken(parts[1]); err == nil {
+				c.Set("userID", claims.UserID)
+				c.Set("claims", claims)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireRole only lets through users who hold one of the given roles. It
+// must run after AuthMiddleware, which loads the user's current roles.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("claims")
+		claims, ok := value.(*services.Claims)
+		if !ok || !claims.HasRole(roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireBusinessAccount only lets through approved business accounts. It
+// reads the account from the database so approval takes effect immediately,
+// and must run after AuthMiddleware.
+func RequireBusinessAccount(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		count, err := db.Users().CountDocuments(ctx, bson.M{
+			"_id":                 c.GetString("userID"),
+			"is_business_account": true,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+			c.Abort()
+			return
+		}
+
+		if count == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This feature is only available to business accounts"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
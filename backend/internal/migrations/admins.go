@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GrantAdminRoles gives the admin role to the accounts with the given
+// emails, so a fresh deployment has someone who can assign roles through the
+// admin API. Accounts that already hold the role are left alone.
+func GrantAdminRoles(db *database.Database, emails []string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := db.Users().UpdateMany(
+		ctx,
+		bson.M{"email": bson.M{"$in": emails}},
+		bson.M{"$addToSet": bson.M{"roles": "admin"}},
+	)
+	return err
+}
@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BackfillAuthorFlags copies the privacy of accounts onto posts, and current
+// suspensions onto posts, comments and NFT listings, created before they
+// carried them, so lists keep hiding them. Only content missing the flags is
+// touched, so it is safe to run on every startup.
+func BackfillAuthorFlags(db *database.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	if err := backfillPrivateAuthors(ctx, db); err != nil {
+		return err
+	}
+	return backfillSuspendedAuthors(ctx, db)
+}
+
+func backfillPrivateAuthors(ctx context.Context, db *database.Database) error {
+	cursor, err := db.Users().Find(
+		ctx,
+		bson.M{"is_private": true},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+
+		_, err := db.Posts().UpdateMany(
+			ctx,
+			bson.M{"user_id": user.ID, "author_private": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"author_private": true}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func backfillSuspendedAuthors(ctx context.Context, db *database.Database) error {
+	cursor, err := db.Users().Find(
+		ctx,
+		bson.M{
+			"suspension": bson.M{"$exists": true},
+			"$or": []bson.M{
+				{"suspension.until": nil},
+				{"suspension.until": bson.M{"$gt": time.Now()}},
+			},
+		},
+		options.Find().SetProjection(bson.M{"suspension": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	content := map[*mongo.Collection]string{
+		db.Posts():       "user_id",
+		db.Comments():    "user_id",
+		db.NFTListings(): "owner_id",
+	}
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+
+		set := bson.M{"author_suspended": true}
+		if user.Suspension.Until != nil {
+			set["author_suspension_ends_at"] = *user.Suspension.Until
+		}
+
+		for collection, ownerField := range content {
+			_, err := collection.UpdateMany(
+				ctx,
+				bson.M{ownerField: user.ID, "author_suspended": bson.M{"$exists": false}},
+				bson.M{"$set": set},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return cursor.Err()
+}
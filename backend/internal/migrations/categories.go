@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BackfillPostCategories sets the categories of posts created before posts
+// were listed under their media's categories. Unknown categories are left
+// out. Only posts without categories are touched, so it is safe to run on
+// every startup.
+func BackfillPostCategories(db *database.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	valid := make([]string, 0, len(models.PostCategories))
+	for category := range models.PostCategories {
+		valid = append(valid, category)
+	}
+
+	_, err := db.Posts().UpdateMany(
+		ctx,
+		bson.M{"categories": bson.M{"$exists": false}},
+		[]bson.M{{"$set": bson.M{
+			"categories": bson.M{"$filter": bson.M{
+				"input": bson.M{"$setUnion": []interface{}{
+					[]string{"$category"},
+					bson.M{"$ifNull": []interface{}{"$media.category", []string{}}},
+				}},
+				"cond": bson.M{"$in": []interface{}{"$$this", valid}},
+			}},
+		}}},
+	)
+	return err
+}
@@ -0,0 +1,211 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const directMessagesMigration = "direct_messages"
+
+// MigrateDirectMessages moves messages written before conversations existed
+// into direct conversations. Only one instance runs it at a time, and it is
+// recorded once it has completed. Each pair of users is migrated so that
+// rerunning it after a failure picks up where it stopped.
+func MigrateDirectMessages(db *database.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	claimed, err := claim(ctx, db, directMessagesMigration, 10*time.Minute)
+	if err != nil || !claimed {
+		return err
+	}
+
+	if err := migrateDirectMessages(ctx, db); err != nil {
+		if releaseErr := release(db, directMessagesMigration); releaseErr != nil {
+			log.Printf("Failed to release message migration: %v", releaseErr)
+		}
+		return err
+	}
+
+	return complete(ctx, db, directMessagesMigration)
+}
+
+func migrateDirectMessages(ctx context.Context, db *database.Database) error {
+	// Group legacy messages by pair of users
+	pipeline := []bson.M{
+		{"$match": bson.M{"conversation_id": bson.M{"$exists": false}}},
+		{"$group": bson.M{
+			"_id": bson.M{
+				"$cond": []interface{}{
+					bson.M{"$lt": []string{"$sender_id", "$receiver_id"}},
+					bson.M{"a": "$sender_id", "b": "$receiver_id"},
+					bson.M{"a": "$receiver_id", "b": "$sender_id"},
+				},
+			},
+		}},
+	}
+
+	cursor, err := db.Messages().Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var pairs []struct {
+		ID struct {
+			A string `bson:"a"`
+			B string `bson:"b"`
+		} `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &pairs); err != nil {
+		return err
+	}
+
+	for _, pair := range pairs {
+		if err := migratePair(ctx, db, pair.ID.A, pair.ID.B); err != nil {
+			return err
+		}
+	}
+
+	if len(pairs) > 0 {
+		log.Printf("Migrated direct messages of %d conversations", len(pairs))
+	}
+
+	return nil
+}
+
+// migratePair moves the legacy messages between two users into their direct
+// conversation. The conversation's unread counts and last message are set
+// from the pair's legacy and migrated messages together before the legacy
+// messages are moved, so a rerun after a failure at any point sets them again
+// to the same values.
+func migratePair(ctx context.Context, db *database.Database, userA, userB string) error {
+	key := models.DirectConversationKey(userA, userB)
+	legacy := bson.M{
+		"conversation_id": bson.M{"$exists": false},
+		"$or": []bson.M{
+			{"sender_id": userA, "receiver_id": userB},
+			{"sender_id": userB, "receiver_id": userA},
+		},
+	}
+
+	conversation, err := directConversation(ctx, db, key, legacy, userA, userB)
+	if err != nil {
+		return err
+	}
+
+	pair := bson.M{"$or": []bson.M{legacy, {"conversation_id": conversation.ID}}}
+
+	// Direct messages carry per-message receipts, so unread counts follow
+	// from the messages themselves
+	cursor, err := db.Messages().Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"$and": []bson.M{pair, {"is_read": false}}}},
+		{"$group": bson.M{"_id": "$receiver_id", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		ReceiverID string `bson:"_id"`
+		Count      int    `bson:"count"`
+	}
+	if err = cursor.All(ctx, &counts); err != nil {
+		return err
+	}
+
+	unread := map[string]int{}
+	for _, count := range counts {
+		unread[count.ReceiverID] = count.Count
+	}
+
+	var last models.Message
+	err = db.Messages().FindOne(ctx, pair, options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})).Decode(&last)
+	if err != nil {
+		return err
+	}
+	last.ConversationID = conversation.ID
+
+	set := bson.M{
+		"participants.$[a].unread_count": unread[userA],
+		"last_message":                   last,
+		"last_message_at":                last.CreatedAt,
+	}
+	filters := []interface{}{bson.M{"a.user_id": userA}}
+	if userB != userA {
+		set["participants.$[b].unread_count"] = unread[userB]
+		filters = append(filters, bson.M{"b.user_id": userB})
+	}
+
+	_, err = db.Conversations().UpdateOne(
+		ctx,
+		bson.M{"_id": conversation.ID},
+		bson.M{"$set": set},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: filters}),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Messages().UpdateMany(ctx, legacy, bson.M{"$set": bson.M{"conversation_id": conversation.ID}})
+	return err
+}
+
+// directConversation finds the pair's direct conversation, or starts one
+// dated from their first legacy message.
+func directConversation(ctx context.Context, db *database.Database, key string, legacy bson.M, userA, userB string) (*models.Conversation, error) {
+	// Reuse the conversation if the pair already started one
+	var conversation models.Conversation
+	err := db.Conversations().FindOne(ctx, bson.M{"direct_key": key}).Decode(&conversation)
+	if err == nil {
+		return &conversation, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	var first models.Message
+	err = db.Messages().FindOne(ctx, legacy, options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}})).Decode(&first)
+	if err != nil {
+		return nil, err
+	}
+
+	conversation = models.Conversation{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      "direct",
+		DirectKey: key,
+		Participants: []models.ConversationParticipant{
+			{UserID: userA, Role: "member", JoinedAt: first.CreatedAt},
+		},
+		CreatedBy: first.SenderID,
+		CreatedAt: first.CreatedAt,
+		UpdatedAt: time.Now(),
+	}
+
+	// Notes to self only have one participant
+	if userB != userA {
+		conversation.Participants = append(conversation.Participants, models.ConversationParticipant{
+			UserID: userB, Role: "member", JoinedAt: first.CreatedAt,
+		})
+	}
+
+	_, err = db.Conversations().InsertOne(ctx, conversation)
+	if mongo.IsDuplicateKeyError(err) {
+		// Started meanwhile, by the pair or another run
+		err = db.Conversations().FindOne(ctx, bson.M{"direct_key": key}).Decode(&conversation)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &conversation, nil
+}
@@ -0,0 +1,82 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrateCopyrightDisablements moves content disabled by copyright notices
+// from the moderation status, which notices used to share with moderators,
+// to its own flag. The moderation status is set back to what moderators
+// last decided. Only content without the flag is touched, so it is safe to
+// run on every startup.
+func MigrateCopyrightDisablements(db *database.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cursor, err := db.DMCANotices().Find(ctx, bson.M{
+		"status": bson.M{"$in": []string{"disabled", "counter_noticed", "upheld", "restoring"}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var notice models.DMCANotice
+		if err := cursor.Decode(&notice); err != nil {
+			return err
+		}
+
+		var collection *mongo.Collection
+		switch notice.TargetType {
+		case "post":
+			collection = db.Posts()
+		case "listing":
+			collection = db.NFTListings()
+		default:
+			continue
+		}
+
+		set := bson.M{"dmca_disabled": true}
+		update := bson.M{"$set": set}
+
+		// Notices record their actions without a moderator
+		var action models.ModerationAction
+		err := db.ModerationActions().FindOne(
+			ctx,
+			bson.M{
+				"target_type":  notice.TargetType,
+				"target_id":    notice.TargetID,
+				"action":       bson.M{"$in": []string{"hide", "remove", "restore"}},
+				"moderator_id": bson.M{"$nin": []interface{}{"", nil}},
+			},
+			options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}}),
+		).Decode(&action)
+		switch {
+		case err == mongo.ErrNoDocuments || (err == nil && action.Action == "restore"):
+			update["$unset"] = bson.M{"moderation_status": ""}
+		case err != nil:
+			return err
+		case action.Action == "hide":
+			set["moderation_status"] = "hidden"
+		}
+
+		_, err = collection.UpdateOne(
+			ctx,
+			bson.M{"_id": notice.TargetID, "dmca_disabled": bson.M{"$exists": false}},
+			update,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
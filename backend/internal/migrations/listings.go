@@ -0,0 +1,86 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// DedupeOpenListings cancels all but one open listing of each post, so the
+// index allowing one active or settling listing per post can be built. It
+// keeps a listing being settled, then one with bids, then the oldest. It
+// must run before the indexes are ensured, and is safe to run on every
+// startup.
+func DedupeOpenListings(db *database.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cursor, err := db.NFTListings().Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"status": bson.M{"$in": []string{"active", "settling"}}}},
+		{"$sort": bson.M{"created_at": 1}},
+		{"$group": bson.M{
+			"_id":      "$post_id",
+			"listings": bson.M{"$push": "$$ROOT"},
+			"count":    bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			PostID   string              `bson:"_id"`
+			Listings []models.NFTListing `bson:"listings"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+
+		keep := &group.Listings[0]
+		for i := range group.Listings {
+			listing := &group.Listings[i]
+			if openListingRank(listing) > openListingRank(keep) {
+				keep = listing
+			}
+		}
+
+		var cancelled []string
+		for _, listing := range group.Listings {
+			if listing.ID != keep.ID {
+				cancelled = append(cancelled, listing.ID)
+			}
+		}
+
+		_, err := db.NFTListings().UpdateMany(
+			ctx,
+			bson.M{"_id": bson.M{"$in": cancelled}, "status": "active"},
+			bson.M{"$set": bson.M{"status": "cancelled", "updated_at": time.Now()}},
+		)
+		if err != nil {
+			return err
+		}
+		log.Printf("Cancelled duplicate listings %v of post %s, keeping %s", cancelled, group.PostID, keep.ID)
+	}
+
+	return cursor.Err()
+}
+
+// openListingRank orders the open listings of a post by which to keep.
+// Ties go to the oldest, which the listings are sorted by.
+func openListingRank(listing *models.NFTListing) int {
+	switch {
+	case listing.Status == "settling":
+		return 2
+	case listing.HighestBidderID != "":
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// claim takes the named migration for this instance for up to ttl, so
+// instances starting together don't run it at once. It reports false if
+// another instance holds it or it has already completed. A claim left by a
+// crashed instance lapses after ttl.
+func claim(ctx context.Context, db *database.Database, name string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+
+	// The upsert fails on the unique _id when the record exists but
+	// doesn't match
+	_, err := db.Migrations().UpdateOne(
+		ctx,
+		bson.M{
+			"_id":          name,
+			"completed_at": bson.M{"$exists": false},
+			"locked_until": bson.M{"$lte": now},
+		},
+		bson.M{"$set": bson.M{"locked_until": now.Add(ttl)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// complete records that the named migration has run, so it is skipped from
+// then on.
+func complete(ctx context.Context, db *database.Database, name string) error {
+	_, err := db.Migrations().UpdateOne(
+		ctx,
+		bson.M{"_id": name},
+		bson.M{
+			"$set":   bson.M{"completed_at": time.Now()},
+			"$unset": bson.M{"locked_until": ""},
+		},
+	)
+	return err
+}
+
+// release gives up a claim after a failed run so the next start retries at
+// once. It has its own timeout, as the run may have failed on the caller's.
+func release(db *database.Database, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Migrations().UpdateOne(
+		ctx,
+		bson.M{"_id": name},
+		bson.M{"$set": bson.M{"locked_until": time.Now()}},
+	)
+	return err
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BackfillUsernameKeys sets the username key of users created before
+// usernames were unique. Where several users share a name, the oldest
+// account keeps it and the others are left without a key, and so can't be
+// found by username, until they pick a new one. Only users without a key
+// are touched, so it is safe to run on every startup.
+func BackfillUsernameKeys(db *database.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cursor, err := db.Users().Find(
+		ctx,
+		bson.M{"username_key": bson.M{"$exists": false}},
+		options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetProjection(bson.M{"_id": 1, "username": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+
+		_, err := db.Users().UpdateOne(
+			ctx,
+			bson.M{"_id": user.ID},
+			bson.M{"$set": bson.M{"username_key": models.UsernameKey(user.Username)}},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			log.Printf("Username %q of user %s is taken by an older account", user.Username, user.ID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
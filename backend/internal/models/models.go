@@ -1,92 +1,452 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
+// UsernameChangeCooldown is how long users wait between username changes,
+// so a name can't be bounced between accounts to impersonate someone.
+const UsernameChangeCooldown = 30 * 24 * time.Hour
+
+// UsernameKey is the form usernames are unique and looked up by, so
+// names differing only in case can't coexist.
+func UsernameKey(username string) string {
+	return strings.ToLower(username)
+}
+
 type User struct {
-	ID                string    `json:"id" bson:"_id,omitempty"`
-	Username          string    `json:"username" bson:"username"`
-	Email             string    `json:"email" bson:"email"`
-	PasswordHash      string    `json:"-" bson:"password_hash"`
-	ProfilePhoto      string    `json:"profilePhoto,omitempty" bson:"profile_photo,omitempty"`
-	Bio               string    `json:"bio,omitempty" bson:"bio,omitempty"`
-	Website           string    `json:"website,omitempty" bson:"website,omitempty"`
-	Location          string    `json:"location,omitempty" bson:"location,omitempty"`
-	FollowersCount    int       `json:"followersCount" bson:"followers_count"`
-	FollowingCount    int       `json:"followingCount" bson:"following_count"`
-	IsBusinessAccount bool      `json:"isBusinessAccount" bson:"is_business_account"`
-	IsVerified        bool      `json:"isVerified" bson:"is_verified"`
-	CreatedAt         time.Time `json:"createdAt" bson:"created_at"`
-	UpdatedAt         time.Time `json:"updatedAt" bson:"updated_at"`
+	ID                string      `json:"id" bson:"_id,omitempty"`
+	Username          string      `json:"username" bson:"username"`
+	UsernameKey       string      `json:"-" bson:"username_key,omitempty"` // see UsernameKey
+	UsernameChangedAt *time.Time  `json:"-" bson:"username_changed_at,omitempty"`
+	Email             string      `json:"email" bson:"email"`
+	PasswordHash      string      `json:"-" bson:"password_hash"`
+	ProfilePhoto      string      `json:"profilePhoto,omitempty" bson:"profile_photo,omitempty"`
+	Bio               string      `json:"bio,omitempty" bson:"bio,omitempty"`
+	Website           string      `json:"website,omitempty" bson:"website,omitempty"`
+	Location          string      `json:"location,omitempty" bson:"location,omitempty"`
+	FollowersCount    int         `json:"followersCount" bson:"followers_count"`
+	FollowingCount    int         `json:"followingCount" bson:"following_count"`
+	IsBusinessAccount bool        `json:"isBusinessAccount" bson:"is_business_account"`
+	IsVerified        bool        `json:"isVerified" bson:"is_verified"`
+	WalletAddress     string      `json:"walletAddress,omitempty" bson:"wallet_address,omitempty"`
+	DMPrivacy         string      `json:"dmPrivacy,omitempty" bson:"dm_privacy,omitempty"` // everyone (default), followers, nobody
+	IsPrivate         bool        `json:"isPrivate" bson:"is_private"`
+	Storefront        *Storefront `json:"storefront,omitempty" bson:"storefront,omitempty"` // business accounts only
+	Roles             []string    `json:"roles,omitempty" bson:"roles,omitempty"`           // see UserRoles
+	CommentFilters    []string    `json:"-" bson:"comment_filters,omitempty"`               // lowercase keywords that hold comments on the user's posts
+	NotificationsOff  []string    `json:"-" bson:"notifications_off,omitempty"`             // notification types the user turned off
+	WarningsCount     int         `json:"-" bson:"warnings_count,omitempty"`
+	Suspension        *Suspension `json:"-" bson:"suspension,omitempty"`
+	CreatedAt         time.Time   `json:"createdAt" bson:"created_at"`
+	UpdatedAt         time.Time   `json:"updatedAt" bson:"updated_at"`
 }
 
 type Post struct {
-	ID             string      `json:"id" bson:"_id,omitempty"`
-	UserID         string      `json:"userId" bson:"user_id"`
-	Username       string      `json:"username" bson:"username"`
-	UserAvatar     string      `json:"userAvatar,omitempty" bson:"user_avatar,omitempty"`
-	UserLocation   string      `json:"userLocation,omitempty" bson:"user_location,omitempty"`
-	Media          []MediaItem `json:"media" bson:"media"`
-	Description    string      `json:"description" bson:"description"`
-	Category       string      `json:"category" bson:"category"` // lot, design, reavise
-	Hashtags       []string    `json:"hashtags" bson:"hashtags"`
-	LikesCount     int         `json:"likesCount" bson:"likes_count"`
-	CommentsCount  int         `json:"commentsCount" bson:"comments_count"`
-	CreatedAt      time.Time   `json:"createdAt" bson:"created_at"`
-	UpdatedAt      time.Time   `json:"updatedAt" bson:"updated_at"`
+	ID               string      `json:"id" bson:"_id,omitempty"`
+	UserID           string      `json:"userId" bson:"user_id"`
+	Username         string      `json:"username" bson:"username"`
+	UserAvatar       string      `json:"userAvatar,omitempty" bson:"user_avatar,omitempty"`
+	UserLocation     string      `json:"userLocation,omitempty" bson:"user_location,omitempty"`
+	Media            []MediaItem `json:"media" bson:"media"`
+	Description      string      `json:"description" bson:"description"`
+	Category         string      `json:"category" bson:"category"`     // see PostCategories
+	Categories       []string    `json:"categories" bson:"categories"` // Category and every media's category
+	Hashtags         []string    `json:"hashtags" bson:"hashtags"`
+	LikesCount       int         `json:"likesCount" bson:"likes_count"`
+	CommentsCount    int         `json:"commentsCount" bson:"comments_count"`
+	CommentsDisabled bool        `json:"commentsDisabled,omitempty" bson:"comments_disabled,omitempty"`
+	AuthorPrivate    bool        `json:"-" bson:"author_private,omitempty"`                             // copy of the author's IsPrivate, kept by ProfileSyncService
+	ModerationStatus string      `json:"moderationStatus,omitempty" bson:"moderation_status,omitempty"` // hidden, removed
+	ProcessingStatus string      `json:"processingStatus,omitempty" bson:"processing_status,omitempty"` // processing, failed; unpublished until its media is ready
+	Revision         int         `json:"revision,omitempty" bson:"revision,omitempty"`                  // see PostRevision
+	EditedAt         *time.Time  `json:"editedAt,omitempty" bson:"edited_at,omitempty"`
+	AuthorSuspended  bool        `json:"-" bson:"author_suspended,omitempty"`
+	SuspensionEndsAt time.Time   `json:"-" bson:"author_suspension_ends_at,omitempty"`
+	DMCADisabled     bool        `json:"-" bson:"dmca_disabled,omitempty"`
+	DeletedAt        *time.Time  `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"` // restorable until PostRestoreWindow has passed
+	PurgedAt         *time.Time  `json:"-" bson:"purged_at,omitempty"`                    // set on the tombstone left by PostService
+	CreatedAt        time.Time   `json:"createdAt" bson:"created_at"`
+	UpdatedAt        time.Time   `json:"updatedAt" bson:"updated_at"`
+}
+
+// PostRestoreWindow is how long a deleted post can be restored before it and
+// its likes and comments are purged.
+const PostRestoreWindow = 30 * 24 * time.Hour
+
+// PostRevision is a snapshot of a post's content. Revision 1 is the post as
+// created and every edit adds the next, so buyers can see the post as it was
+// when they bought.
+type PostRevision struct {
+	ID          string      `json:"id" bson:"_id,omitempty"`
+	PostID      string      `json:"postId" bson:"post_id"`
+	Revision    int         `json:"revision" bson:"revision"`
+	Media       []MediaItem `json:"media" bson:"media"`
+	Description string      `json:"description" bson:"description"`
+	Category    string      `json:"category" bson:"category"`
+	Hashtags    []string    `json:"hashtags" bson:"hashtags"`
+	CreatedAt   time.Time   `json:"createdAt" bson:"created_at"`
+}
+
+// CurrentRevision is the number of a post's latest revision.
+func CurrentRevision(post *Post) int {
+	// Posts created before revisions were kept have never been edited
+	if post.Revision == 0 {
+		return 1
+	}
+	return post.Revision
+}
+
+// RevisionOf snapshots a post's current content. The caller assigns the ID.
+func RevisionOf(post *Post) PostRevision {
+	return PostRevision{
+		PostID:      post.ID,
+		Revision:    CurrentRevision(post),
+		Media:       post.Media,
+		Description: post.Description,
+		Category:    post.Category,
+		Hashtags:    post.Hashtags,
+		CreatedAt:   time.Now(),
+	}
 }
 
 type MediaItem struct {
-	URL      string `json:"url" bson:"url"`
-	Type     string `json:"type" bson:"type"` // image, video
-	Category string `json:"category,omitempty" bson:"category,omitempty"`
+	MediaID    string           `json:"mediaId,omitempty" bson:"media_id,omitempty"`
+	URL        string           `json:"url" bson:"url"`
+	Type       string           `json:"type" bson:"type"`                             // image, video
+	Category   string           `json:"category,omitempty" bson:"category,omitempty"` // see PostCategories
+	Width      int              `json:"width,omitempty" bson:"width,omitempty"`
+	Height     int              `json:"height,omitempty" bson:"height,omitempty"`
+	Blurhash   string           `json:"blurhash,omitempty" bson:"blurhash,omitempty"`
+	Renditions []MediaRendition `json:"renditions,omitempty" bson:"renditions,omitempty"`
+	Duration   float64          `json:"duration,omitempty" bson:"duration,omitempty"` // seconds, videos only
+	Poster     *MediaRendition  `json:"poster,omitempty" bson:"poster,omitempty"`
 }
 
+// Comment is a comment on a post, or a reply to one when ParentID is set.
+// Threads are one level deep: replies to a reply belong to its parent.
 type Comment struct {
-	ID         string    `json:"id" bson:"_id,omitempty"`
-	PostID     string    `json:"postId" bson:"post_id"`
-	UserID     string    `json:"userId" bson:"user_id"`
-	Username   string    `json:"username" bson:"username"`
-	UserAvatar string    `json:"userAvatar,omitempty" bson:"user_avatar,omitempty"`
-	Text       string    `json:"text" bson:"text"`
-	CreatedAt  time.Time `json:"createdAt" bson:"created_at"`
+	ID               string           `json:"id" bson:"_id,omitempty"`
+	PostID           string           `json:"postId" bson:"post_id"`
+	ParentID         string           `json:"parentId,omitempty" bson:"parent_id,omitempty"`
+	UserID           string           `json:"userId" bson:"user_id"`
+	Username         string           `json:"username" bson:"username"`
+	UserAvatar       string           `json:"userAvatar,omitempty" bson:"user_avatar,omitempty"`
+	Text             string           `json:"text" bson:"text"`
+	Mentions         []CommentMention `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	LikesCount       int              `json:"likesCount" bson:"likes_count"`
+	RepliesCount     int              `json:"repliesCount" bson:"replies_count"`
+	ModerationStatus string           `json:"moderationStatus,omitempty" bson:"moderation_status,omitempty"` // hidden, removed
+	OwnerStatus      string           `json:"ownerStatus,omitempty" bson:"owner_status,omitempty"`           // see CommentOwnerStatuses
+	EditedAt         *time.Time       `json:"editedAt,omitempty" bson:"edited_at,omitempty"`
+	AuthorSuspended  bool             `json:"-" bson:"author_suspended,omitempty"`
+	SuspensionEndsAt time.Time        `json:"-" bson:"author_suspension_ends_at,omitempty"`
+	CreatedAt        time.Time        `json:"createdAt" bson:"created_at"`
 }
 
+// CommentOwnerStatuses are the ways a post's owner can keep a comment off
+// their post. Such comments are only shown to their author and the post's
+// owner.
+var CommentOwnerStatuses = map[string]bool{
+	"held":   true, // matched one of the owner's CommentFilters; awaiting review
+	"hidden": true, // hidden by the owner
+}
+
+// MaxCommentFilters is how many keywords a user can filter comments by.
+const MaxCommentFilters = 100
+
+// CommentMention links an @username in a comment's text to the user.
+type CommentMention struct {
+	UserID   string `json:"userId" bson:"user_id"`
+	Username string `json:"username" bson:"username"`
+}
+
+type CommentLike struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	CommentID string    `json:"commentId" bson:"comment_id"`
+	PostID    string    `json:"postId" bson:"post_id"`
+	UserID    string    `json:"userId" bson:"user_id"`
+	CreatedAt time.Time `json:"createdAt" bson:"created_at"`
+}
+
+// Message belongs to a conversation. ReceiverID, IsRead and ReadAt are only
+// set for direct conversations; group read state lives on the participants.
 type Message struct {
-	ID         string    `json:"id" bson:"_id,omitempty"`
-	SenderID   string    `json:"senderId" bson:"sender_id"`
-	ReceiverID string    `json:"receiverId" bson:"receiver_id"`
-	Text       string    `json:"text" bson:"text"`
-	IsRead     bool      `json:"isRead" bson:"is_read"`
-	CreatedAt  time.Time `json:"createdAt" bson:"created_at"`
+	ID               string              `json:"id" bson:"_id,omitempty"`
+	ConversationID   string              `json:"conversationId" bson:"conversation_id"`
+	SenderID         string              `json:"senderId" bson:"sender_id"`
+	ReceiverID       string              `json:"receiverId,omitempty" bson:"receiver_id,omitempty"`
+	Type             string              `json:"type" bson:"type"` // text, attachment, post, offer
+	Text             string              `json:"text" bson:"text"`
+	Attachments      []MessageAttachment `json:"attachments,omitempty" bson:"attachments,omitempty"`
+	SharedPost       *SharedPost         `json:"sharedPost,omitempty" bson:"shared_post,omitempty"`
+	Offer            *Offer              `json:"offer,omitempty" bson:"offer,omitempty"`
+	IsRead           bool                `json:"isRead" bson:"is_read"`
+	ReadAt           *time.Time          `json:"readAt,omitempty" bson:"read_at,omitempty"`
+	ModerationStatus string              `json:"moderationStatus,omitempty" bson:"moderation_status,omitempty"` // hidden, removed
+	CreatedAt        time.Time           `json:"createdAt" bson:"created_at"`
 }
 
-type Transaction struct {
-	ID            string    `json:"id" bson:"_id,omitempty"`
-	BuyerID       string    `json:"buyerId" bson:"buyer_id"`
-	SellerID      string    `json:"sellerId" bson:"seller_id"`
-	PostID        string    `json:"postId" bson:"post_id"`
-	Amount        float64   `json:"amount" bson:"amount"`
-	Status        string    `json:"status" bson:"status"` // pending, completed, cancelled
-	PaymentMethod string    `json:"paymentMethod" bson:"payment_method"` // stripe, paypal
-	PaymentID     string    `json:"paymentId,omitempty" bson:"payment_id,omitempty"`
-	CreatedAt     time.Time `json:"createdAt" bson:"created_at"`
-	UpdatedAt     time.Time `json:"updatedAt" bson:"updated_at"`
+// MessageAttachment is a file uploaded for use in messages. Uploads are stored
+// in the attachments collection and copied onto the message that uses them.
+// The files are private; URL points at the download endpoint, which checks
+// the reader is in a conversation the attachment was sent to.
+type MessageAttachment struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	UploaderID  string    `json:"uploaderId" bson:"uploader_id"`
+	URL         string    `json:"url" bson:"url"`
+	Key         string    `json:"-" bson:"key"`
+	Type        string    `json:"type" bson:"type"` // image, video
+	ContentType string    `json:"contentType" bson:"content_type"`
+	Size        int64     `json:"size" bson:"size"`
+	Filename    string    `json:"filename" bson:"filename"`
+	CreatedAt   time.Time `json:"createdAt" bson:"created_at"`
 }
 
-type NFTListing struct {
+// SharedPost is a snapshot of a post shared in a message.
+type SharedPost struct {
+	PostID       string `json:"postId" bson:"post_id"`
+	UserID       string `json:"userId" bson:"user_id"`
+	Username     string `json:"username" bson:"username"`
+	Description  string `json:"description" bson:"description"`
+	Category     string `json:"category" bson:"category"`
+	ThumbnailURL string `json:"thumbnailUrl,omitempty" bson:"thumbnail_url,omitempty"`
+	Revision     int    `json:"revision,omitempty" bson:"revision,omitempty"`
+}
+
+// Offer is a seller's price proposal for a post, sent as a message card.
+// Accepting it creates a Transaction.
+type Offer struct {
+	PostID        string     `json:"postId" bson:"post_id"`
+	SellerID      string     `json:"sellerId" bson:"seller_id"`
+	BuyerID       string     `json:"buyerId" bson:"buyer_id"` // the only participant who can accept or decline
+	Price         float64    `json:"price" bson:"price"`
+	Note          string     `json:"note,omitempty" bson:"note,omitempty"`
+	Status        string     `json:"status" bson:"status"` // pending, accepted, declined, withdrawn
+	RespondedBy   string     `json:"respondedBy,omitempty" bson:"responded_by,omitempty"`
+	RespondedAt   *time.Time `json:"respondedAt,omitempty" bson:"responded_at,omitempty"`
+	TransactionID string     `json:"transactionId,omitempty" bson:"transaction_id,omitempty"`
+}
+
+type Conversation struct {
+	ID            string                    `json:"id" bson:"_id,omitempty"`
+	Type          string                    `json:"type" bson:"type"` // direct, group
+	Title         string                    `json:"title,omitempty" bson:"title,omitempty"`
+	DirectKey     string                    `json:"-" bson:"direct_key,omitempty"`
+	Participants  []ConversationParticipant `json:"participants" bson:"participants"`
+	LastMessage   *Message                  `json:"lastMessage,omitempty" bson:"last_message,omitempty"`
+	LastMessageAt time.Time                 `json:"lastMessageAt" bson:"last_message_at"`
+	CreatedBy     string                    `json:"createdBy" bson:"created_by"`
+	RequestedBy   string                    `json:"requestedBy,omitempty" bson:"requested_by,omitempty"` // sender of a pending message request
+	CreatedAt     time.Time                 `json:"createdAt" bson:"created_at"`
+	UpdatedAt     time.Time                 `json:"updatedAt" bson:"updated_at"`
+}
+
+type ConversationParticipant struct {
+	UserID            string     `json:"userId" bson:"user_id"`
+	Role              string     `json:"role" bson:"role"` // owner, member, buyer, seller, designer, maker
+	UnreadCount       int        `json:"unreadCount" bson:"unread_count"`
+	LastReadMessageID string     `json:"lastReadMessageId,omitempty" bson:"last_read_message_id,omitempty"`
+	LastReadAt        *time.Time `json:"lastReadAt,omitempty" bson:"last_read_at,omitempty"`
+	Requested         bool       `json:"requested,omitempty" bson:"requested,omitempty"` // added to a group by someone they don't follow; pending in their message requests
+	JoinedAt          time.Time  `json:"joinedAt" bson:"joined_at"`
+}
+
+// ConversationRoles are the roles a conversation participant can hold. Only
+// the owner can rename the conversation or manage participants.
+var ConversationRoles = map[string]bool{
+	"owner":    true,
+	"member":   true,
+	"buyer":    true,
+	"seller":   true,
+	"designer": true,
+	"maker":    true,
+}
+
+// DMPrivacySettings control who can start a direct conversation with a user.
+// Conversations from users the recipient doesn't follow start as message
+// requests.
+var DMPrivacySettings = map[string]bool{
+	"everyone":  true,
+	"followers": true,
+	"nobody":    true,
+}
+
+// PostCategories are the content categories posts and their media are
+// labelled with: The Lot (materials and used items), Design (ideas and
+// proposals) and ReaVise (finished work).
+var PostCategories = map[string]bool{
+	"lot":     true,
+	"design":  true,
+	"reavise": true,
+}
+
+// CategoriesOf lists a post's category followed by the other categories its
+// media carry, so the post is listed under each of them.
+func CategoriesOf(category string, media []MediaItem) []string {
+	categories := []string{category}
+	seen := map[string]bool{category: true}
+	for _, item := range media {
+		if item.Category != "" && !seen[item.Category] {
+			seen[item.Category] = true
+			categories = append(categories, item.Category)
+		}
+	}
+	return categories
+}
+
+// UserRoles are the staff roles that can be granted to a user. Moderators
+// work the report queue; admins can also manage users.
+var UserRoles = map[string]bool{
+	"moderator": true,
+	"admin":     true,
+}
+
+// DirectConversationKey identifies the direct conversation between two users
+// regardless of who started it.
+func DirectConversationKey(userA, userB string) string {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	return userA + ":" + userB
+}
+
+// Participant returns the participant entry for userID, or nil if the user
+// is not part of the conversation.
+func (c *Conversation) Participant(userID string) *ConversationParticipant {
+	for i := range c.Participants {
+		if c.Participants[i].UserID == userID {
+			return &c.Participants[i]
+		}
+	}
+	return nil
+}
+
+// IsRequestFor reports whether the conversation is pending in userID's
+// message requests: a direct conversation someone else started, or a group
+// they were added to by someone they don't follow.
+func (c *Conversation) IsRequestFor(userID string) bool {
+	if c.RequestedBy != "" && c.RequestedBy != userID {
+		return true
+	}
+	participant := c.Participant(userID)
+	return participant != nil && participant.Requested
+}
+
+// ParticipantIDs returns the IDs of every participant.
+func (c *Conversation) ParticipantIDs() []string {
+	ids := make([]string, 0, len(c.Participants))
+	for _, p := range c.Participants {
+		ids = append(ids, p.UserID)
+	}
+	return ids
+}
+
+// UserSummary is the public subset of a user shown alongside other content.
+type UserSummary struct {
+	ID           string `json:"id" bson:"_id"`
+	Username     string `json:"username" bson:"username"`
+	ProfilePhoto string `json:"profilePhoto,omitempty" bson:"profile_photo,omitempty"`
+	IsVerified   bool   `json:"isVerified" bson:"is_verified"`
+}
+
+type Transaction struct {
 	ID             string    `json:"id" bson:"_id,omitempty"`
+	BuyerID        string    `json:"buyerId" bson:"buyer_id"`
+	SellerID       string    `json:"sellerId" bson:"seller_id"`
 	PostID         string    `json:"postId" bson:"post_id"`
-	OwnerID        string    `json:"ownerId" bson:"owner_id"`
-	StartingBid    float64   `json:"startingBid" bson:"starting_bid"`
-	CurrentBid     float64   `json:"currentBid" bson:"current_bid"`
-	AuctionEndDate time.Time `json:"auctionEndDate" bson:"auction_end_date"`
-	Status         string    `json:"status" bson:"status"` // active, sold, expired
+	Amount         float64   `json:"amount" bson:"amount"`
+	Status         string    `json:"status" bson:"status"`                // pending, completed, cancelled
+	PaymentMethod  string    `json:"paymentMethod" bson:"payment_method"` // stripe, paypal
+	PaymentID      string    `json:"paymentId,omitempty" bson:"payment_id,omitempty"`
+	OfferMessageID string    `json:"offerMessageId,omitempty" bson:"offer_message_id,omitempty"`
+	PostRevision   int       `json:"postRevision,omitempty" bson:"post_revision,omitempty"` // the post as the buyer saw it
 	CreatedAt      time.Time `json:"createdAt" bson:"created_at"`
 	UpdatedAt      time.Time `json:"updatedAt" bson:"updated_at"`
 }
 
+// NFT is the platform's record of a minted post. OwnerID follows the token
+// through sales; CreatorID and RoyaltyBps are fixed at mint.
+type NFT struct {
+	ID              string    `json:"id" bson:"_id,omitempty"`
+	PostID          string    `json:"postId" bson:"post_id"`
+	CreatorID       string    `json:"creatorId" bson:"creator_id"`
+	OwnerID         string    `json:"ownerId" bson:"owner_id"`
+	Chain           string    `json:"chain" bson:"chain"`
+	ContractAddress string    `json:"contractAddress" bson:"contract_address"`
+	TokenID         string    `json:"tokenId" bson:"token_id"`
+	MintTxHash      string    `json:"mintTxHash" bson:"mint_tx_hash"`
+	RoyaltyBps      int       `json:"royaltyBps" bson:"royalty_bps"`
+	Status          string    `json:"status,omitempty" bson:"status,omitempty"` // minting until the token is minted and recorded
+	CreatedAt       time.Time `json:"createdAt" bson:"created_at"`
+	UpdatedAt       time.Time `json:"updatedAt" bson:"updated_at"`
+}
+
+type NFTListing struct {
+	ID               string    `json:"id" bson:"_id,omitempty"`
+	PostID           string    `json:"postId" bson:"post_id"`
+	NFTID            string    `json:"nftId,omitempty" bson:"nft_id,omitempty"`
+	OwnerID          string    `json:"ownerId" bson:"owner_id"`
+	CreatorID        string    `json:"creatorId,omitempty" bson:"creator_id,omitempty"`
+	Description      string    `json:"description,omitempty" bson:"description,omitempty"`
+	StartingBid      float64   `json:"startingBid" bson:"starting_bid"`
+	CurrentBid       float64   `json:"currentBid" bson:"current_bid"`
+	HighestBidderID  string    `json:"highestBidderId,omitempty" bson:"highest_bidder_id,omitempty"`
+	AuctionEndDate   time.Time `json:"auctionEndDate" bson:"auction_end_date"`
+	Status           string    `json:"status" bson:"status"` // active, settling, sold, expired, cancelled
+	Chain            string    `json:"chain,omitempty" bson:"chain,omitempty"`
+	ContractAddress  string    `json:"contractAddress,omitempty" bson:"contract_address,omitempty"`
+	TokenID          string    `json:"tokenId,omitempty" bson:"token_id,omitempty"`
+	MintTxHash       string    `json:"mintTxHash,omitempty" bson:"mint_tx_hash,omitempty"`
+	WinnerID         string    `json:"winnerId,omitempty" bson:"winner_id,omitempty"`
+	TransferTxHash   string    `json:"transferTxHash,omitempty" bson:"transfer_tx_hash,omitempty"`
+	RoyaltyBps       int       `json:"royaltyBps" bson:"royalty_bps"`
+	RoyaltyAmount    float64   `json:"royaltyAmount,omitempty" bson:"royalty_amount,omitempty"`
+	ModerationStatus string    `json:"moderationStatus,omitempty" bson:"moderation_status,omitempty"` // hidden, removed
+	SettlingAt       time.Time `json:"-" bson:"settling_at,omitempty"`                                // when a settlement last claimed the listing
+	AuthorSuspended  bool      `json:"-" bson:"author_suspended,omitempty"`
+	SuspensionEndsAt time.Time `json:"-" bson:"author_suspension_ends_at,omitempty"`
+	PreRemovalStatus string    `json:"-" bson:"pre_removal_status,omitempty"`
+	DMCADisabled     bool      `json:"-" bson:"dmca_disabled,omitempty"`
+	CreatedAt        time.Time `json:"createdAt" bson:"created_at"`
+	UpdatedAt        time.Time `json:"updatedAt" bson:"updated_at"`
+}
+
+// NFTTransfer is one change of ownership of an NFT. Mints have an empty
+// FromUserID and no price.
+type NFTTransfer struct {
+	ID         string    `json:"id" bson:"_id,omitempty"`
+	NFTID      string    `json:"nftId" bson:"nft_id"`
+	FromUserID string    `json:"fromUserId,omitempty" bson:"from_user_id,omitempty"`
+	ToUserID   string    `json:"toUserId" bson:"to_user_id"`
+	ListingID  string    `json:"listingId,omitempty" bson:"listing_id,omitempty"`
+	Price      float64   `json:"price" bson:"price"`
+	TxHash     string    `json:"txHash" bson:"tx_hash"`
+	CreatedAt  time.Time `json:"createdAt" bson:"created_at"`
+}
+
+// NFTListingEdit is an audit record of one field changed on a listing.
+type NFTListingEdit struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	ListingID string    `json:"listingId" bson:"listing_id"`
+	UserID    string    `json:"userId" bson:"user_id"`
+	Field     string    `json:"field" bson:"field"` // description, auctionEndDate
+	OldValue  string    `json:"oldValue" bson:"old_value"`
+	NewValue  string    `json:"newValue" bson:"new_value"`
+	CreatedAt time.Time `json:"createdAt" bson:"created_at"`
+}
+
+type Earning struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	UserID    string    `json:"userId" bson:"user_id"`
+	Type      string    `json:"type" bson:"type"` // sale, royalty
+	NFTID     string    `json:"nftId" bson:"nft_id"`
+	ListingID string    `json:"listingId" bson:"listing_id"`
+	Amount    float64   `json:"amount" bson:"amount"`
+	CreatedAt time.Time `json:"createdAt" bson:"created_at"`
+}
+
 type Like struct {
 	ID        string    `json:"id" bson:"_id,omitempty"`
 	UserID    string    `json:"userId" bson:"user_id"`
@@ -100,3 +460,320 @@ type Follow struct {
 	FolloweeID string    `json:"followeeId" bson:"followee_id"`
 	CreatedAt  time.Time `json:"createdAt" bson:"created_at"`
 }
+
+// FollowRequest is a pending request to follow a private account.
+type FollowRequest struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	RequesterID string    `json:"requesterId" bson:"requester_id"`
+	TargetID    string    `json:"targetId" bson:"target_id"`
+	CreatedAt   time.Time `json:"createdAt" bson:"created_at"`
+}
+
+// Block hides two users from each other in both directions.
+type Block struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	BlockerID string    `json:"blockerId" bson:"blocker_id"`
+	BlockedID string    `json:"blockedId" bson:"blocked_id"`
+	CreatedAt time.Time `json:"createdAt" bson:"created_at"`
+}
+
+// Suspension blocks a user from signing in and hides their content. Until is
+// nil for a permanent ban.
+type Suspension struct {
+	Reason      string     `json:"reason" bson:"reason"`
+	Until       *time.Time `json:"until,omitempty" bson:"until,omitempty"`
+	SuspendedBy string     `json:"suspendedBy" bson:"suspended_by"`
+	ReportID    string     `json:"-" bson:"report_id,omitempty"` // the report a moderator acted on, if any
+	CreatedAt   time.Time  `json:"createdAt" bson:"created_at"`
+}
+
+// Active reports whether the suspension is in force at the given time.
+func (s *Suspension) Active(now time.Time) bool {
+	return s != nil && (s.Until == nil || s.Until.After(now))
+}
+
+// Report is a user's complaint about a piece of content or another user,
+// triaged by moderators.
+type Report struct {
+	ID             string     `json:"id" bson:"_id,omitempty"`
+	ReporterID     string     `json:"reporterId" bson:"reporter_id"`
+	TargetType     string     `json:"targetType" bson:"target_type"` // post, comment, user, message, listing
+	TargetID       string     `json:"targetId" bson:"target_id"`
+	TargetOwnerID  string     `json:"targetOwnerId" bson:"target_owner_id"`
+	Reason         string     `json:"reason" bson:"reason"`
+	Details        string     `json:"details,omitempty" bson:"details,omitempty"`
+	Status         string     `json:"status" bson:"status"` // open, reviewing, resolved, dismissed
+	AssignedTo     string     `json:"assignedTo,omitempty" bson:"assigned_to,omitempty"`
+	Action         string     `json:"action,omitempty" bson:"action,omitempty"`
+	ResolutionNote string     `json:"resolutionNote,omitempty" bson:"resolution_note,omitempty"`
+	ResolvedBy     string     `json:"resolvedBy,omitempty" bson:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty" bson:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt" bson:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" bson:"updated_at"`
+}
+
+// ReportTargetTypes are the kinds of things that can be reported.
+var ReportTargetTypes = map[string]bool{
+	"post":    true,
+	"comment": true,
+	"user":    true,
+	"message": true,
+	"listing": true,
+}
+
+// ReportReasons are the reason codes a reporter can choose from.
+var ReportReasons = map[string]bool{
+	"spam":                  true,
+	"harassment":            true,
+	"hate_speech":           true,
+	"nudity":                true,
+	"violence":              true,
+	"intellectual_property": true,
+	"counterfeit":           true,
+	"fraud":                 true,
+	"other":                 true,
+}
+
+// RepeatInfringementReason is the reason of reports the system files when a
+// user reaches services.StrikeLimit copyright strikes, asking a moderator to
+// confirm their suspension. Reporters can't choose it.
+const RepeatInfringementReason = "repeat_infringement"
+
+// ModerationAction records one action taken by a moderator. Content actions
+// (hide, remove, restore) apply to the target; user actions (warn, suspend)
+// apply to UserID, the target's owner.
+type ModerationAction struct {
+	ID          string     `json:"id" bson:"_id,omitempty"`
+	ModeratorID string     `json:"moderatorId" bson:"moderator_id"`
+	ReportID    string     `json:"reportId,omitempty" bson:"report_id,omitempty"`
+	Action      string     `json:"action" bson:"action"` // hide, remove, restore, warn, suspend; dmca_disable, dmca_restore for copyright notices
+	TargetType  string     `json:"targetType" bson:"target_type"`
+	TargetID    string     `json:"targetId" bson:"target_id"`
+	UserID      string     `json:"userId" bson:"user_id"`
+	Note        string     `json:"note,omitempty" bson:"note,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty" bson:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt" bson:"created_at"`
+}
+
+// DMCANotice is a copyright takedown notice against a post or NFT listing.
+// The targeted content is disabled as soon as the notice is filed.
+type DMCANotice struct {
+	ID                 string             `json:"id" bson:"_id,omitempty"`
+	ComplainantUserID  string             `json:"complainantUserId,omitempty" bson:"complainant_user_id,omitempty"`
+	ComplainantName    string             `json:"complainantName" bson:"complainant_name"`
+	ComplainantEmail   string             `json:"complainantEmail" bson:"complainant_email"`
+	ComplainantAddress string             `json:"complainantAddress" bson:"complainant_address"`
+	ComplainantPhone   string             `json:"complainantPhone,omitempty" bson:"complainant_phone,omitempty"`
+	CopyrightedWork    string             `json:"copyrightedWork" bson:"copyrighted_work"`
+	OriginalWorkURL    string             `json:"originalWorkUrl,omitempty" bson:"original_work_url,omitempty"`
+	TargetType         string             `json:"targetType" bson:"target_type"` // post, listing
+	TargetID           string             `json:"targetId" bson:"target_id"`
+	InfringingURL      string             `json:"infringingUrl" bson:"infringing_url"`
+	UploaderID         string             `json:"uploaderId" bson:"uploader_id"`
+	GoodFaithStatement bool               `json:"goodFaithStatement" bson:"good_faith_statement"`
+	AccuracyStatement  bool               `json:"accuracyStatement" bson:"accuracy_statement"`
+	Signature          string             `json:"signature" bson:"signature"`
+	Status             string             `json:"status" bson:"status"` // disabled, counter_noticed, restoring, restored, upheld, rejected
+	CounterNotice      *DMCACounterNotice `json:"counterNotice,omitempty" bson:"counter_notice,omitempty"`
+	CounterDeadline    *time.Time         `json:"counterDeadline,omitempty" bson:"counter_deadline,omitempty"` // a strike is recorded if no counter-notice is filed by then
+	RestoreAfter       *time.Time         `json:"restoreAfter,omitempty" bson:"restore_after,omitempty"`
+	StruckAt           *time.Time         `json:"struckAt,omitempty" bson:"struck_at,omitempty"` // when the uploader's strike was recorded
+	ComplainantIP      string             `json:"-" bson:"complainant_ip,omitempty"`
+	ResolvedBy         string             `json:"resolvedBy,omitempty" bson:"resolved_by,omitempty"`
+	ResolutionNote     string             `json:"resolutionNote,omitempty" bson:"resolution_note,omitempty"`
+	CreatedAt          time.Time          `json:"createdAt" bson:"created_at"`
+	UpdatedAt          time.Time          `json:"updatedAt" bson:"updated_at"`
+}
+
+// DMCACounterNotice is the uploader's response to a DMCA notice.
+type DMCACounterNotice struct {
+	Name                string    `json:"name" bson:"name"`
+	Email               string    `json:"email" bson:"email"`
+	Address             string    `json:"address" bson:"address"`
+	Phone               string    `json:"phone,omitempty" bson:"phone,omitempty"`
+	Explanation         string    `json:"explanation" bson:"explanation"`
+	PerjuryStatement    bool      `json:"perjuryStatement" bson:"perjury_statement"`
+	JurisdictionConsent bool      `json:"jurisdictionConsent" bson:"jurisdiction_consent"`
+	Signature           string    `json:"signature" bson:"signature"`
+	SubmittedAt         time.Time `json:"submittedAt" bson:"submitted_at"`
+}
+
+// CopyrightStrike counts against a user under the repeat infringer policy.
+// Strikes are recorded once a notice is upheld or goes uncontested, and are
+// retracted when the content is restored.
+type CopyrightStrike struct {
+	ID          string     `json:"id" bson:"_id,omitempty"`
+	UserID      string     `json:"userId" bson:"user_id"`
+	NoticeID    string     `json:"noticeId" bson:"notice_id"`
+	RetractedAt *time.Time `json:"retractedAt,omitempty" bson:"retracted_at,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt" bson:"created_at"`
+}
+
+// AuditLogEntry records one action taken through the admin API.
+type AuditLogEntry struct {
+	ID         string                 `json:"id" bson:"_id,omitempty"`
+	AdminID    string                 `json:"adminId" bson:"admin_id"`
+	Action     string                 `json:"action" bson:"action"` // suspend_user, unsuspend_user, set_roles, set_verification, remove_content, approve_business, reject_business, resolve_report, uphold_dmca_notice, reject_dmca_notice
+	TargetType string                 `json:"targetType" bson:"target_type"`
+	TargetID   string                 `json:"targetId" bson:"target_id"`
+	Details    map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	Status     string                 `json:"status,omitempty" bson:"status,omitempty"` // pending, succeeded, failed
+	IPAddress  string                 `json:"ipAddress,omitempty" bson:"ip_address,omitempty"`
+	CreatedAt  time.Time              `json:"createdAt" bson:"created_at"`
+}
+
+// Storefront is the public shop profile of a business account.
+type Storefront struct {
+	Name           string   `json:"name" bson:"name"`
+	Tagline        string   `json:"tagline,omitempty" bson:"tagline,omitempty"`
+	BannerImage    string   `json:"bannerImage,omitempty" bson:"banner_image,omitempty"`
+	ContactEmail   string   `json:"contactEmail,omitempty" bson:"contact_email,omitempty"`
+	ShippingPolicy string   `json:"shippingPolicy,omitempty" bson:"shipping_policy,omitempty"`
+	ReturnPolicy   string   `json:"returnPolicy,omitempty" bson:"return_policy,omitempty"`
+	Links          []string `json:"links,omitempty" bson:"links,omitempty"`
+}
+
+// BusinessApplication is a user's request to become a verified business
+// account, reviewed by admins.
+type BusinessApplication struct {
+	ID                 string     `json:"id" bson:"_id,omitempty"`
+	UserID             string     `json:"userId" bson:"user_id"`
+	BusinessName       string     `json:"businessName" bson:"business_name"`
+	RegistrationNumber string     `json:"registrationNumber" bson:"registration_number"`
+	Country            string     `json:"country" bson:"country"`
+	Address            string     `json:"address" bson:"address"`
+	Website            string     `json:"website,omitempty" bson:"website,omitempty"`
+	ContactEmail       string     `json:"contactEmail" bson:"contact_email"`
+	Phone              string     `json:"phone,omitempty" bson:"phone,omitempty"`
+	Description        string     `json:"description" bson:"description"`
+	DocumentIDs        []string   `json:"documentIds" bson:"document_ids"`
+	Status             string     `json:"status" bson:"status"` // pending, approved, rejected, withdrawn
+	RejectionReason    string     `json:"rejectionReason,omitempty" bson:"rejection_reason,omitempty"`
+	ReviewedBy         string     `json:"reviewedBy,omitempty" bson:"reviewed_by,omitempty"`
+	ReviewedAt         *time.Time `json:"reviewedAt,omitempty" bson:"reviewed_at,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt" bson:"created_at"`
+	UpdatedAt          time.Time  `json:"updatedAt" bson:"updated_at"`
+}
+
+// BusinessDocument is a file supporting a business application. Documents
+// are stored outside the public upload directory and only served to their
+// uploader and admins.
+type BusinessDocument struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	UploaderID  string    `json:"uploaderId" bson:"uploader_id"`
+	Type        string    `json:"type" bson:"type"` // see BusinessDocumentTypes
+	Path        string    `json:"-" bson:"path"`
+	ContentType string    `json:"contentType" bson:"content_type"`
+	Size        int64     `json:"size" bson:"size"`
+	Filename    string    `json:"filename" bson:"filename"`
+	CreatedAt   time.Time `json:"createdAt" bson:"created_at"`
+}
+
+// BusinessDocumentTypes are the kinds of supporting document an applicant can
+// upload. Every application needs at least one identity document.
+var BusinessDocumentTypes = map[string]bool{
+	"identity":     true, // government ID of the account holder
+	"registration": true, // certificate of incorporation or trade registration
+	"rights":       true, // proof of rights to the designs being sold
+	"other":        true,
+}
+
+// Media is an uploaded image or video. Posts reference media by ID and copy
+// its URL into their MediaItems.
+type Media struct {
+	ID          string `json:"id" bson:"_id,omitempty"`
+	OwnerID     string `json:"ownerId" bson:"owner_id"`
+	Key         string `json:"-" bson:"key"` // blob store key
+	URL         string `json:"url" bson:"url"`
+	Type        string `json:"type" bson:"type"` // image, video
+	ContentType string `json:"contentType" bson:"content_type"`
+	Size        int64  `json:"size" bson:"size"`
+	Filename    string `json:"filename" bson:"filename"`
+	Status      string `json:"status" bson:"status"` // pending_upload, uploaded
+	// Media is processed in the background once uploaded: images are
+	// replaced by resized renditions without metadata, videos by HLS
+	// renditions with a poster frame.
+	ProcessingStatus    string           `json:"processingStatus,omitempty" bson:"processing_status,omitempty"` // queued, processing, ready, failed
+	ProcessingError     string           `json:"processingError,omitempty" bson:"processing_error,omitempty"`
+	ProcessingAttempts  int              `json:"-" bson:"processing_attempts,omitempty"`
+	ProcessingStartedAt *time.Time       `json:"-" bson:"processing_started_at,omitempty"`
+	Width               int              `json:"width,omitempty" bson:"width,omitempty"`
+	Height              int              `json:"height,omitempty" bson:"height,omitempty"`
+	Blurhash            string           `json:"blurhash,omitempty" bson:"blurhash,omitempty"`
+	Renditions          []MediaRendition `json:"renditions,omitempty" bson:"renditions,omitempty"`
+	Duration            float64          `json:"duration,omitempty" bson:"duration,omitempty"` // seconds, videos only
+	Poster              *MediaRendition  `json:"poster,omitempty" bson:"poster,omitempty"`
+	AssetKeys           []string         `json:"-" bson:"asset_keys,omitempty"` // further blobs, such as HLS segments
+	CreatedAt           time.Time        `json:"createdAt" bson:"created_at"`
+	UpdatedAt           time.Time        `json:"updatedAt" bson:"updated_at"`
+}
+
+// MediaRendition is a resized copy of an image, or an HLS variant playlist
+// of a video. Renditions are listed smallest first.
+type MediaRendition struct {
+	Key    string `json:"-" bson:"key"`
+	URL    string `json:"url" bson:"url"`
+	Width  int    `json:"width" bson:"width"`
+	Height int    `json:"height" bson:"height"`
+	Format string `json:"format" bson:"format"` // jpeg, png, webp, hls
+	Size   int64  `json:"size" bson:"size"`
+}
+
+// ProfileSync is a pending copy of a user's profile onto the author fields
+// of their posts and comments. There is one per user, keyed by user ID;
+// each profile change bumps Version and restarts it from the beginning.
+type ProfileSync struct {
+	UserID         string     `bson:"_id"`
+	Version        int        `bson:"version"`
+	Status         string     `bson:"status"`                    // pending, running, done
+	PostsCursor    string     `bson:"posts_cursor,omitempty"`    // last post ID updated
+	CommentsCursor string     `bson:"comments_cursor,omitempty"` // last comment ID updated
+	ListingsCursor string     `bson:"listings_cursor,omitempty"` // last NFT listing ID updated
+	StartedAt      *time.Time `bson:"started_at,omitempty"`
+	CreatedAt      time.Time  `bson:"created_at"`
+	UpdatedAt      time.Time  `bson:"updated_at"`
+}
+
+// NotificationTypes are the kinds of notification users get. Users can turn
+// each off; see User.NotificationsOff.
+var NotificationTypes = map[string]bool{
+	"like":           true, // someone liked your post
+	"comment":        true, // someone commented on your post
+	"reply":          true, // someone replied to your comment
+	"mention":        true, // someone mentioned you in a comment
+	"follow":         true, // someone followed you
+	"follow_request": true, // someone asked to follow your private account
+	"message":        true, // someone messaged you
+	"outbid":         true, // someone outbid you on an NFT auction
+	"auction_won":    true, // you won an NFT auction
+	"order":          true, // a purchase of yours or of your post was placed or changed status
+	"copyright":      true, // a copyright notice against your content, or one you filed, changed
+}
+
+// RequiredNotificationTypes can't be turned off, because they carry notices
+// users are entitled to by law.
+var RequiredNotificationTypes = map[string]bool{
+	"copyright": true,
+}
+
+// Notification tells a user something happened. Notifications of the same
+// kind about the same thing are grouped while unread: ActorIDs keeps the
+// most recent actors for display, ActorSet holds them all and ActorsCount is
+// its size.
+type Notification struct {
+	ID          string                 `json:"id" bson:"_id,omitempty"`
+	UserID      string                 `json:"userId" bson:"user_id"` // the recipient
+	Type        string                 `json:"type" bson:"type"`      // see NotificationTypes
+	ActorIDs    []string               `json:"actorIds,omitempty" bson:"actor_ids,omitempty"`
+	ActorSet    []string               `json:"-" bson:"actor_set,omitempty"`
+	ActorsCount int                    `json:"actorsCount" bson:"actors_count"`
+	TargetType  string                 `json:"targetType" bson:"target_type"` // post, comment, user, conversation, listing, transaction
+	TargetID    string                 `json:"targetId" bson:"target_id"`
+	PostID      string                 `json:"postId,omitempty" bson:"post_id,omitempty"` // for comments
+	Data        map[string]interface{} `json:"data,omitempty" bson:"data,omitempty"`
+	GroupKey    string                 `json:"-" bson:"group_key,omitempty"` // empty for notifications that are never grouped
+	IsRead      bool                   `json:"isRead" bson:"is_read"`
+	ReadAt      *time.Time             `json:"readAt,omitempty" bson:"read_at,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt" bson:"created_at"`
+	UpdatedAt   time.Time              `json:"updatedAt" bson:"updated_at"` // when the latest actor joined the group
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUsernameKey(t *testing.T) {
+	tests := []struct {
+		username string
+		want     string
+	}{
+		{"jane_doe", "jane_doe"},
+		{"Jane_Doe", "jane_doe"},
+		{"JANE.DOE", "jane.doe"},
+		{"jane2024", "jane2024"},
+	}
+
+	for _, tt := range tests {
+		if got := UsernameKey(tt.username); got != tt.want {
+			t.Errorf("UsernameKey(%q) = %q, want %q", tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestSuspensionActive(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		suspension *Suspension
+		want       bool
+	}{
+		{"not suspended", nil, false},
+		{"permanent", &Suspension{}, true},
+		{"temporary", &Suspension{Until: &later}, true},
+		{"ends now", &Suspension{Until: &now}, false},
+		{"expired", &Suspension{Until: &earlier}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.suspension.Active(now); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,20 +1,28 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
 	jwtSecret []byte
+	db        *database.Database
 }
 
-func NewAuthService(jwtSecret string) *AuthService {
+func NewAuthService(jwtSecret string, db *database.Database) *AuthService {
 	return &AuthService{
 		jwtSecret: []byte(jwtSecret),
+		db:        db,
 	}
 }
 
@@ -28,9 +36,34 @@ func (s *AuthService) CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func (s *AuthService) GenerateToken(userID string) (string, error) {
+// Claims is the identity carried by an access token. Roles are copied from
+// the user when the token is issued, as a hint for clients; AuthMiddleware
+// replaces them with the user's current roles before any role check.
+type Claims struct {
+	UserID string
+	Roles  []string
+}
+
+// HasRole reports whether the claims hold any of the given roles.
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, held := range c.Roles {
+		for _, role := range roles {
+			if held == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *AuthService) GenerateToken(userID string, roles []string) (string, error) {
+	if roles == nil {
+		roles = []string{}
+	}
+
 	claims := jwt.MapClaims{
 		"user_id": userID,
+		"roles":   roles,
 		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(), // 7 days
 	}
 
@@ -38,7 +71,7 @@ func (s *AuthService) GenerateToken(userID string) (string, error) {
 	return token.SignedString(s.jwtSecret)
 }
 
-func (s *AuthService) ValidateToken(tokenString string) (string, error) {
+func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -47,16 +80,67 @@ func (s *AuthService) ValidateToken(tokenString string) (string, error) {
 	})
 
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		userID, ok := claims["user_id"].(string)
 		if !ok {
-			return "", errors.New("invalid token claims")
+			return nil, errors.New("invalid token claims")
 		}
-		return userID, nil
+
+		// Tokens issued before roles existed carry none
+		var roles []string
+		if values, ok := claims["roles"].([]interface{}); ok {
+			for _, value := range values {
+				if role, ok := value.(string); ok {
+					roles = append(roles, role)
+				}
+			}
+		}
+
+		return &Claims{UserID: userID, Roles: roles}, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+// ActiveSuspension returns the user's suspension if it is in force, or nil.
+// Tokens stay valid for days, so this is checked on every authenticated
+// request rather than only at login. A suspension found to have expired is
+// cleared.
+func (s *AuthService) ActiveSuspension(ctx context.Context, userID string) (*models.Suspension, error) {
+	_, suspension, err := s.CurrentAccess(ctx, userID)
+	return suspension, err
+}
+
+// CurrentAccess returns the user's roles as stored now, along with their
+// ActiveSuspension, in one query. Unknown users have neither.
+func (s *AuthService) CurrentAccess(ctx context.Context, userID string) ([]string, *models.Suspension, error) {
+	var user models.User
+	err := s.db.Users().FindOne(
+		ctx,
+		bson.M{"_id": userID},
+		options.FindOne().SetProjection(bson.M{"roles": 1, "suspension": 1}),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.Suspension == nil {
+		return user.Roles, nil, nil
+	}
+	if user.Suspension.Active(time.Now()) {
+		return user.Roles, user.Suspension, nil
 	}
 
-	return "", errors.New("invalid token")
+	_, err = s.db.Users().UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "suspension.until": user.Suspension.Until},
+		bson.M{"$unset": bson.M{"suspension": ""}},
+	)
+	return user.Roles, nil, err
 }
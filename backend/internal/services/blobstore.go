@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	ErrBlobNotFound        = errors.New("blob not found")
+	ErrPresignNotSupported = errors.New("blob store does not support pre-signed uploads")
+)
+
+// BlobStore stores uploaded files under opaque keys such as
+// "media/<id>.jpg" and serves them from public URLs.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Open reads a stored blob. Callers must close the reader.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Size returns the stored size of a blob, or ErrBlobNotFound.
+	Size(ctx context.Context, key string) (int64, error)
+	Delete(ctx context.Context, key string) error
+	// URL is where clients download the blob.
+	URL(key string) string
+	// PresignPut returns a URL the client can PUT the file to directly,
+	// valid for expires. The client must send the given Content-Type.
+	PresignPut(ctx context.Context, key, contentType string, expires time.Duration) (string, error)
+}
+
+// NewBlobStore selects the storage backend from the environment. The
+// filesystem store keeps files in uploadDir, served under /uploads.
+func NewBlobStore(uploadDir string) (BlobStore, error) {
+	switch os.Getenv("MEDIA_STORAGE") {
+	case "", "fs":
+		return NewFSBlobStore(uploadDir, "/uploads"), nil
+	case "s3":
+		return NewS3BlobStore(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+			PathStyle:       os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+		})
+	default:
+		return nil, errors.New("unknown MEDIA_STORAGE: " + os.Getenv("MEDIA_STORAGE"))
+	}
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a path-style, in-memory S3 bucket that checks every request's
+// SigV4 signature against its own copy of the credentials.
+type fakeS3 struct {
+	verifier *S3BlobStore
+	bucket   string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.signed(r) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case http.MethodDelete:
+		if _, ok := f.objects[key]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// signed recomputes the signature of a header-signed or presigned request
+// from what the server received.
+func (f *fakeS3) signed(r *http.Request) bool {
+	query := r.URL.Query()
+
+	var date, got string
+	var headers map[string]string
+	if presigned := query.Get("X-Amz-Signature"); presigned != "" {
+		got = presigned
+		date = query.Get("X-Amz-Date")
+		query.Del("X-Amz-Signature")
+		headers = map[string]string{
+			"content-type": r.Header.Get("Content-Type"),
+			"host":         r.Host,
+		}
+	} else {
+		_, got, _ = strings.Cut(r.Header.Get("Authorization"), "Signature=")
+		date = r.Header.Get("X-Amz-Date")
+		headers = map[string]string{
+			"host":                 r.Host,
+			"x-amz-content-sha256": r.Header.Get("X-Amz-Content-Sha256"),
+			"x-amz-date":           date,
+		}
+	}
+
+	t, err := time.Parse("20060102T150405Z", date)
+	if err != nil {
+		return false
+	}
+	want := f.verifier.signature(t, r.Method, r.URL.EscapedPath(), canonicalQuery(query), headers, unsignedPayload)
+	return got == want
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, S3Config) {
+	t.Helper()
+
+	fake := &fakeS3{bucket: "media", objects: map[string]fakeObject{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	cfg := S3Config{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          fake.bucket,
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+		PathStyle:       true,
+	}
+
+	verifier, err := NewS3BlobStore(cfg)
+	if err != nil {
+		t.Fatalf("NewS3BlobStore: %v", err)
+	}
+	fake.verifier = verifier
+	return fake, cfg
+}
+
+func TestS3BlobStore(t *testing.T) {
+	fake, cfg := newFakeS3(t)
+	store, err := NewS3BlobStore(cfg)
+	if err != nil {
+		t.Fatalf("NewS3BlobStore: %v", err)
+	}
+	ctx := context.Background()
+
+	// A space and a plus sign check keys are escaped the way they are signed
+	key := "media/user 1/a+b.jpg"
+	data := []byte("not really a jpeg")
+
+	if err := store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := fake.objects[key].contentType; got != "image/jpeg" {
+		t.Errorf("stored content type = %q, want image/jpeg", got)
+	}
+
+	size, err := store.Size(ctx, key)
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+	if size != int64(len(data)) {
+		t.Errorf("Size = %d, want %d", size, len(data))
+	}
+
+	body, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("reading blob: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Open read %q, want %q", got, data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Size(ctx, key); err != ErrBlobNotFound {
+		t.Errorf("Size after Delete: err = %v, want ErrBlobNotFound", err)
+	}
+	if _, err := store.Open(ctx, key); err != ErrBlobNotFound {
+		t.Errorf("Open after Delete: err = %v, want ErrBlobNotFound", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing blob: %v", err)
+	}
+}
+
+func TestS3BlobStoreRejectedSignature(t *testing.T) {
+	_, cfg := newFakeS3(t)
+	cfg.SecretAccessKey = "wrong"
+	store, err := NewS3BlobStore(cfg)
+	if err != nil {
+		t.Fatalf("NewS3BlobStore: %v", err)
+	}
+
+	err = store.Put(context.Background(), "media/a.jpg", strings.NewReader("x"), 1, "image/jpeg")
+	if err == nil || err == ErrBlobNotFound {
+		t.Errorf("Put with the wrong secret: err = %v, want a signature error", err)
+	}
+}
+
+func TestS3BlobStorePresignPut(t *testing.T) {
+	fake, cfg := newFakeS3(t)
+	store, err := NewS3BlobStore(cfg)
+	if err != nil {
+		t.Fatalf("NewS3BlobStore: %v", err)
+	}
+
+	key := "media/clip one.mp4"
+	uploadURL, err := store.PresignPut(context.Background(), key, "video/mp4", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("PresignPut: %v", err)
+	}
+	if !strings.Contains(uploadURL, "X-Amz-Expires=900") {
+		t.Errorf("presigned URL %q does not expire in 900 seconds", uploadURL)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		wantStatus  int
+	}{
+		{"presigned content type", "video/mp4", http.StatusOK},
+		{"different content type", "image/png", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, uploadURL, strings.NewReader("frames"))
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("PUT: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("PUT status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+
+	if string(fake.objects[key].data) != "frames" {
+		t.Errorf("stored object = %q, want %q", fake.objects[key].data, "frames")
+	}
+}
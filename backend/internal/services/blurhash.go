@@ -0,0 +1,138 @@
+package services
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	blurhashComponentsX = 4
+	blurhashComponentsY = 3
+	// blurhashSampleSize is the width images are shrunk to before encoding;
+	// the hash only keeps a handful of frequencies, so more pixels add
+	// nothing but time.
+	blurhashSampleSize = 32
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Blurhash encodes a compact placeholder for an image that clients can
+// render while the real image loads. See https://blurha.sh.
+func Blurhash(img image.Image) string {
+	bounds := img.Bounds()
+	width, height := blurhashSampleSize, bounds.Dy()*blurhashSampleSize/bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	sample := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(sample, sample.Bounds(), img, bounds, draw.Src, nil)
+
+	factors := make([][3]float64, 0, blurhashComponentsX*blurhashComponentsY)
+	for j := 0; j < blurhashComponentsY; j++ {
+		for i := 0; i < blurhashComponentsX; i++ {
+			factors = append(factors, blurhashFactor(sample, i, j))
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encodeBase83((blurhashComponentsX-1)+(blurhashComponentsY-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMaximum := 0.0
+		for _, factor := range ac {
+			for _, v := range factor {
+				actualMaximum = math.Max(actualMaximum, math.Abs(v))
+			}
+		}
+		quantisedMaximum := clampInt(int(math.Floor(actualMaximum*166-0.5)), 0, 82)
+		maximumValue = float64(quantisedMaximum+1) / 166
+		hash.WriteString(encodeBase83(quantisedMaximum, 1))
+	} else {
+		hash.WriteString(encodeBase83(0, 1))
+	}
+
+	hash.WriteString(encodeBase83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+
+	for _, factor := range ac {
+		quantised := [3]int{}
+		for k, v := range factor {
+			quantised[k] = clampInt(int(math.Floor(signPow(v/maximumValue, 0.5)*9+9.5)), 0, 18)
+		}
+		hash.WriteString(encodeBase83(quantised[0]*19*19+quantised[1]*19+quantised[2], 2))
+	}
+
+	return hash.String()
+}
+
+// blurhashFactor is the average linear colour of img weighted by the cosine
+// basis function for component (i, j).
+func blurhashFactor(img *image.NRGBA, i, j int) [3]float64 {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+
+	var r, g, b float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) *
+				math.Cos(math.Pi*float64(j)*float64(y)/float64(height))
+
+			offset := img.PixOffset(x, y)
+			r += basis * sRGBToLinear(img.Pix[offset])
+			g += basis * sRGBToLinear(img.Pix[offset+1])
+			b += basis * sRGBToLinear(img.Pix[offset+2])
+		}
+	}
+
+	normalisation := 2.0
+	if i == 0 && j == 0 {
+		normalisation = 1
+	}
+	scale := normalisation / float64(width*height)
+
+	return [3]float64{r * scale, g * scale, b * scale}
+}
+
+func encodeBase83(value, length int) string {
+	result := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		result[i-1] = base83Chars[digit]
+	}
+	return string(result)
+}
+
+func sRGBToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
+
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
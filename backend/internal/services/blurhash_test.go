@@ -0,0 +1,102 @@
+package services
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+)
+
+func solidImage(width, height int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestBlurhash(t *testing.T) {
+	// Black has no AC components, which all encode as "fQ"
+	flat := strings.Repeat("fQ", blurhashComponentsX*blurhashComponentsY-1)
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+	}{
+		{"black", solidImage(64, 48, color.Black), "L00000" + flat},
+		{"tall", solidImage(1, 400, color.Black), "L00000" + flat},
+		{"wide", solidImage(400, 1, color.Black), "L00000" + flat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Blurhash(tt.img); got != tt.want {
+				t.Errorf("Blurhash() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlurhashAverageColour(t *testing.T) {
+	tests := []struct {
+		name string
+		c    color.Color
+		want string
+	}{
+		{"black", color.Black, "0000"},
+		{"white", color.White, "TSUA"},
+		{"red", color.NRGBA{R: 255, A: 255}, "TI:j"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Blurhash(solidImage(64, 48, tt.c))[2:6]; got != tt.want {
+				t.Errorf("Blurhash() average colour = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlurhashLength(t *testing.T) {
+	// Left half black, right half white
+	img := solidImage(64, 48, color.Black)
+	draw.Draw(img, image.Rect(32, 0, 64, 48), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	hash := Blurhash(img)
+	if want := 4 + 2*blurhashComponentsX*blurhashComponentsY; len(hash) != want {
+		t.Fatalf("len(Blurhash()) = %d, want %d", len(hash), want)
+	}
+	if strings.HasSuffix(hash, strings.Repeat("fQ", blurhashComponentsX*blurhashComponentsY-1)) {
+		t.Errorf("Blurhash() = %q, want AC components for a split image", hash)
+	}
+}
+
+func TestEncodeBase83(t *testing.T) {
+	tests := []struct {
+		value  int
+		length int
+		want   string
+	}{
+		{0, 1, "0"},
+		{21, 1, "L"},
+		{82, 1, "~"},
+		{83, 2, "10"},
+		{3429, 2, "fQ"},
+		{0, 4, "0000"},
+		{16777215, 4, "TSUA"},
+	}
+
+	for _, tt := range tests {
+		if got := encodeBase83(tt.value, tt.length); got != tt.want {
+			t.Errorf("encodeBase83(%d, %d) = %q, want %q", tt.value, tt.length, got, tt.want)
+		}
+	}
+}
+
+func TestSRGBRoundTrip(t *testing.T) {
+	for v := 0; v <= 255; v++ {
+		if got := linearToSRGB(sRGBToLinear(uint8(v))); got != v {
+			t.Errorf("linearToSRGB(sRGBToLinear(%d)) = %d", v, got)
+		}
+	}
+}
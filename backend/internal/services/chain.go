@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrTokenNotFound   = errors.New("token not found")
+	ErrNotTokenOwner   = errors.New("sender does not own token")
+	ErrInvalidAddress  = errors.New("invalid wallet address")
+	ErrChainTxReverted = errors.New("chain transaction reverted")
+	ErrChainTxPending  = errors.New("chain transaction pending")
+	ErrChainTxNotFound = errors.New("chain transaction not found")
+)
+
+var walletAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// ChainClient is the boundary between the platform and the blockchain that
+// holds NFT ownership. Amounts are expressed in the chain's native currency.
+type ChainClient interface {
+	// Chain returns a short identifier of the network, e.g. "simulated" or "ethereum".
+	Chain() string
+	// ContractAddress returns the address of the NFT contract tokens are minted on.
+	ContractAddress() string
+	Mint(ctx context.Context, req MintRequest) (*MintResult, error)
+	// MintStatus looks up a mint transaction sent earlier: the minted token
+	// once it has confirmed, ErrChainTxPending until then, and
+	// ErrChainTxReverted or ErrChainTxNotFound if it minted nothing.
+	MintStatus(ctx context.Context, txHash string) (*MintResult, error)
+	Transfer(ctx context.Context, tokenID, from, to string) (string, error)
+	OwnerOf(ctx context.Context, tokenID string) (string, error)
+	RoyaltyInfo(ctx context.Context, tokenID string, salePrice float64) (*RoyaltyInfo, error)
+}
+
+type MintRequest struct {
+	To              string
+	TokenURI        string
+	RoyaltyReceiver string
+	RoyaltyBps      int                 // basis points, 100 = 1%
+	Submitted       func(txHash string) // optional; called once the transaction is sent, before it confirms
+}
+
+type MintResult struct {
+	TokenID string
+	TxHash  string
+}
+
+type RoyaltyInfo struct {
+	Receiver string
+	Amount   float64
+}
+
+// NewChainClient builds the chain client selected by CHAIN_PROVIDER.
+// The simulated chain is used unless "evm" is requested explicitly.
+func NewChainClient() (ChainClient, error) {
+	switch os.Getenv("CHAIN_PROVIDER") {
+	case "evm":
+		return NewEVMChainClient(
+			os.Getenv("EVM_RPC_URL"),
+			os.Getenv("EVM_CHAIN_NAME"),
+			os.Getenv("NFT_CONTRACT_ADDRESS"),
+			os.Getenv("NFT_OPERATOR_ADDRESS"),
+		)
+	case "", "simulated":
+		return NewSimulatedChain(), nil
+	default:
+		return nil, errors.New("unknown CHAIN_PROVIDER: " + os.Getenv("CHAIN_PROVIDER"))
+	}
+}
+
+// IsValidWalletAddress reports whether address is a 0x-prefixed 20-byte hex address.
+func IsValidWalletAddress(address string) bool {
+	return walletAddressPattern.MatchString(address)
+}
+
+// NormalizeWalletAddress lowercases an address so it can be compared and indexed.
+func NormalizeWalletAddress(address string) string {
+	return strings.ToLower(address)
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// EVMChainClient talks to an ERC-721 contract (with EIP-2981 royalties) over
+// Ethereum JSON-RPC. Transactions are sent with eth_sendTransaction from the
+// platform operator account, so the node (or a signer such as Clef in front of
+// it) must hold that account's key, and the operator must be approved for
+// transfers on the contract.
+//
+// The contract is expected to expose:
+//
+//	mint(address to, string tokenURI, address royaltyReceiver, uint96 royaltyBps)
+//	safeTransferFrom(address from, address to, uint256 tokenId)
+//	ownerOf(uint256 tokenId) returns (address)
+//	royaltyInfo(uint256 tokenId, uint256 salePrice) returns (address, uint256)
+type EVMChainClient struct {
+	rpcURL          string
+	chain           string
+	contractAddress string
+	operatorAddress string
+	httpClient      *http.Client
+	requestID       int64
+	pollInterval    time.Duration
+}
+
+var (
+	selectorMint         = abiSelector("mint(address,string,address,uint96)")
+	selectorSafeTransfer = abiSelector("safeTransferFrom(address,address,uint256)")
+	selectorOwnerOf      = abiSelector("ownerOf(uint256)")
+	selectorRoyaltyInfo  = abiSelector("royaltyInfo(uint256,uint256)")
+	topicTransfer        = "0x" + hex.EncodeToString(keccak256([]byte("Transfer(address,address,uint256)")))
+	weiPerEther          = new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+)
+
+func NewEVMChainClient(rpcURL, chain, contractAddress, operatorAddress string) (*EVMChainClient, error) {
+	if rpcURL == "" {
+		return nil, errors.New("EVM_RPC_URL must be set")
+	}
+	if !IsValidWalletAddress(contractAddress) {
+		return nil, errors.New("NFT_CONTRACT_ADDRESS must be a valid address")
+	}
+	if !IsValidWalletAddress(operatorAddress) {
+		return nil, errors.New("NFT_OPERATOR_ADDRESS must be a valid address")
+	}
+	if chain == "" {
+		chain = "ethereum"
+	}
+
+	return &EVMChainClient{
+		rpcURL:          rpcURL,
+		chain:           chain,
+		contractAddress: NormalizeWalletAddress(contractAddress),
+		operatorAddress: NormalizeWalletAddress(operatorAddress),
+		httpClient:      &http.Client{Timeout: 30 * time.Second},
+		pollInterval:    2 * time.Second,
+	}, nil
+}
+
+func (c *EVMChainClient) Chain() string {
+	return c.chain
+}
+
+func (c *EVMChainClient) ContractAddress() string {
+	return c.contractAddress
+}
+
+func (c *EVMChainClient) Mint(ctx context.Context, req MintRequest) (*MintResult, error) {
+	if !IsValidWalletAddress(req.To) {
+		return nil, ErrInvalidAddress
+	}
+
+	royaltyReceiver := req.RoyaltyReceiver
+	if royaltyReceiver == "" {
+		royaltyReceiver = req.To
+	}
+	if !IsValidWalletAddress(royaltyReceiver) {
+		return nil, ErrInvalidAddress
+	}
+
+	data := abiCall(selectorMint,
+		abiAddress(req.To),
+		abiUint(big.NewInt(4*32)), // offset of the dynamic tokenURI argument
+		abiAddress(royaltyReceiver),
+		abiUint(big.NewInt(int64(req.RoyaltyBps))),
+		abiString(req.TokenURI),
+	)
+
+	txHash, err := c.sendTransaction(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if req.Submitted != nil {
+		req.Submitted(txHash)
+	}
+
+	receipt, err := c.waitForReceipt(ctx, txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.mintedToken(receipt, txHash)
+}
+
+func (c *EVMChainClient) MintStatus(ctx context.Context, txHash string) (*MintResult, error) {
+	var receipt *txReceipt
+	if err := c.rpc(ctx, "eth_getTransactionReceipt", []interface{}{txHash}, &receipt); err != nil {
+		return nil, err
+	}
+
+	if receipt == nil {
+		// Not mined yet, or dropped by the node
+		var tx *struct {
+			Hash string `json:"hash"`
+		}
+		if err := c.rpc(ctx, "eth_getTransactionByHash", []interface{}{txHash}, &tx); err != nil {
+			return nil, err
+		}
+		if tx == nil {
+			return nil, ErrChainTxNotFound
+		}
+		return nil, ErrChainTxPending
+	}
+
+	if receipt.Status != "0x1" {
+		return nil, ErrChainTxReverted
+	}
+	return c.mintedToken(receipt, txHash)
+}
+
+// mintedToken reads the token ID from the Transfer event of a confirmed mint.
+func (c *EVMChainClient) mintedToken(receipt *txReceipt, txHash string) (*MintResult, error) {
+	for _, l := range receipt.Logs {
+		if strings.EqualFold(l.Address, c.contractAddress) && len(l.Topics) == 4 && l.Topics[0] == topicTransfer {
+			tokenID, ok := new(big.Int).SetString(strings.TrimPrefix(l.Topics[3], "0x"), 16)
+			if !ok {
+				return nil, fmt.Errorf("malformed token id in mint log: %s", l.Topics[3])
+			}
+			return &MintResult{TokenID: tokenID.String(), TxHash: txHash}, nil
+		}
+	}
+
+	return nil, errors.New("mint transaction emitted no Transfer event")
+}
+
+func (c *EVMChainClient) Transfer(ctx context.Context, tokenID, from, to string) (string, error) {
+	if !IsValidWalletAddress(from) || !IsValidWalletAddress(to) {
+		return "", ErrInvalidAddress
+	}
+
+	id, ok := new(big.Int).SetString(tokenID, 10)
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+
+	data := abiCall(selectorSafeTransfer, abiAddress(from), abiAddress(to), abiUint(id))
+
+	txHash, err := c.sendTransaction(ctx, data)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := c.waitForReceipt(ctx, txHash); err != nil {
+		return "", err
+	}
+
+	return txHash, nil
+}
+
+func (c *EVMChainClient) OwnerOf(ctx context.Context, tokenID string) (string, error) {
+	id, ok := new(big.Int).SetString(tokenID, 10)
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+
+	data := abiCall(selectorOwnerOf, abiUint(id))
+
+	out, err := c.call(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	if len(out) < 32 {
+		return "", ErrTokenNotFound
+	}
+
+	return "0x" + hex.EncodeToString(out[12:32]), nil
+}
+
+func (c *EVMChainClient) RoyaltyInfo(ctx context.Context, tokenID string, salePrice float64) (*RoyaltyInfo, error) {
+	id, ok := new(big.Int).SetString(tokenID, 10)
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+
+	data := abiCall(selectorRoyaltyInfo, abiUint(id), abiUint(etherToWei(salePrice)))
+
+	out, err := c.call(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) < 64 {
+		return nil, errors.New("malformed royaltyInfo response")
+	}
+
+	return &RoyaltyInfo{
+		Receiver: "0x" + hex.EncodeToString(out[12:32]),
+		Amount:   weiToEther(new(big.Int).SetBytes(out[32:64])),
+	}, nil
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int64         `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type txReceipt struct {
+	Status string `json:"status"`
+	Logs   []struct {
+		Address string   `json:"address"`
+		Topics  []string `json:"topics"`
+	} `json:"logs"`
+}
+
+func (c *EVMChainClient) rpc(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddInt64(&c.requestID, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.rpcURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("%s: %s (code %d)", method, rpcResp.Error.Message, rpcResp.Error.Code)
+	}
+
+	return json.Unmarshal(rpcResp.Result, result)
+}
+
+func (c *EVMChainClient) call(ctx context.Context, data []byte) ([]byte, error) {
+	var result string
+	err := c.rpc(ctx, "eth_call", []interface{}{
+		map[string]string{"to": c.contractAddress, "data": "0x" + hex.EncodeToString(data)},
+		"latest",
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.DecodeString(strings.TrimPrefix(result, "0x"))
+}
+
+func (c *EVMChainClient) sendTransaction(ctx context.Context, data []byte) (string, error) {
+	var txHash string
+	err := c.rpc(ctx, "eth_sendTransaction", []interface{}{
+		map[string]string{
+			"from": c.operatorAddress,
+			"to":   c.contractAddress,
+			"data": "0x" + hex.EncodeToString(data),
+		},
+	}, &txHash)
+	return txHash, err
+}
+
+func (c *EVMChainClient) waitForReceipt(ctx context.Context, txHash string) (*txReceipt, error) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		var receipt *txReceipt
+		if err := c.rpc(ctx, "eth_getTransactionReceipt", []interface{}{txHash}, &receipt); err != nil {
+			return nil, err
+		}
+		if receipt != nil {
+			if receipt.Status != "0x1" {
+				return nil, ErrChainTxReverted
+			}
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func abiSelector(signature string) []byte {
+	return keccak256([]byte(signature))[:4]
+}
+
+func abiCall(selector []byte, args ...[]byte) []byte {
+	data := make([]byte, 0, len(selector)+32*len(args))
+	data = append(data, selector...)
+	for _, arg := range args {
+		data = append(data, arg...)
+	}
+	return data
+}
+
+func abiAddress(address string) []byte {
+	b, _ := hex.DecodeString(strings.TrimPrefix(strings.ToLower(address), "0x"))
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+func abiUint(v *big.Int) []byte {
+	return v.FillBytes(make([]byte, 32))
+}
+
+func abiString(s string) []byte {
+	out := abiUint(big.NewInt(int64(len(s))))
+	padded := make([]byte, (len(s)+31)/32*32)
+	copy(padded, s)
+	return append(out, padded...)
+}
+
+func etherToWei(amount float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(amount), weiPerEther).Int(nil)
+	return wei
+}
+
+func weiToEther(wei *big.Int) float64 {
+	eth, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), weiPerEther).Float64()
+	return eth
+}
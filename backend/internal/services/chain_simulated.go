@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+)
+
+// SimulatedChain is an in-memory ChainClient for local development and tests.
+// State is lost when the process exits.
+type SimulatedChain struct {
+	mu     sync.Mutex
+	nextID int64
+	tokens map[string]*simulatedToken
+	mints  map[string]string // mint transaction hash to token ID
+}
+
+type simulatedToken struct {
+	owner           string
+	uri             string
+	royaltyReceiver string
+	royaltyBps      int
+}
+
+func NewSimulatedChain() *SimulatedChain {
+	return &SimulatedChain{
+		nextID: 1,
+		tokens: make(map[string]*simulatedToken),
+		mints:  make(map[string]string),
+	}
+}
+
+func (s *SimulatedChain) Chain() string {
+	return "simulated"
+}
+
+func (s *SimulatedChain) ContractAddress() string {
+	return "0x0000000000000000000000000000000000000000"
+}
+
+func (s *SimulatedChain) Mint(ctx context.Context, req MintRequest) (*MintResult, error) {
+	if !IsValidWalletAddress(req.To) {
+		return nil, ErrInvalidAddress
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenID := strconv.FormatInt(s.nextID, 10)
+	s.nextID++
+
+	s.tokens[tokenID] = &simulatedToken{
+		owner:           NormalizeWalletAddress(req.To),
+		uri:             req.TokenURI,
+		royaltyReceiver: NormalizeWalletAddress(req.RoyaltyReceiver),
+		royaltyBps:      req.RoyaltyBps,
+	}
+
+	txHash := simulatedTxHash()
+	s.mints[txHash] = tokenID
+	if req.Submitted != nil {
+		req.Submitted(txHash)
+	}
+
+	return &MintResult{TokenID: tokenID, TxHash: txHash}, nil
+}
+
+func (s *SimulatedChain) MintStatus(ctx context.Context, txHash string) (*MintResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenID, ok := s.mints[txHash]
+	if !ok {
+		return nil, ErrChainTxNotFound
+	}
+
+	return &MintResult{TokenID: tokenID, TxHash: txHash}, nil
+}
+
+func (s *SimulatedChain) Transfer(ctx context.Context, tokenID, from, to string) (string, error) {
+	if !IsValidWalletAddress(to) {
+		return "", ErrInvalidAddress
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[tokenID]
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+
+	if token.owner != NormalizeWalletAddress(from) {
+		return "", ErrNotTokenOwner
+	}
+
+	token.owner = NormalizeWalletAddress(to)
+	return simulatedTxHash(), nil
+}
+
+func (s *SimulatedChain) OwnerOf(ctx context.Context, tokenID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[tokenID]
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+
+	return token.owner, nil
+}
+
+func (s *SimulatedChain) RoyaltyInfo(ctx context.Context, tokenID string, salePrice float64) (*RoyaltyInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[tokenID]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+
+	return &RoyaltyInfo{
+		Receiver: token.royaltyReceiver,
+		Amount:   salePrice * float64(token.royaltyBps) / 10000,
+	}, nil
+}
+
+func simulatedTxHash() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return "0x" + hex.EncodeToString(b)
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	testSeller = "0x1111111111111111111111111111111111111111"
+	testBuyer  = "0x2222222222222222222222222222222222222222"
+	testArtist = "0x3333333333333333333333333333333333333333"
+)
+
+func TestSimulatedChainMint(t *testing.T) {
+	chain := NewSimulatedChain()
+	ctx := context.Background()
+
+	var submitted string
+	result, err := chain.Mint(ctx, MintRequest{
+		To:              testSeller,
+		TokenURI:        "https://example.com/nft/1",
+		RoyaltyReceiver: testArtist,
+		RoyaltyBps:      250,
+		Submitted:       func(txHash string) { submitted = txHash },
+	})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if submitted != result.TxHash {
+		t.Errorf("Submitted got %q, want the mint hash %q", submitted, result.TxHash)
+	}
+
+	status, err := chain.MintStatus(ctx, result.TxHash)
+	if err != nil {
+		t.Fatalf("MintStatus: %v", err)
+	}
+	if *status != *result {
+		t.Errorf("MintStatus = %+v, want %+v", status, result)
+	}
+
+	if _, err := chain.MintStatus(ctx, simulatedTxHash()); !errors.Is(err, ErrChainTxNotFound) {
+		t.Errorf("MintStatus of an unknown hash: err = %v, want ErrChainTxNotFound", err)
+	}
+
+	if _, err := chain.Mint(ctx, MintRequest{To: "not an address"}); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Mint to an invalid address: err = %v, want ErrInvalidAddress", err)
+	}
+}
+
+func TestSimulatedChainTransfer(t *testing.T) {
+	chain := NewSimulatedChain()
+	ctx := context.Background()
+
+	minted, err := chain.Mint(ctx, MintRequest{To: testSeller, RoyaltyReceiver: testArtist, RoyaltyBps: 250})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		tokenID string
+		from    string
+		to      string
+		wantErr error
+	}{
+		{"invalid recipient", minted.TokenID, testSeller, "0x123", ErrInvalidAddress},
+		{"unknown token", "999", testSeller, testBuyer, ErrTokenNotFound},
+		{"sender is not the owner", minted.TokenID, testBuyer, testSeller, ErrNotTokenOwner},
+		{"owner sells to buyer", minted.TokenID, testSeller, testBuyer, nil},
+		{"seller cannot sell twice", minted.TokenID, testSeller, testBuyer, ErrNotTokenOwner},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := chain.Transfer(ctx, tt.tokenID, tt.from, tt.to); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Transfer(%q, %q, %q): err = %v, want %v", tt.tokenID, tt.from, tt.to, err, tt.wantErr)
+			}
+		})
+	}
+
+	owner, err := chain.OwnerOf(ctx, minted.TokenID)
+	if err != nil {
+		t.Fatalf("OwnerOf: %v", err)
+	}
+	if owner != testBuyer {
+		t.Errorf("OwnerOf = %q, want %q", owner, testBuyer)
+	}
+	if _, err := chain.OwnerOf(ctx, "999"); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("OwnerOf an unknown token: err = %v, want ErrTokenNotFound", err)
+	}
+}
+
+func TestSimulatedChainRoyaltyInfo(t *testing.T) {
+	chain := NewSimulatedChain()
+	ctx := context.Background()
+
+	minted, err := chain.Mint(ctx, MintRequest{To: testSeller, RoyaltyReceiver: testArtist, RoyaltyBps: 250})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	royalty, err := chain.RoyaltyInfo(ctx, minted.TokenID, 2)
+	if err != nil {
+		t.Fatalf("RoyaltyInfo: %v", err)
+	}
+	if royalty.Receiver != testArtist || royalty.Amount != 0.05 {
+		t.Errorf("RoyaltyInfo = %+v, want %s receiving 0.05", royalty, testArtist)
+	}
+
+	if _, err := chain.RoyaltyInfo(ctx, "999", 2); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("RoyaltyInfo of an unknown token: err = %v, want ErrTokenNotFound", err)
+	}
+}
+
+// fakeRPC answers JSON-RPC requests with canned results by method name.
+func fakeRPC(t *testing.T, results map[string]string) *EVMChainClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, ok := results[req.Method]
+		if !ok {
+			t.Errorf("unexpected RPC call %s", req.Method)
+			result = "null"
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewEVMChainClient(server.URL, "", testArtist, testSeller)
+	if err != nil {
+		t.Fatalf("NewEVMChainClient: %v", err)
+	}
+	return client
+}
+
+func TestEVMChainMintStatus(t *testing.T) {
+	const txHash = "0xabc"
+	transferLog := `{"address":"` + testArtist + `","topics":["` + topicTransfer + `",` +
+		`"0x0000000000000000000000000000000000000000000000000000000000000000",` +
+		`"0x0000000000000000000000001111111111111111111111111111111111111111",` +
+		`"0x000000000000000000000000000000000000000000000000000000000000002a"]}`
+
+	tests := []struct {
+		name        string
+		results     map[string]string
+		wantTokenID string
+		wantErr     error
+	}{
+		{
+			name:        "confirmed",
+			results:     map[string]string{"eth_getTransactionReceipt": `{"status":"0x1","logs":[` + transferLog + `]}`},
+			wantTokenID: "42",
+		},
+		{
+			name:    "reverted",
+			results: map[string]string{"eth_getTransactionReceipt": `{"status":"0x0","logs":[]}`},
+			wantErr: ErrChainTxReverted,
+		},
+		{
+			name: "pending",
+			results: map[string]string{
+				"eth_getTransactionReceipt": "null",
+				"eth_getTransactionByHash":  `{"hash":"` + txHash + `"}`,
+			},
+			wantErr: ErrChainTxPending,
+		},
+		{
+			name: "dropped",
+			results: map[string]string{
+				"eth_getTransactionReceipt": "null",
+				"eth_getTransactionByHash":  "null",
+			},
+			wantErr: ErrChainTxNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fakeRPC(t, tt.results).MintStatus(context.Background(), txHash)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MintStatus: err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (result.TokenID != tt.wantTokenID || result.TxHash != txHash) {
+				t.Errorf("MintStatus = %+v, want token %s from %s", result, tt.wantTokenID, txHash)
+			}
+		})
+	}
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// StrikeLimit is how many active copyright strikes suspend an account
+	// under the repeat infringer policy.
+	StrikeLimit = 3
+	// CounterNoticeWindowDays is how many business days an uploader has to
+	// file a counter-notice before the notice's strike is recorded.
+	CounterNoticeWindowDays = 10
+	// CounterNoticeWaitDays is how many business days content stays disabled
+	// after a counter-notice, giving the complainant time to file suit.
+	CounterNoticeWaitDays = 10
+	// copyrightSweepInterval is how often notices are checked for elapsed
+	// deadlines.
+	copyrightSweepInterval = 15 * time.Minute
+)
+
+// CopyrightService handles the side effects of DMCA notices: disabling and
+// restoring content, counting strikes against uploaders and notifying the
+// parties.
+type CopyrightService struct {
+	db            *database.Database
+	moderation    *ModerationService
+	notifications *NotificationService
+}
+
+func NewCopyrightService(db *database.Database, moderation *ModerationService, notifications *NotificationService) *CopyrightService {
+	return &CopyrightService{db: db, moderation: moderation, notifications: notifications}
+}
+
+// Takedown disables the content named in a notice and notifies the
+// uploader. No strike is recorded until the notice is upheld or the
+// counter-notice window passes.
+func (s *CopyrightService) Takedown(ctx context.Context, notice *models.DMCANotice) error {
+	err := s.moderation.Apply(ctx, &models.ModerationAction{
+		Action:     "dmca_disable",
+		TargetType: notice.TargetType,
+		TargetID:   notice.TargetID,
+		Note:       "Disabled in response to a copyright notice",
+	})
+	if err != nil {
+		return err
+	}
+
+	s.Notify(ctx, notice.UploaderID, "takedown", notice, map[string]interface{}{
+		"counterDeadline": notice.CounterDeadline,
+	})
+	return nil
+}
+
+// RecordStrike records the strike a notice causes against the uploader, at
+// most once, and suspends the uploader once they reach StrikeLimit. Notices
+// that were rejected or restored meanwhile don't cause one.
+func (s *CopyrightService) RecordStrike(ctx context.Context, notice *models.DMCANotice) error {
+	// Mark the notice first so a strike can't land on a notice that is
+	// being rejected or restored
+	now := time.Now()
+	result, err := s.db.DMCANotices().UpdateOne(
+		ctx,
+		bson.M{
+			"_id":       notice.ID,
+			"status":    bson.M{"$in": []string{"disabled", "upheld"}},
+			"struck_at": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"struck_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return nil
+	}
+
+	strike := models.CopyrightStrike{
+		ID:        primitive.NewObjectID().Hex(),
+		UserID:    notice.UploaderID,
+		NoticeID:  notice.ID,
+		CreatedAt: now,
+	}
+	_, err = s.db.CopyrightStrikes().InsertOne(ctx, strike)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		// Let the sweep try again
+		s.db.DMCANotices().UpdateOne(ctx, bson.M{"_id": notice.ID}, bson.M{"$unset": bson.M{"struck_at": ""}})
+		return err
+	}
+
+	strikes, err := s.ActiveStrikes(ctx, notice.UploaderID)
+	if err != nil {
+		return err
+	}
+
+	s.Notify(ctx, notice.UploaderID, "strike", notice, map[string]interface{}{
+		"strikes":     strikes,
+		"strikeLimit": StrikeLimit,
+	})
+
+	if strikes >= StrikeLimit {
+		return s.suspendRepeatInfringer(ctx, notice.UploaderID, strikes)
+	}
+	return nil
+}
+
+// suspendRepeatInfringer permanently suspends a user who reached
+// StrikeLimit, unless they are already suspended for it. The suspension is
+// recorded against a resolved repeat_infringement report, which lets Restore
+// lift it when a strike is retracted and moderators review it like any
+// other.
+func (s *CopyrightService) suspendRepeatInfringer(ctx context.Context, userID string, strikes int64) error {
+	var user models.User
+	err := s.db.Users().FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err != nil {
+		return err
+	}
+
+	if user.Suspension.Active(time.Now()) && user.Suspension.ReportID != "" {
+		count, err := s.db.Reports().CountDocuments(ctx, bson.M{
+			"_id":    user.Suspension.ReportID,
+			"reason": models.RepeatInfringementReason,
+		})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	now := time.Now()
+	report := models.Report{
+		ID:             primitive.NewObjectID().Hex(),
+		TargetType:     "user",
+		TargetID:       userID,
+		TargetOwnerID:  userID,
+		Reason:         models.RepeatInfringementReason,
+		Details:        fmt.Sprintf("%d active copyright strikes", strikes),
+		Status:         "resolved",
+		Action:         "suspend",
+		ResolutionNote: "Suspended automatically under the repeat infringer policy",
+		ResolvedAt:     &now,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if _, err := s.db.Reports().InsertOne(ctx, report); err != nil {
+		return err
+	}
+
+	err = s.moderation.Apply(ctx, &models.ModerationAction{
+		ReportID:   report.ID,
+		Action:     "suspend",
+		TargetType: "user",
+		TargetID:   userID,
+		Note:       "Repeat copyright infringement",
+	})
+	if err != nil {
+		// Leave it to moderators rather than lose it
+		s.db.Reports().UpdateOne(ctx, bson.M{"_id": report.ID}, bson.M{
+			"$set":   bson.M{"status": "open", "updated_at": time.Now()},
+			"$unset": bson.M{"action": "", "resolution_note": "", "resolved_at": ""},
+		})
+		return err
+	}
+
+	return nil
+}
+
+// Restore re-enables the content named in a notice and retracts the strike
+// it caused. If that takes the uploader back under StrikeLimit, a
+// suspension imposed for repeat infringement is lifted and open reports
+// about it are dismissed.
+func (s *CopyrightService) Restore(ctx context.Context, notice *models.DMCANotice) error {
+	err := s.moderation.Apply(ctx, &models.ModerationAction{
+		Action:     "dmca_restore",
+		TargetType: notice.TargetType,
+		TargetID:   notice.TargetID,
+		Note:       "Restored after a copyright notice was resolved",
+	})
+	if err != nil && err != ErrTargetNotFound {
+		return err
+	}
+
+	now := time.Now()
+	_, err = s.db.CopyrightStrikes().UpdateOne(
+		ctx,
+		bson.M{"notice_id": notice.ID, "retracted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"retracted_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Checked even when the strike was already retracted, so a retry
+	// finishes what a failed attempt started
+	strikes, err := s.ActiveStrikes(ctx, notice.UploaderID)
+	if err != nil {
+		return err
+	}
+	if strikes >= StrikeLimit {
+		return nil
+	}
+
+	cursor, err := s.db.Reports().Find(ctx, bson.M{
+		"target_type": "user",
+		"target_id":   notice.UploaderID,
+		"reason":      models.RepeatInfringementReason,
+	})
+	if err != nil {
+		return err
+	}
+	var reports []models.Report
+	if err = cursor.All(ctx, &reports); err != nil {
+		return err
+	}
+
+	reportIDs := make([]string, len(reports))
+	for i, report := range reports {
+		reportIDs[i] = report.ID
+	}
+	if _, err := s.moderation.UnsuspendForReports(ctx, notice.UploaderID, reportIDs); err != nil {
+		return err
+	}
+
+	_, err = s.db.Reports().UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": reportIDs}, "status": bson.M{"$in": []string{"open", "reviewing"}}},
+		bson.M{"$set": bson.M{
+			"status":          "dismissed",
+			"resolution_note": "Copyright strikes retracted",
+			"resolved_at":     now,
+			"updated_at":      now,
+		}},
+	)
+	return err
+}
+
+// FinishRestore restores the content of a notice in the restoring status
+// and then completes it: rejected if a moderator rejected it, restored
+// otherwise. A notice left restoring by a failure is retried by Run.
+func (s *CopyrightService) FinishRestore(ctx context.Context, notice *models.DMCANotice) error {
+	if err := s.Restore(ctx, notice); err != nil {
+		return err
+	}
+
+	status := "restored"
+	if notice.ResolvedBy != "" {
+		status = "rejected"
+	}
+
+	result, err := s.db.DMCANotices().UpdateOne(
+		ctx,
+		bson.M{"_id": notice.ID, "status": "restoring"},
+		bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return nil
+	}
+
+	notice.Status = status
+	s.Notify(ctx, notice.UploaderID, status, notice, nil)
+	return nil
+}
+
+// ActiveStrikes counts the unretracted copyright strikes against a user.
+func (s *CopyrightService) ActiveStrikes(ctx context.Context, userID string) (int64, error) {
+	return s.db.CopyrightStrikes().CountDocuments(ctx, bson.M{
+		"user_id":      userID,
+		"retracted_at": bson.M{"$exists": false},
+	})
+}
+
+// Notify sends a party to a notice a copyright notification about event,
+// carrying the notice's details along with data.
+func (s *CopyrightService) Notify(ctx context.Context, userID, event string, notice *models.DMCANotice, data map[string]interface{}) {
+	details := map[string]interface{}{
+		"event":           event,
+		"status":          notice.Status,
+		"contentType":     notice.TargetType,
+		"contentId":       notice.TargetID,
+		"complainantName": notice.ComplainantName,
+		"copyrightedWork": notice.CopyrightedWork,
+		"infringingUrl":   notice.InfringingURL,
+	}
+	if notice.OriginalWorkURL != "" {
+		details["originalWorkUrl"] = notice.OriginalWorkURL
+	}
+	if notice.ResolutionNote != "" {
+		details["note"] = notice.ResolutionNote
+	}
+	for key, value := range data {
+		details[key] = value
+	}
+
+	s.notifications.Notify(ctx, "", models.Notification{
+		UserID:     userID,
+		Type:       "copyright",
+		TargetType: "dmca_notice",
+		TargetID:   notice.ID,
+		Data:       details,
+	})
+}
+
+// Run periodically records the strikes of notices whose counter-notice
+// window has passed uncontested, and restores content whose counter-notice waiting
+// period has elapsed without the complainant filing suit.
+func (s *CopyrightService) Run(ctx context.Context) {
+	ticker := time.NewTicker(copyrightSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		s.strikeUncontested(ctx)
+		s.restoreElapsed(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *CopyrightService) strikeUncontested(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, time.Minute)
+	defer cancel()
+
+	cursor, err := s.db.DMCANotices().Find(ctx, bson.M{
+		"$or": []bson.M{
+			{"status": "disabled", "counter_deadline": bson.M{"$lte": time.Now()}},
+			// Upheld notices whose strike failed to record
+			{"status": "upheld"},
+		},
+		"struck_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		log.Printf("Failed to find uncontested DMCA notices: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var notices []models.DMCANotice
+	if err = cursor.All(ctx, &notices); err != nil {
+		log.Printf("Failed to decode DMCA notices: %v", err)
+		return
+	}
+
+	for i := range notices {
+		if err := s.RecordStrike(ctx, &notices[i]); err != nil {
+			log.Printf("Failed to record strike for DMCA notice %s: %v", notices[i].ID, err)
+		}
+	}
+}
+
+func (s *CopyrightService) restoreElapsed(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, time.Minute)
+	defer cancel()
+
+	// Claim elapsed notices first so a concurrent moderator decision can't
+	// change them; the restore below picks them up with any left over from
+	// earlier failures
+	_, err := s.db.DMCANotices().UpdateMany(
+		ctx,
+		bson.M{"status": "counter_noticed", "restore_after": bson.M{"$lte": time.Now()}},
+		bson.M{"$set": bson.M{"status": "restoring", "updated_at": time.Now()}},
+	)
+	if err != nil {
+		log.Printf("Failed to claim elapsed counter-notices: %v", err)
+		return
+	}
+
+	cursor, err := s.db.DMCANotices().Find(ctx, bson.M{"status": "restoring"})
+	if err != nil {
+		log.Printf("Failed to find DMCA notices to restore: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var notices []models.DMCANotice
+	if err = cursor.All(ctx, &notices); err != nil {
+		log.Printf("Failed to decode DMCA notices: %v", err)
+		return
+	}
+
+	for i := range notices {
+		if err := s.FinishRestore(ctx, &notices[i]); err != nil {
+			log.Printf("Failed to restore content for DMCA notice %s: %v", notices[i].ID, err)
+		}
+	}
+}
+
+// AddBusinessDays returns t moved forward by days weekdays.
+func AddBusinessDays(t time.Time, days int) time.Time {
+	for days > 0 {
+		t = t.AddDate(0, 0, 1)
+		if t.Weekday() != time.Saturday && t.Weekday() != time.Sunday {
+			days--
+		}
+	}
+	return t
+}
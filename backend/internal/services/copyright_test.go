@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddBusinessDays(t *testing.T) {
+	// 2024-01-01 is a Monday
+	day := func(d int) time.Time {
+		return time.Date(2024, time.January, d, 15, 30, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		start time.Time
+		days  int
+		want  time.Time
+	}{
+		{"zero days", day(1), 0, day(1)},
+		{"zero days on a weekend", day(6), 0, day(6)},
+		{"next weekday", day(1), 1, day(2)},
+		{"Friday to Monday", day(5), 1, day(8)},
+		{"from Saturday", day(6), 1, day(8)},
+		{"from Sunday", day(7), 1, day(8)},
+		{"a week", day(1), 5, day(8)},
+		{"across a weekend", day(4), 3, day(9)},
+		{"counter-notice window", day(5), CounterNoticeWindowDays, day(19)},
+		{"counter-notice wait from midweek", day(3), CounterNoticeWaitDays, day(17)},
+		{"counter-notice filed on a Sunday", day(7), CounterNoticeWaitDays, day(19)},
+		{"across a month end", time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC), 2, time.Date(2024, time.February, 2, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AddBusinessDays(tt.start, tt.days); !got.Equal(tt.want) {
+				t.Errorf("AddBusinessDays(%s, %d) = %s, want %s", tt.start.Format("Mon Jan 2"), tt.days, got.Format("Mon Jan 2 15:04"), tt.want.Format("Mon Jan 2 15:04"))
+			}
+		})
+	}
+}
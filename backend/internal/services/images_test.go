@@ -0,0 +1,177 @@
+package services
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestTargetSizes(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want []int
+	}{
+		{"smaller than the smallest rendition", 100, []int{100}},
+		{"exactly the smallest rendition", 320, []int{320}},
+		{"between renditions", 500, []int{320, 500}},
+		{"exactly a middle rendition", 640, []int{320, 640}},
+		{"exactly the largest rendition", 2048, []int{320, 640, 1080, 2048}},
+		{"larger than the largest rendition", 4000, []int{320, 640, 1080, 2048}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := targetSizes(tt.size, renditionWidths); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targetSizes(%d) = %v, want %v", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+// labelledImage builds an image whose pixels are told apart by their red
+// value, laid out in rows of labels.
+func labelledImage(rows [][]uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, label := range row {
+			img.SetNRGBA(x, y, color.NRGBA{R: label, A: 255})
+		}
+	}
+	return img
+}
+
+func labels(img image.Image) [][]uint8 {
+	bounds := img.Bounds()
+	rows := make([][]uint8, bounds.Dy())
+	for y := range rows {
+		rows[y] = make([]uint8, bounds.Dx())
+		for x := range rows[y] {
+			rows[y][x] = color.NRGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA).R
+		}
+	}
+	return rows
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 1 2 3
+	// 4 5 6
+	src := [][]uint8{{1, 2, 3}, {4, 5, 6}}
+
+	tests := []struct {
+		orientation int
+		want        [][]uint8
+	}{
+		{0, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{1, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{2, [][]uint8{{3, 2, 1}, {6, 5, 4}}},
+		{3, [][]uint8{{6, 5, 4}, {3, 2, 1}}},
+		{4, [][]uint8{{4, 5, 6}, {1, 2, 3}}},
+		{5, [][]uint8{{1, 4}, {2, 5}, {3, 6}}},
+		{6, [][]uint8{{4, 1}, {5, 2}, {6, 3}}},
+		{7, [][]uint8{{6, 3}, {5, 2}, {4, 1}}},
+		{8, [][]uint8{{3, 6}, {2, 5}, {1, 4}}},
+		{9, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+	}
+
+	for _, tt := range tests {
+		got := labels(applyOrientation(labelledImage(src), tt.orientation))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("applyOrientation(%d) = %v, want %v", tt.orientation, got, tt.want)
+		}
+	}
+}
+
+func TestApplyOrientationOffsetBounds(t *testing.T) {
+	// Decoded sub-images don't always start at the origin
+	img := labelledImage([][]uint8{{0, 0, 0}, {0, 1, 2}, {0, 3, 4}}).SubImage(image.Rect(1, 1, 3, 3))
+
+	got := labels(applyOrientation(img, 6))
+	want := [][]uint8{{3, 1}, {4, 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("applyOrientation(6) = %v, want %v", got, want)
+	}
+}
+
+// tiffWithEntry builds a TIFF header and a first IFD holding one entry.
+func tiffWithEntry(order binary.ByteOrder, tag, typ, value uint16) []byte {
+	tiff := make([]byte, 8+2+12)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], tag)
+	order.PutUint16(tiff[12:], typ)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], value)
+	return tiff
+}
+
+func TestExifOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"little endian", tiffWithEntry(binary.LittleEndian, 0x0112, 3, 6), 6},
+		{"big endian", tiffWithEntry(binary.BigEndian, 0x0112, 3, 8), 8},
+		{"upright", tiffWithEntry(binary.BigEndian, 0x0112, 3, 1), 1},
+		{"zero", tiffWithEntry(binary.LittleEndian, 0x0112, 3, 0), 1},
+		{"out of range", tiffWithEntry(binary.LittleEndian, 0x0112, 3, 9), 1},
+		{"other tag", tiffWithEntry(binary.LittleEndian, 0x010F, 3, 6), 1},
+		{"not a short", tiffWithEntry(binary.LittleEndian, 0x0112, 4, 6), 1},
+		{"truncated entry", tiffWithEntry(binary.LittleEndian, 0x0112, 3, 6)[:16], 1},
+		{"unknown byte order", append([]byte("XX"), tiffWithEntry(binary.LittleEndian, 0x0112, 3, 6)[2:]...), 1},
+		{"IFD past the end", []byte{'I', 'I', 42, 0, 0xFF, 0, 0, 0}, 1},
+		{"too short", []byte("II*"), 1},
+		{"empty", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.tiff); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// jpegWithSegment wraps a segment's payload in a JPEG start marker, the
+// segment and the start of the image data.
+func jpegWithSegment(marker byte, payload []byte) []byte {
+	data := []byte{0xFF, 0xD8, 0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(data[4:], uint16(len(payload)+2))
+	data = append(data, payload...)
+	return append(data, 0xFF, 0xDA, 0, 2)
+}
+
+func TestJpegOrientation(t *testing.T) {
+	exif := append([]byte("Exif\x00\x00"), tiffWithEntry(binary.BigEndian, 0x0112, 3, 3)...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"EXIF segment", jpegWithSegment(0xE1, exif), 3},
+		{"APP1 without EXIF", jpegWithSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00")), 1},
+		{"EXIF after another segment", append(jpegWithSegment(0xE0, []byte("JFIF\x00"))[:11], jpegWithSegment(0xE1, exif)[2:]...), 3},
+		{"no segments", []byte{0xFF, 0xD8, 0xFF, 0xDA, 0, 2}, 1},
+		{"segment longer than the data", jpegWithSegment(0xE1, exif)[:20], 1},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
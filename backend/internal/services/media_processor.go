@@ -0,0 +1,649 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// mediaPollInterval is how often the queue is checked when idle.
+	mediaPollInterval = 5 * time.Second
+	// maxMediaProcessingAttempts is how often a job is tried before it is
+	// marked failed.
+	maxMediaProcessingAttempts = 3
+	// maxImagePixels guards against decompression bombs: small files that
+	// decode into enormous images.
+	maxImagePixels = 50_000_000
+	jpegQuality    = 85
+	webpQuality    = 80
+)
+
+// processingLease is how long a worker may hold a job before it is assumed to
+// have crashed and the job is handed out again.
+func processingLease(mediaType string) time.Duration {
+	if mediaType == "video" {
+		return 2 * time.Hour
+	}
+	return 10 * time.Minute
+}
+
+// MediaProcessor works through queued uploads in the background. Images are
+// turned upright, resized into renditions and re-encoded, which drops their
+// EXIF metadata including GPS location. Videos are transcoded to HLS with a
+// poster frame. Originals are deleted, and posts are published once all of
+// their media is ready.
+type MediaProcessor struct {
+	db    *database.Database
+	store BlobStore
+	hub   *RealtimeHub
+	// profiles syncs profile photos replaced by their processed version
+	profiles *ProfileSyncService
+	// ffmpeg and ffprobe are the paths of the binaries, used for videos,
+	// WebP and HEIC. They are empty when not installed.
+	ffmpeg  string
+	ffprobe string
+}
+
+// NewMediaProcessor looks up ffmpeg and ffprobe at FFMPEG_PATH and
+// FFPROBE_PATH, or on the PATH. Without them, images only get JPEG and PNG
+// renditions, and HEIC images and videos fail to process.
+func NewMediaProcessor(db *database.Database, store BlobStore, hub *RealtimeHub, profiles *ProfileSyncService) *MediaProcessor {
+	return &MediaProcessor{
+		db:       db,
+		store:    store,
+		hub:      hub,
+		profiles: profiles,
+		ffmpeg:   lookPathEnv("FFMPEG_PATH", "ffmpeg"),
+		ffprobe:  lookPathEnv("FFPROBE_PATH", "ffprobe"),
+	}
+}
+
+func lookPathEnv(env, fallback string) string {
+	name := os.Getenv(env)
+	if name == "" {
+		name = fallback
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		log.Printf("%s not found, media processing is limited: %v", fallback, err)
+		return ""
+	}
+	return path
+}
+
+// Run processes images and videos in separate loops, so long transcodes
+// don't hold up images.
+func (p *MediaProcessor) Run(ctx context.Context) {
+	go p.work(ctx, "video")
+	p.work(ctx, "image")
+}
+
+func (p *MediaProcessor) work(ctx context.Context, mediaType string) {
+	ticker := time.NewTicker(mediaPollInterval)
+	defer ticker.Stop()
+
+	for {
+		p.drain(ctx, mediaType)
+		p.publishReadyPosts(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain processes jobs until the queue is empty.
+func (p *MediaProcessor) drain(ctx context.Context, mediaType string) {
+	p.failAbandoned(ctx, mediaType)
+
+	for ctx.Err() == nil {
+		media, err := p.claim(ctx, mediaType)
+		if err == mongo.ErrNoDocuments {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to claim %s job: %v", mediaType, err)
+			return
+		}
+
+		p.process(ctx, media)
+	}
+}
+
+// claim takes the oldest queued job, or one whose worker's lease expired.
+func (p *MediaProcessor) claim(parent context.Context, mediaType string) (*models.Media, error) {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"type":                mediaType,
+		"status":              "uploaded",
+		"processing_attempts": bson.M{"$lt": maxMediaProcessingAttempts},
+		"$or": []bson.M{
+			{"processing_status": "queued"},
+			{"processing_status": "processing", "processing_started_at": bson.M{"$lt": now.Add(-processingLease(mediaType))}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{"processing_status": "processing", "processing_started_at": now},
+		"$inc": bson.M{"processing_attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var media models.Media
+	if err := p.db.Media().FindOneAndUpdate(ctx, filter, update, opts).Decode(&media); err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// failAbandoned gives up on jobs whose last attempt's worker never came
+// back.
+func (p *MediaProcessor) failAbandoned(parent context.Context, mediaType string) {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+
+	_, err := p.db.Media().UpdateMany(ctx, bson.M{
+		"type":                  mediaType,
+		"processing_status":     "processing",
+		"processing_started_at": bson.M{"$lt": time.Now().Add(-processingLease(mediaType))},
+		"processing_attempts":   bson.M{"$gte": maxMediaProcessingAttempts},
+	}, bson.M{
+		"$set":   bson.M{"processing_status": "failed", "processing_error": "Processing timed out", "updated_at": time.Now()},
+		"$unset": bson.M{"processing_started_at": ""},
+	})
+	if err != nil {
+		log.Printf("Failed to expire %s jobs: %v", mediaType, err)
+	}
+}
+
+// processedMedia is the outcome of processing a media.
+type processedMedia struct {
+	width      int
+	height     int
+	blurhash   string
+	renditions []models.MediaRendition
+	duration   float64
+	poster     *models.MediaRendition
+	assetKeys  []string
+	// served replaces the original as the media's URL. It is nil when the
+	// original is served as is.
+	served *servedBlob
+}
+
+type servedBlob struct {
+	key         string
+	url         string
+	contentType string
+	size        int64
+}
+
+// keys lists every blob stored while processing.
+func (r *processedMedia) keys() []string {
+	var keys []string
+	for _, rendition := range r.renditions {
+		keys = append(keys, rendition.Key)
+	}
+	if r.poster != nil {
+		keys = append(keys, r.poster.Key)
+	}
+	if r.served != nil {
+		keys = append(keys, r.served.key)
+	}
+	return append(keys, r.assetKeys...)
+}
+
+func (p *MediaProcessor) process(parent context.Context, media *models.Media) {
+	ctx, cancel := context.WithTimeout(parent, processingLease(media.Type))
+	defer cancel()
+
+	var result *processedMedia
+	var err error
+	if media.Type == "video" {
+		result, err = p.processVideo(ctx, media)
+	} else {
+		result, err = p.processImage(ctx, media)
+	}
+
+	if err != nil {
+		log.Printf("Failed to process media %s (attempt %d): %v", media.ID, media.ProcessingAttempts, err)
+
+		status := "queued"
+		if media.ProcessingAttempts >= maxMediaProcessingAttempts {
+			status = "failed"
+		}
+
+		message := "Image could not be processed"
+		if media.Type == "video" {
+			message = "Video could not be processed"
+		}
+
+		_, err = p.db.Media().UpdateOne(ctx, p.jobFilter(media), bson.M{
+			"$set":   bson.M{"processing_status": status, "processing_error": message, "updated_at": time.Now()},
+			"$unset": bson.M{"processing_started_at": ""},
+		})
+		if err != nil {
+			log.Printf("Failed to update media %s: %v", media.ID, err)
+		}
+		return
+	}
+
+	p.finish(ctx, media, result)
+}
+
+// jobFilter matches a media only while this attempt still owns its job.
+func (p *MediaProcessor) jobFilter(media *models.Media) bson.M {
+	return bson.M{
+		"_id":                 media.ID,
+		"processing_status":   "processing",
+		"processing_attempts": media.ProcessingAttempts,
+	}
+}
+
+// finish records processed media and replaces the original with what was
+// made from it.
+func (p *MediaProcessor) finish(ctx context.Context, media *models.Media, result *processedMedia) {
+	set := bson.M{
+		"processing_status": "ready",
+		"width":             result.width,
+		"height":            result.height,
+		"blurhash":          result.blurhash,
+		"renditions":        result.renditions,
+		"duration":          result.duration,
+		"poster":            result.poster,
+		"asset_keys":        result.assetKeys,
+		"updated_at":        time.Now(),
+	}
+	if result.served != nil {
+		set["key"] = result.served.key
+		set["url"] = result.served.url
+		set["content_type"] = result.served.contentType
+		set["size"] = result.served.size
+	}
+
+	updated, err := p.db.Media().UpdateOne(ctx, p.jobFilter(media), bson.M{
+		"$set":   set,
+		"$unset": bson.M{"processing_error": "", "processing_started_at": ""},
+	})
+	if err != nil || updated.MatchedCount == 0 {
+		// The media was deleted meanwhile, or another worker took the job over
+		if err != nil {
+			log.Printf("Failed to update media %s: %v", media.ID, err)
+		}
+		p.deleteBlobs(ctx, result.keys())
+		return
+	}
+
+	if result.served == nil {
+		return
+	}
+
+	if err := p.store.Delete(ctx, media.Key); err != nil {
+		log.Printf("Failed to delete original of media %s: %v", media.ID, err)
+	}
+
+	if media.Type == "image" {
+		updated, err := p.db.Users().UpdateOne(
+			ctx,
+			bson.M{"_id": media.OwnerID, "profile_photo": media.URL},
+			bson.M{"$set": bson.M{"profile_photo": result.served.url, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			log.Printf("Failed to update profile photo for media %s: %v", media.ID, err)
+		} else if updated.ModifiedCount > 0 {
+			if err := p.profiles.Enqueue(ctx, media.OwnerID); err != nil {
+				log.Printf("Failed to schedule profile sync for user %s: %v", media.OwnerID, err)
+			}
+		}
+	}
+}
+
+func (p *MediaProcessor) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := p.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
+
+// NewMediaItem is how a post shows a media. Category is the post author's
+// label for it.
+func NewMediaItem(media *models.Media, category string) models.MediaItem {
+	return models.MediaItem{
+		MediaID:    media.ID,
+		URL:        media.URL,
+		Type:       media.Type,
+		Category:   category,
+		Width:      media.Width,
+		Height:     media.Height,
+		Blurhash:   media.Blurhash,
+		Renditions: media.Renditions,
+		Duration:   media.Duration,
+		Poster:     media.Poster,
+	}
+}
+
+// IsMediaReady reports whether a media can be shown in a published post.
+// Media uploaded before processing existed has no status and is ready.
+func IsMediaReady(media *models.Media) bool {
+	return media.ProcessingStatus == "" || media.ProcessingStatus == "ready"
+}
+
+// publishReadyPosts publishes posts whose media has all been processed, with
+// the final media URLs, and marks posts failed when any of their media
+// failed. Authors are notified with a "post" event either way.
+func (p *MediaProcessor) publishReadyPosts(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, time.Minute)
+	defer cancel()
+
+	cursor, err := p.db.Posts().Find(
+		ctx,
+		bson.M{"processing_status": "processing"},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(100),
+	)
+	if err != nil {
+		log.Printf("Failed to find processing posts: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var posts []models.Post
+	if err = cursor.All(ctx, &posts); err != nil {
+		log.Printf("Failed to decode processing posts: %v", err)
+		return
+	}
+	if len(posts) == 0 {
+		return
+	}
+
+	var mediaIDs []string
+	for _, post := range posts {
+		for _, item := range post.Media {
+			mediaIDs = append(mediaIDs, item.MediaID)
+		}
+	}
+
+	mediaCursor, err := p.db.Media().Find(ctx, bson.M{"_id": bson.M{"$in": mediaIDs}})
+	if err != nil {
+		log.Printf("Failed to fetch media of processing posts: %v", err)
+		return
+	}
+	defer mediaCursor.Close(ctx)
+
+	var found []models.Media
+	if err = mediaCursor.All(ctx, &found); err != nil {
+		log.Printf("Failed to decode media of processing posts: %v", err)
+		return
+	}
+
+	byID := map[string]*models.Media{}
+	for i := range found {
+		byID[found[i].ID] = &found[i]
+	}
+
+	for _, post := range posts {
+		items := make([]models.MediaItem, 0, len(post.Media))
+		failed, pending := false, false
+		for _, item := range post.Media {
+			media, ok := byID[item.MediaID]
+			switch {
+			case !ok || media.ProcessingStatus == "failed":
+				failed = true
+			case !IsMediaReady(media):
+				pending = true
+			default:
+				items = append(items, NewMediaItem(media, item.Category))
+			}
+		}
+
+		filter := bson.M{"_id": post.ID, "processing_status": "processing"}
+
+		var update bson.M
+		var event string
+		switch {
+		case failed:
+			update = bson.M{"$set": bson.M{"processing_status": "failed", "updated_at": time.Now()}}
+			event = "failed"
+		case pending:
+			continue
+		default:
+			update = bson.M{
+				"$set":   bson.M{"media": items, "updated_at": time.Now()},
+				"$unset": bson.M{"processing_status": ""},
+			}
+			event = "published"
+		}
+
+		result, err := p.db.Posts().UpdateOne(ctx, filter, update)
+		if err != nil {
+			log.Printf("Failed to update processing post %s: %v", post.ID, err)
+			continue
+		}
+		if result.ModifiedCount > 0 {
+			p.hub.Publish("post", map[string]interface{}{
+				"event":  event,
+				"postId": post.ID,
+			}, post.UserID)
+		}
+	}
+}
+
+func (p *MediaProcessor) processImage(ctx context.Context, media *models.Media) (*processedMedia, error) {
+	data, err := p.readBlob(ctx, media.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := p.decodeImage(ctx, media.ContentType, data)
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	// GIFs may be animated, which renditions would flatten. They carry no
+	// EXIF, so the original is kept.
+	if media.ContentType == "image/gif" {
+		bounds := img.Bounds()
+		return &processedMedia{width: bounds.Dx(), height: bounds.Dy(), blurhash: Blurhash(img)}, nil
+	}
+
+	if media.ContentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	bounds := img.Bounds()
+	result := &processedMedia{width: bounds.Dx(), height: bounds.Dy(), blurhash: Blurhash(img)}
+	alpha := hasAlpha(img)
+
+	for _, width := range targetSizes(bounds.Dx(), renditionWidths) {
+		renditions, err := p.storeRenditions(ctx, media.ID, resizeToWidth(img, width), alpha)
+		if err != nil {
+			p.deleteBlobs(ctx, result.keys())
+			return nil, err
+		}
+		result.renditions = append(result.renditions, renditions...)
+	}
+
+	// Serve the largest rendition in a format every client can show
+	for _, rendition := range result.renditions {
+		if rendition.Format != "webp" {
+			result.served = &servedBlob{
+				key:         rendition.Key,
+				url:         rendition.URL,
+				contentType: "image/" + rendition.Format,
+				size:        rendition.Size,
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (p *MediaProcessor) readBlob(ctx context.Context, key string) ([]byte, error) {
+	blob, err := p.store.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	return io.ReadAll(blob)
+}
+
+func (p *MediaProcessor) decodeImage(ctx context.Context, contentType string, data []byte) (image.Image, error) {
+	if contentType == "image/heic" {
+		return p.decodeHEIC(ctx, data)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("image is too large: %dx%d", config.Width, config.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// decodeHEIC converts a HEIC image with ffmpeg, which also applies its
+// rotation.
+func (p *MediaProcessor) decodeHEIC(ctx context.Context, data []byte) (image.Image, error) {
+	if p.ffmpeg == "" {
+		return nil, errors.New("ffmpeg is required to decode HEIC")
+	}
+
+	// The HEIF demuxer needs a seekable input, so it can't read a pipe
+	tmp, err := os.CreateTemp("", "heic-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := p.runFFmpeg(ctx, nil, "-i", tmp.Name(), "-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "pipe:1")
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(output))
+}
+
+// storeRenditions encodes one size of an image as JPEG, or PNG if it has
+// transparency, and as WebP when ffmpeg is available.
+func (p *MediaProcessor) storeRenditions(ctx context.Context, mediaID string, img *image.NRGBA, alpha bool) ([]models.MediaRendition, error) {
+	var encoded bytes.Buffer
+	format, ext, contentType := "jpeg", ".jpg", "image/jpeg"
+	if alpha {
+		format, ext, contentType = "png", ".png", "image/png"
+		if err := png.Encode(&encoded, img); err != nil {
+			return nil, err
+		}
+	} else if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	key := fmt.Sprintf("media/%s/w%d%s", mediaID, width, ext)
+	rendition, err := p.putRendition(ctx, key, width, height, format, contentType, encoded.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	renditions := []models.MediaRendition{rendition}
+
+	if p.ffmpeg == "" {
+		return renditions, nil
+	}
+
+	// A missing WebP rendition only costs bandwidth, so don't fail the job
+	// over it
+	webp, err := p.encodeWebP(ctx, img)
+	if err != nil {
+		log.Printf("Failed to encode WebP rendition of media %s: %v", mediaID, err)
+		return renditions, nil
+	}
+
+	key = fmt.Sprintf("media/%s/w%d.webp", mediaID, width)
+	rendition, err = p.putRendition(ctx, key, width, height, "webp", "image/webp", webp)
+	if err != nil {
+		p.deleteBlobs(ctx, []string{renditions[0].Key})
+		return nil, err
+	}
+	return append(renditions, rendition), nil
+}
+
+func (p *MediaProcessor) putRendition(ctx context.Context, key string, width, height int, format, contentType string, data []byte) (models.MediaRendition, error) {
+	if err := p.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return models.MediaRendition{}, err
+	}
+
+	return models.MediaRendition{
+		Key:    key,
+		URL:    p.store.URL(key),
+		Width:  width,
+		Height: height,
+		Format: format,
+		Size:   int64(len(data)),
+	}, nil
+}
+
+func (p *MediaProcessor) encodeWebP(ctx context.Context, img image.Image) ([]byte, error) {
+	// Hand ffmpeg lossless pixels; compressing them is its job
+	var input bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.NoCompression}
+	if err := encoder.Encode(&input, img); err != nil {
+		return nil, err
+	}
+
+	return p.runFFmpeg(ctx, &input,
+		"-f", "png_pipe", "-i", "pipe:0",
+		"-c:v", "libwebp", "-quality", fmt.Sprint(webpQuality),
+		"-f", "webp", "pipe:1",
+	)
+}
+
+// runFFmpeg runs ffmpeg with stdin as its input and returns its output.
+func (p *MediaProcessor) runFFmpeg(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	return runCommand(ctx, p.ffmpeg, stdin, append([]string{"-hide_banner", "-loglevel", "error", "-nostdin"}, args...)...)
+}
+
+func runCommand(ctx context.Context, name string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/reaviseapp/rv-backend/internal/models"
+)
+
+const (
+	// hlsSegmentSeconds is the target length of an HLS segment. Keyframes
+	// are forced at the same interval so every variant splits identically.
+	hlsSegmentSeconds = 6
+	posterMaxWidth    = 1280
+	audioBitrate      = 128_000
+)
+
+// videoVariant is an HLS rendition, sized by the video's shorter side so
+// portrait and landscape videos get the same quality.
+type videoVariant struct {
+	size    int
+	maxRate int // video bits per second
+}
+
+var videoVariants = []videoVariant{
+	{size: 360, maxRate: 800_000},
+	{size: 720, maxRate: 2_800_000},
+	{size: 1080, maxRate: 5_000_000},
+}
+
+// variantFor returns the variant settings for a rendition of the given size.
+func variantFor(size int) videoVariant {
+	for _, variant := range videoVariants {
+		if size <= variant.size {
+			return videoVariant{size: size, maxRate: variant.maxRate}
+		}
+	}
+	return videoVariants[len(videoVariants)-1]
+}
+
+// videoProbe is the part of ffprobe's JSON output we use.
+type videoProbe struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+		Tags      struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// processVideo transcodes a video into HLS variants, takes a poster frame
+// and records its duration. The original, and any metadata in it, is
+// dropped.
+func (p *MediaProcessor) processVideo(ctx context.Context, media *models.Media) (*processedMedia, error) {
+	if p.ffmpeg == "" || p.ffprobe == "" {
+		return nil, errors.New("ffmpeg and ffprobe are required to process videos")
+	}
+
+	dir, err := os.MkdirTemp("", "video-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input")
+	if err := p.downloadBlob(ctx, media.Key, input); err != nil {
+		return nil, err
+	}
+
+	width, height, duration, err := p.probeVideo(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("probe: %w", err)
+	}
+
+	result := &processedMedia{width: width, height: height, duration: duration}
+
+	if err := p.storePoster(ctx, media.ID, input, duration, result); err != nil {
+		p.deleteBlobs(ctx, result.keys())
+		return nil, fmt.Errorf("poster: %w", err)
+	}
+
+	if err := p.storeHLS(ctx, media.ID, input, dir, result); err != nil {
+		p.deleteBlobs(ctx, result.keys())
+		return nil, fmt.Errorf("transcode: %w", err)
+	}
+
+	return result, nil
+}
+
+func (p *MediaProcessor) downloadBlob(ctx context.Context, key, name string) error {
+	blob, err := p.store.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, blob)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// probeVideo returns a video's display size, after rotation, and duration in
+// seconds.
+func (p *MediaProcessor) probeVideo(ctx context.Context, input string) (int, int, float64, error) {
+	output, err := runCommand(ctx, p.ffprobe, nil, "-v", "error", "-show_streams", "-show_format", "-of", "json", input)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return parseProbe(output)
+}
+
+// parseProbe reads the display size and duration from ffprobe's JSON output.
+func parseProbe(output []byte) (int, int, float64, error) {
+	var probe videoProbe
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return 0, 0, 0, err
+	}
+
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil || duration <= 0 {
+		return 0, 0, 0, errors.New("unknown duration")
+	}
+
+	for _, stream := range probe.Streams {
+		if stream.CodecType != "video" || stream.Width == 0 || stream.Height == 0 {
+			continue
+		}
+
+		rotation, _ := strconv.ParseFloat(stream.Tags.Rotate, 64)
+		for _, sideData := range stream.SideDataList {
+			if sideData.Rotation != 0 {
+				rotation = sideData.Rotation
+			}
+		}
+
+		width, height := stream.Width, stream.Height
+		if math.Mod(math.Abs(rotation), 180) == 90 {
+			width, height = height, width
+		}
+		return width, height, duration, nil
+	}
+
+	return 0, 0, 0, errors.New("no video stream")
+}
+
+// storePoster grabs a frame near the start of the video as its poster and
+// placeholder.
+func (p *MediaProcessor) storePoster(ctx context.Context, mediaID, input string, duration float64, result *processedMedia) error {
+	at := math.Min(1, duration/2)
+	output, err := p.runFFmpeg(ctx, nil,
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64), "-i", input,
+		"-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "pipe:1",
+	)
+	if err != nil {
+		return err
+	}
+
+	frame, err := png.Decode(bytes.NewReader(output))
+	if err != nil {
+		return err
+	}
+	result.blurhash = Blurhash(frame)
+
+	poster := toNRGBA(frame)
+	if poster.Rect.Dx() > posterMaxWidth {
+		poster = resizeToWidth(poster, posterMaxWidth)
+	}
+
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, poster, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("media/%s/poster.jpg", mediaID)
+	rendition, err := p.putRendition(ctx, key, poster.Rect.Dx(), poster.Rect.Dy(), "jpeg", "image/jpeg", encoded.Bytes())
+	if err != nil {
+		return err
+	}
+	result.poster = &rendition
+	return nil
+}
+
+// storeHLS transcodes every variant and uploads their playlists and segments
+// along with a master playlist, which becomes the media's URL.
+func (p *MediaProcessor) storeHLS(ctx context.Context, mediaID, input, dir string, result *processedMedia) error {
+	hlsDir := filepath.Join(dir, "hls")
+	if err := os.Mkdir(hlsDir, 0o755); err != nil {
+		return err
+	}
+
+	shortSide := result.width
+	if result.height < shortSide {
+		shortSide = result.height
+	}
+
+	sizes := make([]int, 0, len(videoVariants))
+	for _, variant := range videoVariants {
+		sizes = append(sizes, variant.size)
+	}
+
+	keyPrefix := "media/" + mediaID + "/hls/"
+
+	var master strings.Builder
+	master.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+
+	for _, size := range targetSizes(shortSide, sizes) {
+		variant := variantFor(size)
+		width := evenDimension(result.width * size / shortSide)
+		height := evenDimension(result.height * size / shortSide)
+		name := fmt.Sprintf("%dp", size)
+
+		_, err := p.runFFmpeg(ctx, nil,
+			"-i", input,
+			"-map", "0:v:0", "-map", "0:a:0?",
+			"-vf", fmt.Sprintf("scale=%d:%d", width, height),
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "23", "-pix_fmt", "yuv420p",
+			"-maxrate", strconv.Itoa(variant.maxRate), "-bufsize", strconv.Itoa(2*variant.maxRate),
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsSegmentSeconds),
+			"-c:a", "aac", "-b:a", strconv.Itoa(audioBitrate), "-ac", "2",
+			"-map_metadata", "-1",
+			"-f", "hls", "-hls_time", strconv.Itoa(hlsSegmentSeconds), "-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(hlsDir, name+"_%04d.ts"),
+			filepath.Join(hlsDir, name+".m3u8"),
+		)
+		if err != nil {
+			return err
+		}
+
+		segments, err := filepath.Glob(filepath.Join(hlsDir, name+"_*.ts"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(segments)
+
+		var total int64
+		for _, segment := range segments {
+			key := keyPrefix + filepath.Base(segment)
+			written, err := p.putFile(ctx, key, segment, "video/mp2t")
+			if err != nil {
+				return err
+			}
+			result.assetKeys = append(result.assetKeys, key)
+			total += written
+		}
+
+		key := keyPrefix + name + ".m3u8"
+		written, err := p.putFile(ctx, key, filepath.Join(hlsDir, name+".m3u8"), "application/vnd.apple.mpegurl")
+		if err != nil {
+			return err
+		}
+		total += written
+
+		result.renditions = append(result.renditions, models.MediaRendition{
+			Key:    key,
+			URL:    p.store.URL(key),
+			Width:  width,
+			Height: height,
+			Format: "hls",
+			Size:   total,
+		})
+
+		// BANDWIDTH is the peak rate; the encoder is capped at maxRate
+		fmt.Fprintf(&master, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d\n%s.m3u8\n",
+			variant.maxRate+audioBitrate, width, height, name)
+	}
+
+	key := keyPrefix + "master.m3u8"
+	playlist := []byte(master.String())
+	if err := p.store.Put(ctx, key, bytes.NewReader(playlist), int64(len(playlist)), "application/vnd.apple.mpegurl"); err != nil {
+		return err
+	}
+
+	result.served = &servedBlob{
+		key:         key,
+		url:         p.store.URL(key),
+		contentType: "application/vnd.apple.mpegurl",
+		size:        int64(len(playlist)),
+	}
+	return nil
+}
+
+// putFile uploads a local file and returns its size.
+func (p *MediaProcessor) putFile(ctx context.Context, key, name, contentType string) (int64, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := p.store.Put(ctx, key, file, info.Size(), contentType); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// evenDimension rounds a size down to an even number, as H.264 with 4:2:0
+// chroma requires.
+func evenDimension(size int) int {
+	if size < 2 {
+		return 2
+	}
+	return size &^ 1
+}
@@ -0,0 +1,125 @@
+package services
+
+import "testing"
+
+func TestParseProbe(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		wantWidth    int
+		wantHeight   int
+		wantDuration float64
+		wantErr      bool
+	}{
+		{
+			name:         "landscape",
+			output:       `{"streams":[{"codec_type":"video","width":1920,"height":1080}],"format":{"duration":"12.5"}}`,
+			wantWidth:    1920,
+			wantHeight:   1080,
+			wantDuration: 12.5,
+		},
+		{
+			name:         "rotate tag",
+			output:       `{"streams":[{"codec_type":"video","width":1920,"height":1080,"tags":{"rotate":"90"}}],"format":{"duration":"3"}}`,
+			wantWidth:    1080,
+			wantHeight:   1920,
+			wantDuration: 3,
+		},
+		{
+			name:         "display matrix side data",
+			output:       `{"streams":[{"codec_type":"video","width":1920,"height":1080,"side_data_list":[{"rotation":-90}]}],"format":{"duration":"3"}}`,
+			wantWidth:    1080,
+			wantHeight:   1920,
+			wantDuration: 3,
+		},
+		{
+			name:         "upside down",
+			output:       `{"streams":[{"codec_type":"video","width":1920,"height":1080,"side_data_list":[{"rotation":180}]}],"format":{"duration":"3"}}`,
+			wantWidth:    1920,
+			wantHeight:   1080,
+			wantDuration: 3,
+		},
+		{
+			name:         "audio stream first",
+			output:       `{"streams":[{"codec_type":"audio"},{"codec_type":"video","width":640,"height":480}],"format":{"duration":"1"}}`,
+			wantWidth:    640,
+			wantHeight:   480,
+			wantDuration: 1,
+		},
+		{
+			name:    "missing duration",
+			output:  `{"streams":[{"codec_type":"video","width":640,"height":480}],"format":{}}`,
+			wantErr: true,
+		},
+		{
+			name:    "no video stream",
+			output:  `{"streams":[{"codec_type":"audio"}],"format":{"duration":"1"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "not JSON",
+			output:  `Invalid data found when processing input`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height, duration, err := parseProbe([]byte(tt.output))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseProbe = (%d, %d, %v), want an error", width, height, duration)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProbe: %v", err)
+			}
+			if width != tt.wantWidth || height != tt.wantHeight || duration != tt.wantDuration {
+				t.Errorf("parseProbe = (%d, %d, %v), want (%d, %d, %v)",
+					width, height, duration, tt.wantWidth, tt.wantHeight, tt.wantDuration)
+			}
+		})
+	}
+}
+
+func TestVariantFor(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want videoVariant
+	}{
+		{"below the smallest variant", 240, videoVariant{size: 240, maxRate: 800_000}},
+		{"exactly the smallest variant", 360, videoVariant{size: 360, maxRate: 800_000}},
+		{"between variants", 480, videoVariant{size: 480, maxRate: 2_800_000}},
+		{"exactly the largest variant", 1080, videoVariant{size: 1080, maxRate: 5_000_000}},
+		{"larger than the largest variant", 2160, videoVariant{size: 1080, maxRate: 5_000_000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := variantFor(tt.size); got != tt.want {
+				t.Errorf("variantFor(%d) = %+v, want %+v", tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvenDimension(t *testing.T) {
+	tests := []struct {
+		size int
+		want int
+	}{
+		{0, 2},
+		{1, 2},
+		{2, 2},
+		{361, 360},
+		{720, 720},
+	}
+
+	for _, tt := range tests {
+		if got := evenDimension(tt.size); got != tt.want {
+			t.Errorf("evenDimension(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
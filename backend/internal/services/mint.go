@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// mintSweepInterval is how often mints left in progress are checked
+	// against the chain.
+	mintSweepInterval = 5 * time.Minute
+	// mintGracePeriod is how long a mint is left to the request that
+	// started it before the sweep reconciles it. It must outlast the
+	// request's own wait for confirmation.
+	mintGracePeriod = 10 * time.Minute
+)
+
+// MintService records minted tokens against their NFTs and reconciles
+// mints whose request timed out or failed to record the result, finishing
+// those that confirmed on chain and discarding those that minted nothing.
+type MintService struct {
+	db    *database.Database
+	chain ChainClient
+}
+
+func NewMintService(db *database.Database, chain ChainClient) *MintService {
+	return &MintService{db: db, chain: chain}
+}
+
+// RecordSubmitted stores the hash of a mint transaction once it is sent, so
+// the mint can be reconciled if the request never sees it confirm.
+func (s *MintService) RecordSubmitted(ctx context.Context, nftID, txHash string) error {
+	_, err := s.db.NFTs().UpdateOne(
+		ctx,
+		bson.M{"_id": nftID, "status": "minting"},
+		bson.M{"$set": bson.M{"mint_tx_hash": txHash, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// Finish records the minted token on an NFT and its mint transfer to the
+// owner. It reports whether this call finished the mint; false means the
+// mint was already finished or discarded.
+func (s *MintService) Finish(ctx context.Context, nft *models.NFT, result *MintResult) (bool, error) {
+	now := time.Now()
+	update, err := s.db.NFTs().UpdateOne(
+		ctx,
+		bson.M{"_id": nft.ID, "status": "minting"},
+		bson.M{
+			"$set":   bson.M{"token_id": result.TokenID, "mint_tx_hash": result.TxHash, "updated_at": now},
+			"$unset": bson.M{"status": ""},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	if update.ModifiedCount == 0 {
+		return false, nil
+	}
+
+	nft.TokenID = result.TokenID
+	nft.MintTxHash = result.TxHash
+	nft.Status = ""
+
+	_, err = s.db.NFTTransfers().InsertOne(ctx, models.NFTTransfer{
+		ID:        primitive.NewObjectID().Hex(),
+		NFTID:     nft.ID,
+		ToUserID:  nft.OwnerID,
+		TxHash:    result.TxHash,
+		CreatedAt: now,
+	})
+	return true, err
+}
+
+// Discard removes the record of a mint that minted nothing.
+func (s *MintService) Discard(ctx context.Context, nftID string) error {
+	_, err := s.db.NFTs().DeleteOne(ctx, bson.M{"_id": nftID, "status": "minting"})
+	return err
+}
+
+// Run reconciles stale mints until ctx is cancelled.
+func (s *MintService) Run(ctx context.Context) {
+	ticker := time.NewTicker(mintSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		s.reconcile(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *MintService) reconcile(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, time.Minute)
+	defer cancel()
+
+	cursor, err := s.db.NFTs().Find(ctx, bson.M{
+		"status":     "minting",
+		"created_at": bson.M{"$lte": time.Now().Add(-mintGracePeriod)},
+	})
+	if err != nil {
+		log.Printf("Failed to find stale mints: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var nfts []models.NFT
+	if err = cursor.All(ctx, &nfts); err != nil {
+		log.Printf("Failed to decode stale mints: %v", err)
+		return
+	}
+
+	for i := range nfts {
+		nft := &nfts[i]
+
+		// Without a hash the transaction was never sent
+		if nft.MintTxHash == "" {
+			if err := s.Discard(ctx, nft.ID); err != nil {
+				log.Printf("Failed to discard unsent mint %s: %v", nft.ID, err)
+			}
+			continue
+		}
+
+		result, err := s.chain.MintStatus(ctx, nft.MintTxHash)
+		switch {
+		case err == nil:
+			if _, err := s.Finish(ctx, nft, result); err != nil {
+				log.Printf("Failed to finish mint %s (tx %s): %v", nft.ID, nft.MintTxHash, err)
+			}
+		case errors.Is(err, ErrChainTxReverted), errors.Is(err, ErrChainTxNotFound):
+			if err := s.Discard(ctx, nft.ID); err != nil {
+				log.Printf("Failed to discard failed mint %s: %v", nft.ID, err)
+			}
+		case errors.Is(err, ErrChainTxPending):
+			// Check again on the next sweep
+		default:
+			log.Printf("Failed to check mint %s (tx %s): %v", nft.ID, nft.MintTxHash, err)
+		}
+	}
+}
@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// suspensionSweepInterval is how often expired suspensions are lifted and
+// content checked against its authors' suspensions.
+const suspensionSweepInterval = 5 * time.Minute
+
+// suspendableContent are the content types that keep a copy of their
+// author's suspension.
+var suspendableContent = []string{"post", "comment", "listing"}
+
+var (
+	ErrTargetNotFound = errors.New("moderation target not found")
+	ErrInvalidAction  = errors.New("action does not apply to this target")
+)
+
+// ModerationService applies moderator actions to content and users.
+type ModerationService struct {
+	db       *database.Database
+	hub      *RealtimeHub
+	profiles *ProfileSyncService
+}
+
+func NewModerationService(db *database.Database, hub *RealtimeHub, profiles *ProfileSyncService) *ModerationService {
+	return &ModerationService{db: db, hub: hub, profiles: profiles}
+}
+
+// VisibleContentFilter matches content that moderators haven't hidden or
+// removed and no copyright notice has disabled. Hidden content stays visible
+// to its owner, stored in ownerField.
+func VisibleContentFilter(ownerField, viewerID string) bson.M {
+	visible := []bson.M{{"moderation_status": bson.M{"$exists": false}}}
+	if viewerID != "" {
+		visible = append(visible, bson.M{"moderation_status": "hidden", ownerField: viewerID})
+	}
+	return bson.M{"$or": visible, "dmca_disabled": bson.M{"$ne": true}}
+}
+
+// IsContentVisible is VisibleContentFilter for an already loaded document,
+// apart from copyright notices, which only apply to posts and listings.
+func IsContentVisible(moderationStatus, ownerID, viewerID string) bool {
+	switch moderationStatus {
+	case "":
+		return true
+	case "hidden":
+		return viewerID != "" && ownerID == viewerID
+	default:
+		return false
+	}
+}
+
+// contentCollection returns the collection holding a content target type and
+// the field naming its owner.
+func (s *ModerationService) contentCollection(targetType string) (*mongo.Collection, string, bool) {
+	switch targetType {
+	case "post":
+		return s.db.Posts(), "user_id", true
+	case "comment":
+		return s.db.Comments(), "user_id", true
+	case "message":
+		return s.db.Messages(), "sender_id", true
+	case "listing":
+		return s.db.NFTListings(), "owner_id", true
+	default:
+		return nil, "", false
+	}
+}
+
+// TargetOwner returns the user responsible for a report target: the author
+// of content, or the user itself.
+func (s *ModerationService) TargetOwner(ctx context.Context, targetType, targetID string) (string, error) {
+	if targetType == "user" {
+		count, err := s.db.Users().CountDocuments(ctx, bson.M{"_id": targetID})
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return "", ErrTargetNotFound
+		}
+		return targetID, nil
+	}
+
+	collection, ownerField, ok := s.contentCollection(targetType)
+	if !ok {
+		return "", ErrTargetNotFound
+	}
+
+	var doc bson.M
+	err := collection.FindOne(
+		ctx,
+		bson.M{"_id": targetID},
+		options.FindOne().SetProjection(bson.M{ownerField: 1}),
+	).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return "", ErrTargetNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ownerID, _ := doc[ownerField].(string)
+	return ownerID, nil
+}
+
+// Apply carries out a moderation action and records it. It fills in the
+// action's ID, affected user and creation time.
+func (s *ModerationService) Apply(ctx context.Context, action *models.ModerationAction) error {
+	ownerID, err := s.TargetOwner(ctx, action.TargetType, action.TargetID)
+	if err != nil {
+		return err
+	}
+
+	action.ID = primitive.NewObjectID().Hex()
+	action.UserID = ownerID
+	action.CreatedAt = time.Now()
+
+	switch action.Action {
+	case "hide", "remove", "restore", "dmca_disable", "dmca_restore":
+		if err := s.setContentStatus(ctx, action.TargetType, action.TargetID, action.Action); err != nil {
+			return err
+		}
+	case "warn":
+		_, err = s.db.Users().UpdateOne(
+			ctx,
+			bson.M{"_id": ownerID},
+			bson.M{"$inc": bson.M{"warnings_count": 1}},
+		)
+		if err != nil {
+			return err
+		}
+	case "suspend":
+		err := s.suspend(ctx, ownerID, models.Suspension{
+			Reason:      action.Note,
+			Until:       action.ExpiresAt,
+			SuspendedBy: action.ModeratorID,
+			ReportID:    action.ReportID,
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidAction
+	}
+
+	if _, err := s.db.ModerationActions().InsertOne(ctx, action); err != nil {
+		return err
+	}
+
+	s.hub.Publish("moderation", map[string]interface{}{
+		"action":     action.Action,
+		"targetType": action.TargetType,
+		"targetId":   action.TargetID,
+		"note":       action.Note,
+		"expiresAt":  action.ExpiresAt,
+	}, ownerID)
+
+	return nil
+}
+
+// Suspend suspends a user until the given time, or permanently if until is nil.
+func (s *ModerationService) Suspend(ctx context.Context, userID, reason string, until *time.Time, suspendedBy string) error {
+	return s.suspend(ctx, userID, models.Suspension{
+		Reason:      reason,
+		Until:       until,
+		SuspendedBy: suspendedBy,
+	})
+}
+
+func (s *ModerationService) suspend(ctx context.Context, userID string, suspension models.Suspension) error {
+	suspension.CreatedAt = time.Now()
+
+	result, err := s.db.Users().UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"suspension": suspension,
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrTargetNotFound
+	}
+
+	s.syncContent(ctx, userID)
+	return nil
+}
+
+// syncContent schedules copying a user's suspension onto their posts,
+// comments and listings, which lists filter on. If that fails, the sweep
+// finds the content out of step and schedules it again.
+func (s *ModerationService) syncContent(ctx context.Context, userID string) {
+	if err := s.profiles.Enqueue(ctx, userID); err != nil {
+		log.Printf("Failed to schedule profile sync for user %s: %v", userID, err)
+	}
+}
+
+// Unsuspend lifts a user's suspension.
+func (s *ModerationService) Unsuspend(ctx context.Context, userID string) error {
+	result, err := s.db.Users().UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$unset": bson.M{"suspension": ""},
+		"$set":   bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrTargetNotFound
+	}
+
+	s.syncContent(ctx, userID)
+	return nil
+}
+
+// UnsuspendForReports lifts a user's suspension if a moderator imposed it
+// acting on one of the given reports, and notifies the user. It reports
+// whether there was such a suspension.
+func (s *ModerationService) UnsuspendForReports(ctx context.Context, userID string, reportIDs []string) (bool, error) {
+	if len(reportIDs) == 0 {
+		return false, nil
+	}
+
+	result, err := s.db.Users().UpdateOne(
+		ctx,
+		bson.M{"_id": userID, "suspension.report_id": bson.M{"$in": reportIDs}},
+		bson.M{
+			"$unset": bson.M{"suspension": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	if result.ModifiedCount == 0 {
+		return false, nil
+	}
+
+	s.syncContent(ctx, userID)
+	s.hub.Publish("moderation", map[string]interface{}{
+		"action":     "unsuspend",
+		"targetType": "user",
+		"targetId":   userID,
+	}, userID)
+
+	return true, nil
+}
+
+// Run periodically lifts suspensions whose term has ended and notifies the
+// users. Expired suspensions are already ignored everywhere; this clears them
+// from the record and tells the user they can sign in again. It also resyncs
+// content whose copy of its author's suspension is out of step.
+func (s *ModerationService) Run(ctx context.Context) {
+	ticker := time.NewTicker(suspensionSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		s.liftExpiredSuspensions(ctx)
+		s.resyncSuspensions(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ModerationService) liftExpiredSuspensions(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, time.Minute)
+	defer cancel()
+
+	cursor, err := s.db.Users().Find(
+		ctx,
+		bson.M{"suspension.until": bson.M{"$ne": nil, "$lte": time.Now()}},
+		options.Find().SetProjection(bson.M{"suspension": 1}),
+	)
+	if err != nil {
+		log.Printf("Failed to find expired suspensions: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		log.Printf("Failed to decode expired suspensions: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		// Leave the user alone if they were suspended again meanwhile
+		result, err := s.db.Users().UpdateOne(
+			ctx,
+			bson.M{"_id": user.ID, "suspension.until": user.Suspension.Until},
+			bson.M{
+				"$unset": bson.M{"suspension": ""},
+				"$set":   bson.M{"updated_at": time.Now()},
+			},
+		)
+		if err != nil {
+			log.Printf("Failed to lift suspension of user %s: %v", user.ID, err)
+			continue
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		s.syncContent(ctx, user.ID)
+		s.hub.Publish("moderation", map[string]interface{}{
+			"action":     "unsuspend",
+			"targetType": "user",
+			"targetId":   user.ID,
+		}, user.ID)
+	}
+}
+
+// resyncSuspensions schedules a profile sync for users whose content
+// disagrees with their suspension, as happens when scheduling one after the
+// suspension changed failed. Users with a sync underway are left to it.
+func (s *ModerationService) resyncSuspensions(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	stale := map[string]bool{}
+
+	// Suspended users whose content isn't marked with their suspension
+	cursor, err := s.db.Users().Find(
+		ctx,
+		bson.M{
+			"suspension": bson.M{"$exists": true},
+			"$or": []bson.M{
+				{"suspension.until": nil},
+				{"suspension.until": bson.M{"$gt": now}},
+			},
+		},
+		options.Find().SetProjection(bson.M{"suspension": 1}),
+	)
+	if err != nil {
+		log.Printf("Failed to find suspended users: %v", err)
+		return
+	}
+
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		log.Printf("Failed to decode suspended users: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		unmarked := []bson.M{{"author_suspended": bson.M{"$ne": true}}}
+		if user.Suspension.Until == nil {
+			unmarked = append(unmarked, bson.M{"author_suspension_ends_at": bson.M{"$exists": true}})
+		} else {
+			unmarked = append(unmarked, bson.M{"author_suspension_ends_at": bson.M{"$ne": *user.Suspension.Until}})
+		}
+
+		for _, targetType := range suspendableContent {
+			collection, ownerField, _ := s.contentCollection(targetType)
+			n, err := collection.CountDocuments(ctx, bson.M{ownerField: user.ID, "$or": unmarked}, options.Count().SetLimit(1))
+			if err != nil {
+				log.Printf("Failed to check %s suspension flags of user %s: %v", targetType, user.ID, err)
+				return
+			}
+			if n > 0 {
+				stale[user.ID] = true
+				break
+			}
+		}
+	}
+
+	// Content still marked with a suspension its author no longer has
+	for _, targetType := range suspendableContent {
+		collection, ownerField, _ := s.contentCollection(targetType)
+		ownerIDs, err := collection.Distinct(ctx, ownerField, bson.M{
+			"author_suspended": true,
+			"$or": []bson.M{
+				{"author_suspension_ends_at": bson.M{"$exists": false}},
+				{"author_suspension_ends_at": bson.M{"$gt": now}},
+			},
+		})
+		if err != nil {
+			log.Printf("Failed to find suspended %s authors: %v", targetType, err)
+			return
+		}
+
+		for _, value := range ownerIDs {
+			ownerID, _ := value.(string)
+			if ownerID == "" || stale[ownerID] {
+				continue
+			}
+
+			var user models.User
+			err := s.db.Users().FindOne(ctx, bson.M{"_id": ownerID}, options.FindOne().SetProjection(bson.M{"suspension": 1})).Decode(&user)
+			if err == mongo.ErrNoDocuments {
+				continue
+			}
+			if err != nil {
+				log.Printf("Failed to fetch user %s: %v", ownerID, err)
+				return
+			}
+			if !user.Suspension.Active(now) {
+				stale[ownerID] = true
+			}
+		}
+	}
+
+	for userID := range stale {
+		n, err := s.db.ProfileSyncs().CountDocuments(ctx, bson.M{"_id": userID, "status": bson.M{"$ne": "done"}})
+		if err != nil {
+			log.Printf("Failed to check profile sync of user %s: %v", userID, err)
+			continue
+		}
+		if n == 0 {
+			s.syncContent(ctx, userID)
+		}
+	}
+}
+
+func (s *ModerationService) setContentStatus(ctx context.Context, targetType, targetID, action string) error {
+	collection, _, ok := s.contentCollection(targetType)
+	if !ok {
+		return ErrInvalidAction
+	}
+
+	// Copyright notices disable content separately, so resolving a notice
+	// and a moderator's decision don't undo each other
+	var update bson.M
+	switch action {
+	case "hide":
+		update = bson.M{"$set": bson.M{"moderation_status": "hidden"}}
+	case "remove":
+		update = bson.M{"$set": bson.M{"moderation_status": "removed"}}
+	case "dmca_disable":
+		update = bson.M{"$set": bson.M{"dmca_disabled": true}}
+	case "dmca_restore":
+		update = bson.M{"$unset": bson.M{"dmca_disabled": ""}}
+	default:
+		update = bson.M{"$unset": bson.M{"moderation_status": ""}}
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": targetID}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrTargetNotFound
+	}
+
+	if targetType != "listing" {
+		return nil
+	}
+
+	// A removed listing can no longer be bid on. Restoring it reopens the
+	// auction with its bids, once neither a moderator nor a copyright notice
+	// keeps it down.
+	switch action {
+	case "remove", "dmca_disable":
+		_, err = s.db.NFTListings().UpdateOne(
+			ctx,
+			bson.M{"_id": targetID, "status": "active"},
+			bson.M{"$set": bson.M{"status": "cancelled", "pre_removal_status": "active", "updated_at": time.Now()}},
+		)
+	case "restore", "dmca_restore":
+		_, err = s.db.NFTListings().UpdateOne(
+			ctx,
+			bson.M{
+				"_id":                targetID,
+				"status":             "cancelled",
+				"pre_removal_status": "active",
+				"moderation_status":  bson.M{"$ne": "removed"},
+				"dmca_disabled":      bson.M{"$ne": true},
+			},
+			bson.M{
+				"$set":   bson.M{"status": "active", "updated_at": time.Now()},
+				"$unset": bson.M{"pre_removal_status": ""},
+			},
+		)
+		// Unless the post was listed again meanwhile
+		if mongo.IsDuplicateKeyError(err) {
+			err = nil
+		}
+	}
+	return err
+}
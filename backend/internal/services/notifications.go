@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// notificationActorLimit is how many of a group's most recent actors are
+// kept for display; the rest are only counted.
+const notificationActorLimit = 3
+
+// NotificationService records notifications and pushes them to their
+// recipients as "notification" events.
+type NotificationService struct {
+	db  *database.Database
+	hub *RealtimeHub
+}
+
+func NewNotificationService(db *database.Database, hub *RealtimeHub) *NotificationService {
+	return &NotificationService{db: db, hub: hub}
+}
+
+// Notify records n for n.UserID. actorID is who caused it, or empty for
+// notifications from the system. Notifications with a GroupKey join an
+// unread one with the same key instead of adding another. Users aren't
+// notified of their own actions or of types they turned off, unless the
+// type is required.
+//
+// Notifications are a side effect of the request that triggers them, so
+// failures are logged rather than returned.
+func (s *NotificationService) Notify(ctx context.Context, actorID string, n models.Notification) {
+	if n.UserID == "" || n.UserID == actorID {
+		return
+	}
+
+	enabled, err := s.enabled(ctx, n.UserID, n.Type)
+	if err != nil {
+		log.Printf("Failed to check notification preferences of user %s: %v", n.UserID, err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	// A concurrent first notification can win the insert; joining its group
+	// then succeeds on the second try
+	for attempt := 0; attempt < 2; attempt++ {
+		if n.GroupKey != "" && actorID != "" {
+			grouped, joined, err := s.join(ctx, actorID, &n)
+			if err != nil {
+				log.Printf("Failed to group %s notification for user %s: %v", n.Type, n.UserID, err)
+				return
+			}
+			if grouped != nil {
+				if joined {
+					s.hub.Publish("notification", grouped, n.UserID)
+				}
+				return
+			}
+		}
+
+		notification := n
+		notification.ID = primitive.NewObjectID().Hex()
+		if actorID != "" {
+			notification.ActorIDs = []string{actorID}
+			notification.ActorSet = []string{actorID}
+			notification.ActorsCount = 1
+		}
+		notification.IsRead = false
+		notification.CreatedAt = time.Now()
+		notification.UpdatedAt = notification.CreatedAt
+
+		_, err := s.db.Notifications().InsertOne(ctx, notification)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to record %s notification for user %s: %v", n.Type, n.UserID, err)
+			return
+		}
+
+		s.hub.Publish("notification", notification, n.UserID)
+		return
+	}
+}
+
+// join adds actorID to the unread notification in n's group, if there is
+// one. joined is false when the actor was already in it.
+func (s *NotificationService) join(ctx context.Context, actorID string, n *models.Notification) (*models.Notification, bool, error) {
+	filter := bson.M{"user_id": n.UserID, "group_key": n.GroupKey, "is_read": false}
+	// The full actor set can be large and is never shown
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"actor_set": 0})
+
+	// Groups from before actor_set existed start it from their actor_ids
+	actorSet := bson.M{"$ifNull": bson.A{"$actor_set", "$actor_ids"}}
+
+	set := bson.M{
+		"actor_set": bson.M{"$setUnion": bson.A{actorSet, bson.A{actorID}}},
+		"actor_ids": bson.M{"$slice": bson.A{
+			bson.M{"$concatArrays": bson.A{bson.A{actorID}, bson.M{"$ifNull": bson.A{"$actor_ids", bson.A{}}}}},
+			notificationActorLimit,
+		}},
+		"updated_at": time.Now(),
+	}
+	if n.Data != nil {
+		set["data"] = bson.M{"$literal": n.Data}
+	}
+
+	var grouped models.Notification
+	err := s.db.Notifications().FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"user_id":   n.UserID,
+			"group_key": n.GroupKey,
+			"is_read":   false,
+			"actor_set": bson.M{"$ne": actorID},
+			"actor_ids": bson.M{"$ne": actorID},
+		},
+		bson.A{
+			bson.M{"$set": set},
+			bson.M{"$set": bson.M{"actors_count": bson.M{"$size": "$actor_set"}}},
+		},
+		opts,
+	).Decode(&grouped)
+	if err == nil {
+		return &grouped, true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
+	}
+
+	// The actor is already in the group, e.g. liking a post again
+	err = s.db.Notifications().FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"actor_set": 0})).Decode(&grouped)
+	if err == mongo.ErrNoDocuments {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &grouped, false, nil
+}
+
+func (s *NotificationService) enabled(ctx context.Context, userID, notificationType string) (bool, error) {
+	if models.RequiredNotificationTypes[notificationType] {
+		return true, nil
+	}
+	count, err := s.db.Users().CountDocuments(ctx, bson.M{"_id": userID, "notifications_off": notificationType})
+	if err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
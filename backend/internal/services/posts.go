@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// postPurgeInterval is how often deleted posts past their restore window
+// are removed for good.
+const postPurgeInterval = time.Hour
+
+// PostService removes deleted posts once they can no longer be restored.
+// Posts that a transaction, offer or NFT listing refers to are reduced to a
+// tombstone instead, so those records keep the post's ID and author.
+type PostService struct {
+	db *database.Database
+}
+
+func NewPostService(db *database.Database) *PostService {
+	return &PostService{db: db}
+}
+
+// Run periodically purges posts deleted more than models.PostRestoreWindow
+// ago, along with their likes and comments.
+func (s *PostService) Run(ctx context.Context) {
+	ticker := time.NewTicker(postPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		s.purgeDeleted(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *PostService) purgeDeleted(parent context.Context) {
+	ctx, cancel := context.WithTimeout(parent, time.Minute)
+	defer cancel()
+
+	cursor, err := s.db.Posts().Find(
+		ctx,
+		bson.M{
+			"deleted_at": bson.M{"$lte": time.Now().Add(-models.PostRestoreWindow)},
+			"purged_at":  bson.M{"$exists": false},
+		},
+		options.Find().SetProjection(bson.M{"_id": 1, "user_id": 1, "deleted_at": 1}).SetLimit(100),
+	)
+	if err != nil {
+		log.Printf("Failed to find deleted posts: %v", err)
+		return
+	}
+
+	var posts []models.Post
+	if err := cursor.All(ctx, &posts); err != nil {
+		log.Printf("Failed to decode deleted posts: %v", err)
+		return
+	}
+
+	for _, post := range posts {
+		if err := s.purge(ctx, &post); err != nil {
+			log.Printf("Failed to purge post %s: %v", post.ID, err)
+		}
+	}
+}
+
+// purge deletes a post and what hangs off it. While a transaction, offer or
+// NFT listing refers to the post, its revisions, which record what was sold,
+// are kept along with a tombstone of the post.
+func (s *PostService) purge(ctx context.Context, post *models.Post) error {
+	postID := post.ID
+	if _, err := s.db.Likes().DeleteMany(ctx, bson.M{"post_id": postID}); err != nil {
+		return err
+	}
+	if _, err := s.db.CommentLikes().DeleteMany(ctx, bson.M{"post_id": postID}); err != nil {
+		return err
+	}
+	if _, err := s.db.Comments().DeleteMany(ctx, bson.M{"post_id": postID}); err != nil {
+		return err
+	}
+
+	referenced, err := s.referenced(ctx, postID)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		_, err = s.db.Posts().ReplaceOne(ctx, bson.M{"_id": postID}, bson.M{
+			"user_id":    post.UserID,
+			"deleted_at": post.DeletedAt,
+			"purged_at":  time.Now(),
+		})
+		return err
+	}
+
+	if _, err := s.db.PostRevisions().DeleteMany(ctx, bson.M{"post_id": postID}); err != nil {
+		return err
+	}
+
+	_, err = s.db.Posts().DeleteOne(ctx, bson.M{"_id": postID})
+	return err
+}
+
+// referenced reports whether a transaction, an offer in a conversation or
+// an NFT listing refers to the post.
+func (s *PostService) referenced(ctx context.Context, postID string) (bool, error) {
+	references := map[*mongo.Collection]bson.M{
+		s.db.Transactions(): {"post_id": postID},
+		s.db.Messages():     {"offer.post_id": postID},
+		s.db.NFTListings():  {"post_id": postID},
+	}
+
+	for collection, filter := range references {
+		n, err := collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserBlocked        = errors.New("user is blocked")
+	ErrMessagesRestricted = errors.New("user does not accept messages from this sender")
+)
+
+// PrivacyService answers who may see and contact whom: blocks, private
+// accounts and direct message settings.
+type PrivacyService struct {
+	db *database.Database
+}
+
+func NewPrivacyService(db *database.Database) *PrivacyService {
+	return &PrivacyService{db: db}
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (s *PrivacyService) IsBlocked(ctx context.Context, userA, userB string) (bool, error) {
+	if userA == "" || userB == "" || userA == userB {
+		return false, nil
+	}
+
+	count, err := s.db.Blocks().CountDocuments(ctx, bson.M{
+		"$or": []bson.M{
+			{"blocker_id": userA, "blocked_id": userB},
+			{"blocker_id": userB, "blocked_id": userA},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// BlockedUserIDs returns the users hidden from userID: those it blocked and
+// those that blocked it. Anonymous viewers have no blocks.
+func (s *PrivacyService) BlockedUserIDs(ctx context.Context, userID string) ([]string, error) {
+	if userID == "" {
+		return nil, nil
+	}
+
+	cursor, err := s.db.Blocks().Find(ctx, bson.M{
+		"$or": []bson.M{
+			{"blocker_id": userID},
+			{"blocked_id": userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []models.Block
+	if err = cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		if block.BlockerID == userID {
+			ids = append(ids, block.BlockedID)
+		} else {
+			ids = append(ids, block.BlockerID)
+		}
+	}
+
+	return ids, nil
+}
+
+// CanView reports whether viewerID may see ownerID's posts: the users must
+// not have blocked each other, the owner must not be suspended, and private
+// accounts are only visible to their approved followers. viewerID is empty
+// for anonymous requests.
+func (s *PrivacyService) CanView(ctx context.Context, viewerID, ownerID string) (bool, error) {
+	if viewerID == ownerID {
+		return true, nil
+	}
+
+	blocked, err := s.IsBlocked(ctx, viewerID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	if blocked {
+		return false, nil
+	}
+
+	var owner models.User
+	err = s.db.Users().FindOne(
+		ctx,
+		bson.M{"_id": ownerID},
+		options.FindOne().SetProjection(bson.M{"is_private": 1, "suspension": 1}),
+	).Decode(&owner)
+	if err == mongo.ErrNoDocuments {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if owner.Suspension.Active(time.Now()) {
+		return false, nil
+	}
+	if !owner.IsPrivate {
+		return true, nil
+	}
+	if viewerID == "" {
+		return false, nil
+	}
+
+	return s.IsFollowing(ctx, viewerID, ownerID)
+}
+
+// IsExcluded is AuthorConditions for a single author: whether the users
+// blocked each other or the author is suspended.
+func (s *PrivacyService) IsExcluded(ctx context.Context, viewerID, authorID string) (bool, error) {
+	if viewerID == authorID {
+		return false, nil
+	}
+
+	blocked, err := s.IsBlocked(ctx, viewerID, authorID)
+	if err != nil || blocked {
+		return blocked, err
+	}
+
+	count, err := s.db.Users().CountDocuments(ctx, bson.M{
+		"_id":        authorID,
+		"suspension": bson.M{"$exists": true},
+		"$or": []bson.M{
+			{"suspension.until": nil},
+			{"suspension.until": bson.M{"$gt": time.Now()}},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// PostConditions returns the conditions posts must meet for viewerID to see
+// them in feeds, to combine with a query under $and: no blocks in either
+// direction, the author isn't suspended, and private accounts' posts only
+// for their followers. Privacy is read from the author_private copy on each
+// post, so only the viewer's own follows need loading.
+func (s *PrivacyService) PostConditions(ctx context.Context, viewerID string) ([]bson.M, error) {
+	conditions, err := s.AuthorConditions(ctx, viewerID, "user_id")
+	if err != nil {
+		return nil, err
+	}
+
+	visible := []bson.M{{"author_private": bson.M{"$ne": true}}}
+	if viewerID != "" {
+		followed, err := s.FollowedUserIDs(ctx, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		visible = append(visible, bson.M{"user_id": bson.M{"$in": append(followed, viewerID)}})
+	}
+	conditions = append(conditions, bson.M{"$or": visible})
+
+	return conditions, nil
+}
+
+// FollowedUserIDs returns the users followerID follows.
+func (s *PrivacyService) FollowedUserIDs(ctx context.Context, followerID string) ([]string, error) {
+	cursor, err := s.db.Follows().Find(
+		ctx,
+		bson.M{"follower_id": followerID},
+		options.Find().SetProjection(bson.M{"followee_id": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var follows []models.Follow
+	if err = cursor.All(ctx, &follows); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(follows))
+	for _, follow := range follows {
+		ids = append(ids, follow.FolloweeID)
+	}
+
+	return ids, nil
+}
+
+// AuthorConditions returns the conditions content must meet for viewerID to
+// see it in lists, to combine with a query under $and: no blocks in either
+// direction with the author, named by ownerField, and the author isn't
+// suspended.
+func (s *PrivacyService) AuthorConditions(ctx context.Context, viewerID, ownerField string) ([]bson.M, error) {
+	blocked, err := s.BlockedUserIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []bson.M{NotSuspendedFilter()}
+	if len(blocked) > 0 {
+		conditions = append(conditions, bson.M{ownerField: bson.M{"$nin": blocked}})
+	}
+
+	return conditions, nil
+}
+
+// NotSuspendedFilter matches posts, comments and listings whose author isn't
+// suspended, going by the copy of the suspension ProfileSyncService keeps on
+// them. Temporary suspensions stop matching when they end, without a sync.
+func NotSuspendedFilter() bson.M {
+	return bson.M{"$or": []bson.M{
+		{"author_suspended": bson.M{"$ne": true}},
+		{"author_suspension_ends_at": bson.M{"$lte": time.Now()}},
+	}}
+}
+
+// IsFollowing reports whether followerID follows followeeID.
+func (s *PrivacyService) IsFollowing(ctx context.Context, followerID, followeeID string) (bool, error) {
+	count, err := s.db.Follows().CountDocuments(ctx, bson.M{
+		"follower_id": followerID,
+		"followee_id": followeeID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// CheckNewConversation decides whether senderID may start a direct
+// conversation with receiverID, and whether it lands in the receiver's
+// message requests because the receiver doesn't follow the sender.
+func (s *PrivacyService) CheckNewConversation(ctx context.Context, senderID, receiverID string) (bool, error) {
+	var receiver models.User
+	err := s.db.Users().FindOne(ctx, bson.M{"_id": receiverID}).Decode(&receiver)
+	if err == mongo.ErrNoDocuments {
+		return false, ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	blocked, err := s.IsBlocked(ctx, senderID, receiverID)
+	if err != nil {
+		return false, err
+	}
+	if blocked {
+		return false, ErrUserBlocked
+	}
+
+	switch receiver.DMPrivacy {
+	case "nobody":
+		return false, ErrMessagesRestricted
+	case "followers":
+		following, err := s.IsFollowing(ctx, senderID, receiverID)
+		if err != nil {
+			return false, err
+		}
+		if !following {
+			return false, ErrMessagesRestricted
+		}
+	}
+
+	followedBack, err := s.IsFollowing(ctx, receiverID, senderID)
+	if err != nil {
+		return false, err
+	}
+
+	return !followedBack, nil
+}
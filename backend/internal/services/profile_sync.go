@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// profileSyncPollInterval is how often other instances' jobs are picked
+	// up; jobs enqueued on this instance start straight away.
+	profileSyncPollInterval = 30 * time.Second
+	profileSyncBatchSize    = 500
+	// profileSyncLease is how long a job can go without progress before
+	// another worker takes it over.
+	profileSyncLease = 5 * time.Minute
+)
+
+// errSyncSuperseded stops a sync whose user changed their profile again.
+var errSyncSuperseded = errors.New("profile changed during sync")
+
+// ProfileSyncService copies profile, privacy and suspension changes onto
+// the author fields posts, comments and NFT listings keep, in the
+// background so renaming a prolific account doesn't hold up the request.
+type ProfileSyncService struct {
+	db   *database.Database
+	wake chan struct{}
+}
+
+func NewProfileSyncService(db *database.Database) *ProfileSyncService {
+	return &ProfileSyncService{db: db, wake: make(chan struct{}, 1)}
+}
+
+// Enqueue schedules copying the user's current profile onto their posts,
+// comments and listings. A sync already in progress starts over.
+func (s *ProfileSyncService) Enqueue(ctx context.Context, userID string) error {
+	now := time.Now()
+	_, err := s.db.ProfileSyncs().UpdateOne(
+		ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$inc":         bson.M{"version": 1},
+			"$set":         bson.M{"status": "pending", "updated_at": now},
+			"$unset":       bson.M{"posts_cursor": "", "comments_cursor": "", "listings_cursor": "", "started_at": ""},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run works through pending syncs, waking when one is enqueued.
+func (s *ProfileSyncService) Run(ctx context.Context) {
+	ticker := time.NewTicker(profileSyncPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *ProfileSyncService) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := s.claim(ctx)
+		if err == mongo.ErrNoDocuments {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to claim profile sync: %v", err)
+			return
+		}
+
+		if err := s.sync(ctx, job); err != nil && err != errSyncSuperseded {
+			// The lease runs out and the job is retried from its cursors
+			log.Printf("Failed to sync profile of user %s: %v", job.UserID, err)
+		}
+	}
+}
+
+// claim takes the longest-waiting pending job, or one whose worker stopped
+// making progress.
+func (s *ProfileSyncService) claim(parent context.Context) (*models.ProfileSync, error) {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"$or": []bson.M{
+		{"status": "pending"},
+		{"status": "running", "started_at": bson.M{"$lt": now.Add(-profileSyncLease)}},
+	}}
+	update := bson.M{"$set": bson.M{"status": "running", "started_at": now}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "updated_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.ProfileSync
+	if err := s.db.ProfileSyncs().FindOneAndUpdate(ctx, filter, update, opts).Decode(&job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *ProfileSyncService) sync(ctx context.Context, job *models.ProfileSync) error {
+	user, err := s.findUser(ctx, job.UserID)
+	if err == mongo.ErrNoDocuments {
+		return s.finish(ctx, job, true)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.syncCollection(ctx, job, s.db.Posts(), "user_id", "posts_cursor", job.PostsCursor, authorUpdate(user, bson.M{
+		"username":       user.Username,
+		"user_avatar":    user.ProfilePhoto,
+		"user_location":  user.Location,
+		"author_private": user.IsPrivate,
+	}))
+	if err != nil {
+		return err
+	}
+
+	err = s.syncCollection(ctx, job, s.db.Comments(), "user_id", "comments_cursor", job.CommentsCursor, authorUpdate(user, bson.M{
+		"username":    user.Username,
+		"user_avatar": user.ProfilePhoto,
+	}))
+	if err != nil {
+		return err
+	}
+
+	err = s.syncCollection(ctx, job, s.db.NFTListings(), "owner_id", "listings_cursor", job.ListingsCursor, authorUpdate(user, bson.M{}))
+	if err != nil {
+		return err
+	}
+
+	return s.finish(ctx, job, false)
+}
+
+// authorUpdate adds the user's suspension, which feeds filter on with
+// NotSuspendedFilter, to the fields set on their content.
+func authorUpdate(user *models.User, set bson.M) bson.M {
+	suspended := user.Suspension.Active(time.Now())
+	set["author_suspended"] = suspended
+
+	// A permanent suspension has no end
+	if suspended && user.Suspension.Until != nil {
+		set["author_suspension_ends_at"] = *user.Suspension.Until
+		return bson.M{"$set": set}
+	}
+	return bson.M{"$set": set, "$unset": bson.M{"author_suspension_ends_at": ""}}
+}
+
+func (s *ProfileSyncService) findUser(parent context.Context, userID string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := s.db.Users().FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// finish marks a job done, or drops it once its user is gone, unless the
+// profile changed again meanwhile.
+func (s *ProfileSyncService) finish(parent context.Context, job *models.ProfileSync, drop bool) error {
+	ctx, cancel := context.WithTimeout(parent, 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": job.UserID, "version": job.Version, "status": "running"}
+	if drop {
+		_, err := s.db.ProfileSyncs().DeleteOne(ctx, filter)
+		return err
+	}
+
+	_, err := s.db.ProfileSyncs().UpdateOne(ctx, filter, bson.M{
+		"$set":   bson.M{"status": "done", "updated_at": time.Now()},
+		"$unset": bson.M{"posts_cursor": "", "comments_cursor": "", "listings_cursor": "", "started_at": ""},
+	})
+	return err
+}
+
+// syncCollection applies update to the user's documents, those whose
+// ownerField names them, in batches in ID order, saving its place after each
+// so an interrupted sync resumes there.
+func (s *ProfileSyncService) syncCollection(parent context.Context, job *models.ProfileSync, collection *mongo.Collection, ownerField, cursorField, cursor string, update bson.M) error {
+	for {
+		done, next, err := s.syncBatch(parent, job, collection, ownerField, cursorField, cursor, update)
+		if err != nil || done {
+			return err
+		}
+		cursor = next
+	}
+}
+
+func (s *ProfileSyncService) syncBatch(parent context.Context, job *models.ProfileSync, collection *mongo.Collection, ownerField, cursorField, cursor string, update bson.M) (bool, string, error) {
+	ctx, cancel := context.WithTimeout(parent, 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{ownerField: job.UserID}
+	if cursor != "" {
+		filter["_id"] = bson.M{"$gt": cursor}
+	}
+
+	found, err := collection.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"_id": 1}).
+		SetLimit(profileSyncBatchSize))
+	if err != nil {
+		return false, "", err
+	}
+
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err := found.All(ctx, &docs); err != nil {
+		return false, "", err
+	}
+	if len(docs) == 0 {
+		return true, cursor, nil
+	}
+
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+
+	if _, err := collection.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, update); err != nil {
+		return false, "", err
+	}
+	cursor = ids[len(ids)-1]
+
+	// Saving progress also renews the lease
+	result, err := s.db.ProfileSyncs().UpdateOne(
+		ctx,
+		bson.M{"_id": job.UserID, "version": job.Version, "status": "running"},
+		bson.M{"$set": bson.M{cursorField: cursor, "started_at": time.Now()}},
+	)
+	if err != nil {
+		return false, "", err
+	}
+	if result.MatchedCount == 0 {
+		return false, "", errSyncSuperseded
+	}
+
+	return len(docs) < profileSyncBatchSize, cursor, nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAuthorUpdate(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	earlier := time.Now().Add(-time.Hour)
+	unsetEnd := bson.M{"author_suspension_ends_at": ""}
+
+	tests := []struct {
+		name       string
+		suspension *models.Suspension
+		want       bson.M
+	}{
+		{
+			name: "not suspended",
+			want: bson.M{
+				"$set":   bson.M{"username": "jane", "author_suspended": false},
+				"$unset": unsetEnd,
+			},
+		},
+		{
+			name:       "permanent",
+			suspension: &models.Suspension{},
+			want: bson.M{
+				"$set":   bson.M{"username": "jane", "author_suspended": true},
+				"$unset": unsetEnd,
+			},
+		},
+		{
+			name:       "temporary",
+			suspension: &models.Suspension{Until: &later},
+			want: bson.M{
+				"$set": bson.M{"username": "jane", "author_suspended": true, "author_suspension_ends_at": later},
+			},
+		},
+		{
+			name:       "expired",
+			suspension: &models.Suspension{Until: &earlier},
+			want: bson.M{
+				"$set":   bson.M{"username": "jane", "author_suspended": false},
+				"$unset": unsetEnd,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &models.User{Suspension: tt.suspension}
+			got := authorUpdate(user, bson.M{"username": "jane"})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("authorUpdate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
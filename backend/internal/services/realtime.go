@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+)
+
+// RealtimeEvent is pushed to every open connection of the users in UserIDs.
+type RealtimeEvent struct {
+	Type    string      `json:"type"` // message, read, typing, offer
+	UserIDs []string    `json:"-"`
+	Data    interface{} `json:"data"`
+}
+
+// EventBroker fans events out to every API node. Publish hands an event to the
+// broker; Start delivers every published event, including those published by
+// other nodes, to deliver until ctx is cancelled.
+type EventBroker interface {
+	Publish(ctx context.Context, event RealtimeEvent) error
+	Start(ctx context.Context, deliver func(RealtimeEvent)) error
+}
+
+// NewEventBroker builds the broker selected by REALTIME_BROKER. The in-memory
+// broker only reaches connections on the same node; "mongo" uses change
+// streams and requires MongoDB to run as a replica set.
+func NewEventBroker(db *database.Database) (EventBroker, error) {
+	switch os.Getenv("REALTIME_BROKER") {
+	case "", "memory":
+		return NewMemoryBroker(), nil
+	case "mongo":
+		return NewMongoBroker(db), nil
+	default:
+		return nil, errors.New("unknown REALTIME_BROKER: " + os.Getenv("REALTIME_BROKER"))
+	}
+}
+
+// MemoryBroker delivers events in-process for single-node deployments.
+type MemoryBroker struct {
+	mu      sync.RWMutex
+	deliver func(RealtimeEvent)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, event RealtimeEvent) error {
+	b.mu.RLock()
+	deliver := b.deliver
+	b.mu.RUnlock()
+
+	if deliver != nil {
+		deliver(event)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Start(ctx context.Context, deliver func(RealtimeEvent)) error {
+	b.mu.Lock()
+	b.deliver = deliver
+	b.mu.Unlock()
+
+	<-ctx.Done()
+	return nil
+}
+
+// RealtimeHub tracks open connections per user and pushes broker events to them.
+type RealtimeHub struct {
+	broker EventBroker
+
+	mu      sync.RWMutex
+	clients map[string]map[*RealtimeClient]struct{}
+}
+
+// RealtimeClient is one open connection. Events for it are queued on Send;
+// a client whose queue is full is disconnected rather than blocking the hub.
+type RealtimeClient struct {
+	UserID string
+	Send   chan RealtimeEvent
+}
+
+func NewRealtimeHub(broker EventBroker) *RealtimeHub {
+	return &RealtimeHub{
+		broker:  broker,
+		clients: make(map[string]map[*RealtimeClient]struct{}),
+	}
+}
+
+// Run delivers broker events to connected clients until ctx is cancelled.
+func (h *RealtimeHub) Run(ctx context.Context) {
+	if err := h.broker.Start(ctx, h.deliver); err != nil && ctx.Err() == nil {
+		log.Printf("Realtime broker stopped: %v", err)
+	}
+}
+
+// Publish sends an event to the given users on every node. Failures are only
+// logged: realtime delivery is best effort on top of the persisted data.
+func (h *RealtimeHub) Publish(eventType string, data interface{}, userIDs ...string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	event := RealtimeEvent{Type: eventType, UserIDs: userIDs, Data: data}
+	if err := h.broker.Publish(ctx, event); err != nil {
+		log.Printf("Failed to publish %s event: %v", eventType, err)
+	}
+}
+
+func (h *RealtimeHub) Register(userID string) *RealtimeClient {
+	client := &RealtimeClient{
+		UserID: userID,
+		Send:   make(chan RealtimeEvent, 64),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*RealtimeClient]struct{})
+	}
+	h.clients[userID][client] = struct{}{}
+
+	return client
+}
+
+func (h *RealtimeHub) Unregister(client *RealtimeClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeLocked(client)
+}
+
+func (h *RealtimeHub) deliver(event RealtimeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range event.UserIDs {
+		for client := range h.clients[userID] {
+			select {
+			case client.Send <- event:
+			default:
+				h.removeLocked(client)
+			}
+		}
+	}
+}
+
+func (h *RealtimeHub) removeLocked(client *RealtimeClient) {
+	clients, ok := h.clients[client.UserID]
+	if !ok {
+		return
+	}
+	if _, ok := clients[client]; !ok {
+		return
+	}
+
+	delete(clients, client)
+	close(client.Send)
+	if len(clients) == 0 {
+		delete(h.clients, client.UserID)
+	}
+}
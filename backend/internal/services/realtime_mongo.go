@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/reaviseapp/rv-backend/internal/database"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MongoBroker fans events out across nodes through the realtime_events
+// collection: every node inserts the events it publishes and watches the
+// collection's change stream for inserts from all nodes. Documents expire
+// through a TTL index, see database.EnsureIndexes.
+type MongoBroker struct {
+	db *database.Database
+}
+
+type brokerEvent struct {
+	ID        string    `bson:"_id"`
+	Type      string    `bson:"type"`
+	UserIDs   []string  `bson:"user_ids"`
+	Payload   string    `bson:"payload"` // JSON, so Data round-trips unchanged
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func NewMongoBroker(db *database.Database) *MongoBroker {
+	return &MongoBroker{db: db}
+}
+
+func (b *MongoBroker) Publish(ctx context.Context, event RealtimeEvent) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.db.RealtimeEvents().InsertOne(ctx, brokerEvent{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      event.Type,
+		UserIDs:   event.UserIDs,
+		Payload:   string(payload),
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+func (b *MongoBroker) Start(ctx context.Context, deliver func(RealtimeEvent)) error {
+	for {
+		err := b.watch(ctx, deliver)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		log.Printf("Realtime change stream interrupted, reconnecting: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func (b *MongoBroker) watch(ctx context.Context, deliver func(RealtimeEvent)) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": "insert"}}},
+	}
+
+	stream, err := b.db.RealtimeEvents().Watch(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var change struct {
+			FullDocument brokerEvent `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&change); err != nil {
+			log.Printf("Failed to decode realtime event: %v", err)
+			continue
+		}
+
+		deliver(RealtimeEvent{
+			Type:    change.FullDocument.Type,
+			UserIDs: change.FullDocument.UserIDs,
+			Data:    json.RawMessage(change.FullDocument.Payload),
+		})
+	}
+
+	return stream.Err()
+}
@@ -12,11 +12,12 @@ import (
 )
 
 type RecommendationService struct {
-	db *database.Database
+	db      *database.Database
+	privacy *PrivacyService
 }
 
-func NewRecommendationService(db *database.Database) *RecommendationService {
-	return &RecommendationService{db: db}
+func NewRecommendationService(db *database.Database, privacy *PrivacyService) *RecommendationService {
+	return &RecommendationService{db: db, privacy: privacy}
 }
 
 // GetRecommendedPosts returns recommended posts for a user
@@ -41,12 +42,19 @@ func (s *RecommendationService) GetRecommendedPosts(userID string, limit int) ([
 		likedPostIDs = append(likedPostIDs, like.PostID)
 	}
 
-	// Simple recommendation: get posts from followed users and popular posts
-	// Exclude already liked posts
-	filter := bson.M{
-		"_id": bson.M{"$nin": likedPostIDs},
+	conditions, err := s.privacy.PostConditions(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
 
+	// Simple recommendation: get posts from followed users and popular posts
+	// Exclude already liked posts and posts the user may not see
+	filter := VisibleContentFilter("user_id", userID)
+	filter["processing_status"] = bson.M{"$exists": false}
+	filter["deleted_at"] = bson.M{"$exists": false}
+	filter["_id"] = bson.M{"$nin": likedPostIDs}
+	filter["$and"] = conditions
+
 	opts := options.Find().
 		SetSort(bson.D{{Key: "likes_count", Value: -1}, {Key: "created_at", Value: -1}}).
 		SetLimit(int64(limit))
@@ -99,9 +107,11 @@ func (s *RecommendationService) GetFollowingPosts(userID string, limit int) ([]m
 	}
 
 	// Get posts from followed users
-	filter := bson.M{
-		"user_id": bson.M{"$in": followedUserIDs},
-	}
+	filter := VisibleContentFilter("user_id", userID)
+	filter["processing_status"] = bson.M{"$exists": false}
+	filter["deleted_at"] = bson.M{"$exists": false}
+	filter["user_id"] = bson.M{"$in": followedUserIDs}
+	filter["$and"] = []bson.M{NotSuspendedFilter()}
 
 	opts := options.Find().
 		SetSort(bson.D{{Key: "created_at", Value: -1}}).